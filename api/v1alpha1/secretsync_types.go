@@ -30,8 +30,9 @@ type SecretSync struct {
 type NotificationEvent string
 
 const (
-	NotificationEventSyncSuccess NotificationEvent = "success"
-	NotificationEventSyncFailure NotificationEvent = "failure"
+	NotificationEventSyncSuccess     NotificationEvent = "success"
+	NotificationEventSyncFailure     NotificationEvent = "failure"
+	NotificationEventChangesDetected NotificationEvent = "changes"
 )
 
 type StoreConfig struct {
@@ -127,6 +128,14 @@ type SecretSyncSpec struct {
 	NotificationsTemplate *string             `json:"notificationsTemplate,omitempty"`
 }
 
+// Values of SecretSyncStatus.Status
+const (
+	SecretSyncStatusSynced    = "Synced"
+	SecretSyncStatusDryRun    = "DryRun"
+	SecretSyncStatusFailed    = "Failed"
+	SecretSyncStatusSuspended = "Suspended"
+)
+
 // +kubebuilder:object:generate=true
 
 // SecretSyncStatus defines the observed state of SecretSync
@@ -164,103 +164,6 @@ func (in *RenameTransform) DeepCopy() *RenameTransform {
 	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SlackNotification) DeepCopyInto(out *SlackNotification) {
-	*out = *in
-	if in.Events != nil {
-		in, out := &in.Events, &out.Events
-		*out = make([]NotificationEvent, len(*in))
-		copy(*out, *in)
-	}
-	if in.URL != nil {
-		in, out := &in.URL, &out.URL
-		*out = new(string)
-		**out = **in
-	}
-	if in.URLSecret != nil {
-		in, out := &in.URLSecret, &out.URLSecret
-		*out = new(string)
-		**out = **in
-	}
-	if in.URLSecretKey != nil {
-		in, out := &in.URLSecretKey, &out.URLSecretKey
-		*out = new(string)
-		**out = **in
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackNotification.
-func (in *SlackNotification) DeepCopy() *SlackNotification {
-	if in == nil {
-		return nil
-	}
-	out := new(SlackNotification)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *StoreConfig) DeepCopyInto(out *StoreConfig) {
-	*out = *in
-	if in.AWS != nil {
-		in, out := &in.AWS, &out.AWS
-		*out = (*in).DeepCopy()
-	}
-	if in.IdentityCenter != nil {
-		in, out := &in.IdentityCenter, &out.IdentityCenter
-		*out = (*in).DeepCopy()
-	}
-	if in.Vault != nil {
-		in, out := &in.Vault, &out.Vault
-		*out = (*in).DeepCopy()
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoreConfig.
-func (in *StoreConfig) DeepCopy() *StoreConfig {
-	if in == nil {
-		return nil
-	}
-	out := new(StoreConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *TransformSpec) DeepCopyInto(out *TransformSpec) {
-	*out = *in
-	if in.Include != nil {
-		in, out := &in.Include, &out.Include
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.Exclude != nil {
-		in, out := &in.Exclude, &out.Exclude
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
-	if in.Rename != nil {
-		in, out := &in.Rename, &out.Rename
-		*out = make([]RenameTransform, len(*in))
-		copy(*out, *in)
-	}
-	if in.Template != nil {
-		in, out := &in.Template, &out.Template
-		*out = new(string)
-		**out = **in
-	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformSpec.
-func (in *TransformSpec) DeepCopy() *TransformSpec {
-	if in == nil {
-		return nil
-	}
-	out := new(TransformSpec)
-	in.DeepCopyInto(out)
-	return out
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretSync) DeepCopyInto(out *SecretSync) {
 	*out = *in
@@ -407,6 +310,103 @@ func (in *SecretSyncStatus) DeepCopy() *SecretSyncStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SlackNotification) DeepCopyInto(out *SlackNotification) {
+	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]NotificationEvent, len(*in))
+		copy(*out, *in)
+	}
+	if in.URL != nil {
+		in, out := &in.URL, &out.URL
+		*out = new(string)
+		**out = **in
+	}
+	if in.URLSecret != nil {
+		in, out := &in.URLSecret, &out.URLSecret
+		*out = new(string)
+		**out = **in
+	}
+	if in.URLSecretKey != nil {
+		in, out := &in.URLSecretKey, &out.URLSecretKey
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackNotification.
+func (in *SlackNotification) DeepCopy() *SlackNotification {
+	if in == nil {
+		return nil
+	}
+	out := new(SlackNotification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoreConfig) DeepCopyInto(out *StoreConfig) {
+	*out = *in
+	if in.AWS != nil {
+		in, out := &in.AWS, &out.AWS
+		*out = (*in).DeepCopy()
+	}
+	if in.IdentityCenter != nil {
+		in, out := &in.IdentityCenter, &out.IdentityCenter
+		*out = (*in).DeepCopy()
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoreConfig.
+func (in *StoreConfig) DeepCopy() *StoreConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StoreConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransformSpec) DeepCopyInto(out *TransformSpec) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rename != nil {
+		in, out := &in.Rename, &out.Rename
+		*out = make([]RenameTransform, len(*in))
+		copy(*out, *in)
+	}
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformSpec.
+func (in *TransformSpec) DeepCopy() *TransformSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TransformSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookNotification) DeepCopyInto(out *WebhookNotification) {
 	*out = *in
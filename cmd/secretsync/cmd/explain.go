@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainTarget   string
+	explainSecret   string
+	explainKey      string
+	explainOutput   string
+	explainDiscover bool
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Show which import supplied each key of a merged secret",
+	Long: `Shows, for each key of a secret in a target's merged bundle, the import
+whose value was kept, the imports it overrode or ignored, and the merge
+strategy that decided between them. Values inherited from
+another target are followed through that target's merge down to the source
+that supplied them.
+
+Provenance is recorded by the merge phase next to each bundle. Keys of nested
+maps are shown joined with dots, e.g. "db.host".
+
+Examples:
+  secretsync explain --config config.yaml --target Serverless_Prod --secret api/stripe
+  secretsync explain --config config.yaml --target Serverless_Prod --secret api/stripe --key secret_key`,
+	RunE: runExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().StringVar(&explainTarget, "target", "", "target whose merged bundle holds the secret")
+	explainCmd.Flags().StringVar(&explainSecret, "secret", "", "secret path in the bundle")
+	explainCmd.Flags().StringVar(&explainKey, "key", "", "only explain this key")
+	explainCmd.Flags().StringVarP(&explainOutput, "output", "o", "human", "output format: human, json")
+	explainCmd.Flags().BoolVar(&explainDiscover, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	_ = explainCmd.MarkFlagRequired("target")
+	_ = explainCmd.MarkFlagRequired("secret")
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, err := loadPipeline(ctx, explainDiscover)
+	if err != nil {
+		return err
+	}
+
+	explanations, err := p.Explain(ctx, explainTarget, explainSecret, explainKey)
+	if err != nil {
+		return err
+	}
+
+	if explainOutput == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(explanations)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, e := range explanations {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, e.Key)
+		for _, step := range e.Chain {
+			fmt.Fprintf(w, "  %s\t<- %s\t%s%s\n", step.Target, step.Import, step.Path, describeReplaced(step.KeyProvenance))
+		}
+	}
+	return w.Flush()
+}
+
+// describeReplaced lists the imports a key's value replaced, was appended to
+// or was kept over, and the merge strategy that decided it
+func describeReplaced(kp pipeline.KeyProvenance) string {
+	var parts []string
+	if kp.Strategy != "" {
+		parts = append(parts, kp.Strategy)
+	}
+	if len(kp.Overrode) > 0 {
+		parts = append(parts, "overrode "+joinOrigins(kp.Overrode))
+	}
+	if len(kp.Appended) > 0 {
+		parts = append(parts, "appended to "+joinOrigins(kp.Appended))
+	}
+	if len(kp.Ignored) > 0 {
+		parts = append(parts, "ignored "+joinOrigins(kp.Ignored))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "\t(" + strings.Join(parts, "; ") + ")"
+}
+
+func joinOrigins(origins []pipeline.ValueOrigin) string {
+	names := make([]string, 0, len(origins))
+	for _, o := range origins {
+		names = append(names, fmt.Sprintf("%s [%s]", o.Path, o.Import))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/controller"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Run the Kubernetes controller for SecretSync resources",
+	Long: `Runs a controller that watches SecretSync custom resources and syncs each
+one from its Vault source to its destinations, recording the outcome in the
+resource's status. Resources are re-synced every --sync-interval; suspended
+resources are skipped.
+
+Examples:
+  # Watch all namespaces with leader election
+  secretsync operator --leader-elect
+
+  # Watch a single namespace and re-sync every minute
+  secretsync operator --namespace secrets --sync-interval 1m`,
+	RunE: runOperator,
+}
+
+var (
+	operatorNamespace    string
+	operatorInterval     time.Duration
+	operatorLeaderElect  bool
+	operatorProbeAddress string
+)
+
+func init() {
+	rootCmd.AddCommand(operatorCmd)
+	operatorCmd.Flags().StringVar(&operatorNamespace, "namespace", "", "only watch SecretSync resources in this namespace (default: all)")
+	operatorCmd.Flags().DurationVar(&operatorInterval, "sync-interval", controller.DefaultInterval, "interval between syncs of each resource")
+	operatorCmd.Flags().BoolVar(&operatorLeaderElect, "leader-elect", false, "enable leader election so only one replica syncs")
+	operatorCmd.Flags().StringVar(&operatorProbeAddress, "health-probe-bind-address", ":8081", "address for the /healthz and /readyz probes")
+}
+
+func runOperator(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action":    "runOperator",
+		"namespace": operatorNamespace,
+		"interval":  operatorInterval,
+	})
+
+	ctrl.SetLogger(zap.New())
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("failed to build scheme: %w", err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return fmt.Errorf("failed to build scheme: %w", err)
+	}
+
+	opts := ctrl.Options{
+		Scheme: scheme,
+		// The --metrics-port server exposes SecretSync metrics
+		Metrics:                metricsserver.Options{BindAddress: "0"},
+		HealthProbeBindAddress: operatorProbeAddress,
+		LeaderElection:         operatorLeaderElect,
+		LeaderElectionID:       "secretsync-operator.secretsync.extendeddata.dev",
+		// Notification secrets are read on demand instead of caching every Secret
+		Client: client.Options{Cache: &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}}}},
+	}
+	if operatorNamespace != "" {
+		opts.Cache = cache.Options{DefaultNamespaces: map[string]cache.Config{operatorNamespace: {}}}
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), opts)
+	if err != nil {
+		return fmt.Errorf("failed to create manager: %w", err)
+	}
+
+	reconciler := &controller.SecretSyncReconciler{
+		Client:   mgr.GetClient(),
+		Interval: operatorInterval,
+	}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		return fmt.Errorf("failed to set up controller: %w", err)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		return fmt.Errorf("failed to add health check: %w", err)
+	}
+	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+		return fmt.Errorf("failed to add ready check: %w", err)
+	}
+
+	l.Info("Starting SecretSync operator")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		return fmt.Errorf("operator stopped: %w", err)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/extended-data-library/secretssync/pkg/diff"
 	"github.com/extended-data-library/secretssync/pkg/pipeline"
@@ -16,14 +17,16 @@ import (
 )
 
 var (
-	targets         string
-	mergeOnly       bool
-	syncOnly        bool
-	dryRun          bool
-	discoverTargets bool
-	outputFormat    string
-	computeDiff     bool
-	exitCodeMode    bool
+	targets           string
+	mergeOnly         bool
+	syncOnly          bool
+	dryRun            bool
+	discoverTargets   bool
+	outputFormat      string
+	computeDiff       bool
+	exitCodeMode      bool
+	lockTimeout       time.Duration
+	allowLargeChanges bool
 )
 
 // pipelineCmd runs the full merge-then-sync pipeline
@@ -46,6 +49,9 @@ var pipelineCmd = &cobra.Command{
    - Multiple output formats (human, JSON, GitHub Actions)
    - CI/CD-friendly exit codes (0=no changes, 1=changes, 2=errors)
 
+4. GUARDRAILS: Abort targets that would change too much (pipeline.guardrails)
+   - Exit code 3 when a guardrail trips, unless --allow-large-changes
+
 Examples:
   # Full pipeline
   secretsync pipeline --config config.yaml
@@ -55,7 +61,7 @@ Examples:
 
   # CI/CD mode with exit codes
   secretsync pipeline --config config.yaml --dry-run --exit-code
-  # Returns: 0 if no changes, 1 if changes detected, 2 on errors
+  # Returns: 0 if no changes, 1 if changes detected, 2 on errors, 3 if a guardrail tripped
 
   # GitHub Actions compatible output
   secretsync pipeline --config config.yaml --dry-run --output github
@@ -83,7 +89,9 @@ func init() {
 	// Diff and output options
 	pipelineCmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "output format: human, json, github, compact")
 	pipelineCmd.Flags().BoolVar(&computeDiff, "diff", false, "compute and show diff even when not in dry-run mode")
-	pipelineCmd.Flags().BoolVar(&exitCodeMode, "exit-code", false, "use exit codes: 0=no changes, 1=changes, 2=errors (useful for CI/CD)")
+	pipelineCmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 0, "how long to wait for a run lock held by another run (pipeline.lock)")
+	pipelineCmd.Flags().BoolVar(&exitCodeMode, "exit-code", false, "use exit codes: 0=no changes, 1=changes, 2=errors, 3=guardrail tripped (useful for CI/CD)")
+	pipelineCmd.Flags().BoolVar(&allowLargeChanges, "allow-large-changes", false, "proceed with targets that trip a guardrail (pipeline.guardrails)")
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
@@ -141,12 +149,14 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 
 	// Run options
 	opts := pipeline.Options{
-		Operation:       op,
-		Targets:         targetList,
-		DryRun:          dryRun,
-		ContinueOnError: true,
-		OutputFormat:    format,
-		ComputeDiff:     computeDiff || dryRun,
+		Operation:         op,
+		Targets:           targetList,
+		DryRun:            dryRun,
+		ContinueOnError:   true,
+		OutputFormat:      format,
+		ComputeDiff:       computeDiff || dryRun,
+		LockTimeout:       lockTimeout,
+		AllowLargeChanges: allowLargeChanges,
 	}
 
 	l.WithFields(log.Fields{
@@ -180,6 +190,7 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	exitOnGuardrail(results)
 	if err != nil {
 		return err
 	}
@@ -195,6 +206,19 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// exitOnGuardrail prints the guardrails tripped by a run and exits with
+// pipeline.ExitCodeGuardrail if there are any
+func exitOnGuardrail(results []pipeline.Result) {
+	tripped := pipeline.TrippedGuardrails(results)
+	if len(tripped) == 0 {
+		return
+	}
+	for _, guardErr := range tripped {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", guardErr)
+	}
+	os.Exit(pipeline.ExitCodeGuardrail)
+}
+
 // parseOutputFormat converts string to OutputFormat
 func parseOutputFormat(s string) diff.OutputFormat {
 	switch strings.ToLower(s) {
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	planOut        string
+	planTargets    string
+	planOutput     string
+	planDiscover   bool
+	planAllowLarge bool
+
+	applyDiscover    bool
+	applyLockTimeout time.Duration
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Record the changes a pipeline run would make to a plan file",
+	Long: `Merges every target and resolves its sync without writing anything, prints
+the diff and writes a plan file for 'secretsync apply'.
+
+The plan records each target's bundle, salted content hashes of the bundle
+and of the destination state, the planned destination writes and deletes,
+and a digest of the configuration. It holds no secret values.
+
+A target that trips a guardrail (pipeline.guardrails) fails the plan with
+exit code 3 unless --allow-large-changes is passed.
+
+Examples:
+  secretsync plan --config config.yaml -out plan.json
+  secretsync plan --config config.yaml --out plan.json --targets Serverless_Prod`,
+	RunE: runPlan,
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply PLAN",
+	Short: "Apply a plan file written by 'secretsync plan'",
+	Long: `Applies exactly the writes and deletes recorded in a plan file.
+
+The configuration, the bundle of every planned target and the destination
+state are read again first. If any of them changed since the plan was made,
+apply writes nothing and fails; run plan again.
+
+Examples:
+  secretsync apply --config config.yaml plan.json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(applyCmd)
+
+	planCmd.Flags().StringVar(&planOut, "out", "", "path to write the plan file to")
+	planCmd.Flags().StringVar(&planTargets, "targets", "", "comma-separated list of targets (default: all)")
+	planCmd.Flags().StringVarP(&planOutput, "output", "o", "human", "diff output format: human, json, github, compact")
+	planCmd.Flags().BoolVar(&planDiscover, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	planCmd.Flags().BoolVar(&planAllowLarge, "allow-large-changes", false, "plan targets that trip a guardrail (pipeline.guardrails)")
+	_ = planCmd.MarkFlagRequired("out")
+
+	applyCmd.Flags().BoolVar(&applyDiscover, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	applyCmd.Flags().DurationVar(&applyLockTimeout, "lock-timeout", 0, "how long to wait for a run lock held by another run (pipeline.lock)")
+}
+
+// planOutArgs rewrites the Terraform-style single-dash -out flag of the plan
+// command to --out, which pflag would otherwise parse as the shorthand -o.
+// Other commands and the values of flags are left alone.
+func planOutArgs(args []string) []string {
+	if cmd, _, err := rootCmd.Find(args); err != nil || cmd != planCmd {
+		return args
+	}
+
+	out := make([]string, len(args))
+	takesValue := false
+	for i, arg := range args {
+		if takesValue {
+			takesValue = false
+			out[i] = arg
+			continue
+		}
+		if arg == "--" {
+			copy(out[i:], args[i:])
+			break
+		}
+		if arg == "-out" || strings.HasPrefix(arg, "-out=") {
+			arg = "-" + arg
+		} else if !strings.Contains(arg, "=") {
+			takesValue = flagTakesValue(planCmd, arg)
+		}
+		out[i] = arg
+	}
+	return out
+}
+
+// flagTakesValue reports whether arg is a flag of cmd whose value is the next argument
+func flagTakesValue(cmd *cobra.Command, arg string) bool {
+	var f *pflag.Flag
+	if name, ok := strings.CutPrefix(arg, "--"); ok {
+		f = cmd.Flags().Lookup(name)
+		if f == nil {
+			f = cmd.InheritedFlags().Lookup(name)
+		}
+	} else if len(arg) == 2 && arg[0] == '-' {
+		f = cmd.Flags().ShorthandLookup(arg[1:])
+		if f == nil {
+			f = cmd.InheritedFlags().ShorthandLookup(arg[1:])
+		}
+	}
+	return f != nil && f.NoOptDefVal == ""
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	p, err := loadPipeline(ctx, planDiscover)
+	if err != nil {
+		return err
+	}
+
+	var targetList []string
+	if planTargets != "" {
+		for _, t := range strings.Split(planTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+
+	plan, err := p.Plan(ctx, pipeline.Options{Targets: targetList, AllowLargeChanges: planAllowLarge})
+	var guardErr *pipeline.GuardrailError
+	if errors.As(err, &guardErr) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(pipeline.ExitCodeGuardrail)
+	}
+	if err != nil {
+		return err
+	}
+	if plan.Diff != nil {
+		fmt.Println(diff.FormatDiff(plan.Diff, parseOutputFormat(planOutput)))
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(planOut, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+
+	writes, deletes := 0, 0
+	for _, tp := range plan.Targets {
+		writes += len(tp.Writes)
+		deletes += len(tp.Deletes)
+	}
+	fmt.Printf("Plan written to %s: %d targets, %d writes, %d deletes\n", planOut, len(plan.Targets), writes, deletes)
+	return nil
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runApply",
+		"plan":   args[0],
+	})
+
+	plan, err := pipeline.LoadPlan(args[0])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	p, err := loadPipeline(ctx, applyDiscover)
+	if err != nil {
+		return err
+	}
+
+	results, err := p.Apply(ctx, plan, pipeline.Options{LockTimeout: applyLockTimeout})
+	if len(results) > 0 {
+		printResults(results)
+	}
+	if err != nil {
+		return err
+	}
+
+	l.Info("Plan applied successfully")
+	return nil
+}
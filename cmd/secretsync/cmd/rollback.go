@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rollbackTarget   string
+	rollbackVersion  int
+	rollbackDryRun   bool
+	rollbackYes      bool
+	rollbackOutput   string
+	rollbackDiscover bool
+
+	rollbackLockTimeout time.Duration
+	rollbackAllowLarge  bool
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Re-sync a recorded version of a target's bundle to its destination",
+	Long: `Syncs a version of a target's bundle listed by 'secretsync history' to the
+target's destination, exactly as the sync phase syncs the current bundle
+(target filters, transforms and orphan deletion apply).
+
+The diff against the destination is always shown first. Without --yes the
+rollback asks for confirmation before writing; with --dry-run it stops after
+the diff.
+
+The merge store is not changed: the next merge and sync restore the bundle
+built from the sources, so fix or suspend the sources first.
+
+Examples:
+  # Show what rolling back to version 3 would change
+  secretsync rollback --config config.yaml --target Serverless_Prod --version 3 --dry-run
+
+  # Roll back without prompting
+  secretsync rollback --config config.yaml --target Serverless_Prod --version 3 --yes`,
+	RunE: runRollback,
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().StringVar(&rollbackTarget, "target", "", "target to roll back")
+	rollbackCmd.Flags().IntVar(&rollbackVersion, "version", 0, "bundle version to re-sync (see 'secretsync history')")
+	rollbackCmd.Flags().BoolVar(&rollbackDryRun, "dry-run", false, "only show the diff")
+	rollbackCmd.Flags().BoolVarP(&rollbackYes, "yes", "y", false, "apply without asking for confirmation")
+	rollbackCmd.Flags().StringVarP(&rollbackOutput, "output", "o", "human", "diff output format: human, json, github, compact")
+	rollbackCmd.Flags().BoolVar(&rollbackDiscover, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	rollbackCmd.Flags().DurationVar(&rollbackLockTimeout, "lock-timeout", 0, "how long to wait for a run lock held by another run (pipeline.lock)")
+	rollbackCmd.Flags().BoolVar(&rollbackAllowLarge, "allow-large-changes", false, "roll back even if a guardrail trips (pipeline.guardrails)")
+	_ = rollbackCmd.MarkFlagRequired("target")
+	_ = rollbackCmd.MarkFlagRequired("version")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action":  "runRollback",
+		"target":  rollbackTarget,
+		"version": rollbackVersion,
+	})
+
+	ctx := context.Background()
+	p, err := loadPipeline(ctx, rollbackDiscover)
+	if err != nil {
+		return err
+	}
+
+	result, err := p.Rollback(ctx, rollbackTarget, rollbackVersion, pipeline.Options{DryRun: true, AllowLargeChanges: rollbackAllowLarge})
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		printResults([]pipeline.Result{result})
+		exitOnGuardrail([]pipeline.Result{result})
+		return fmt.Errorf("rollback dry run failed")
+	}
+	fmt.Println(p.FormatDiff(parseOutputFormat(rollbackOutput)))
+
+	if rollbackDryRun {
+		return nil
+	}
+	if p.Diff().IsZeroSum() {
+		fmt.Printf("%s already matches version %d\n", rollbackTarget, rollbackVersion)
+		return nil
+	}
+	if !rollbackYes && !confirm(fmt.Sprintf("Roll back %s to version %d?", rollbackTarget, rollbackVersion)) {
+		return fmt.Errorf("rollback aborted")
+	}
+
+	result, err = p.Rollback(ctx, rollbackTarget, rollbackVersion, pipeline.Options{LockTimeout: rollbackLockTimeout, AllowLargeChanges: rollbackAllowLarge})
+	if err != nil {
+		return err
+	}
+	printResults([]pipeline.Result{result})
+	if !result.Success {
+		return fmt.Errorf("rollback completed with errors")
+	}
+
+	l.Info("Rollback completed successfully")
+	return nil
+}
+
+// confirm asks a yes/no question on stdin; anything but y or yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
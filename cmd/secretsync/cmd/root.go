@@ -75,6 +75,7 @@ Examples:
 
 // Execute runs the root command
 func Execute() {
+	rootCmd.SetArgs(planOutArgs(os.Args[1:]))
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/extended-data-library/secretssync/pkg/scheduler"
+	"github.com/extended-data-library/secretssync/pkg/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// defaultServeMetricsPort is used by serve when --metrics-port is not set
+const defaultServeMetricsPort = 9090
+
+var (
+	serveDryRun       bool
+	serveDiscover     bool
+	serveRunOnStart   bool
+	serveDrainTimeout time.Duration
+	serveAPIAddr      string
+	serveAPIToken     string
+	serveLockTimeout  time.Duration
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the pipeline on a schedule as a long-running daemon",
+	Long: `Loads the configuration once and runs the pipeline on cron schedules.
+
+Each target runs on its own schedule (targets.<name>.schedule) or on the
+default pipeline.schedule; targets sharing a schedule run together. Schedules
+are five-field cron expressions ("*/15 * * * *"), descriptors ("@hourly") or
+intervals ("@every 10m"). Runs never overlap.
+
+The metrics server (/metrics and /health) stays up between runs, on
+--metrics-port or 9090 when it is not set.
+
+With --api-addr, an HTTP control API is served on that address to start runs
+and fetch their status, results and diffs (see docs/PIPELINE.md). It needs a
+bearer token from --api-token or SECRETSYNC_API_TOKEN. API runs use the
+current configuration and never overlap with scheduled runs.
+
+Signals:
+  SIGHUP           reload the configuration; an invalid one is logged and
+                   the current one keeps running
+  SIGINT, SIGTERM  stop scheduling, wait for the in-flight run to finish
+                   (up to --drain-timeout), then exit
+
+Examples:
+  # Run every target on its schedule
+  secretsync serve --config config.yaml
+
+  # Run everything once at startup, then on schedule
+  secretsync serve --config config.yaml --run-on-start
+
+  # Serve the control API on :8443
+  SECRETSYNC_API_TOKEN=... secretsync serve --config config.yaml --api-addr :8443`,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().BoolVar(&serveDryRun, "dry-run", false, "dry run mode (no changes)")
+	serveCmd.Flags().BoolVar(&serveDiscover, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
+	serveCmd.Flags().BoolVar(&serveRunOnStart, "run-on-start", false, "run every scheduled target once at startup")
+	serveCmd.Flags().DurationVar(&serveDrainTimeout, "drain-timeout", 5*time.Minute, "how long to wait for an in-flight run on shutdown before canceling it")
+	serveCmd.Flags().StringVar(&serveAPIAddr, "api-addr", "", "address to serve the HTTP control API on (disabled when empty)")
+	serveCmd.Flags().DurationVar(&serveLockTimeout, "lock-timeout", 0, "how long a run waits for a run lock held by another run (pipeline.lock)")
+	serveCmd.Flags().StringVar(&serveAPIToken, "api-token", "", "bearer token for the control API (default $SECRETSYNC_API_TOKEN)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runServe",
+		"config": cfgFile,
+	})
+
+	if metricsPort == 0 {
+		metricsPort = defaultServeMetricsPort
+		go startMetricsServer()
+	}
+
+	load := func(ctx context.Context) (scheduler.Runner, error) {
+		if serveDiscover {
+			return pipeline.NewFromFileWithContext(ctx, cfgFile)
+		}
+		return pipeline.NewFromFile(cfgFile)
+	}
+	s := scheduler.New(load, pipeline.Options{
+		Operation:       pipeline.OperationPipeline,
+		DryRun:          serveDryRun,
+		ContinueOnError: true,
+		LockTimeout:     serveLockTimeout,
+	})
+
+	// Registered before starting so no signal is missed
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	ctx := context.Background()
+	if err := s.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
+
+	var api *server.Server
+	var apiServer *http.Server
+	if serveAPIAddr != "" {
+		var err error
+		if api, apiServer, err = startAPIServer(s); err != nil {
+			_ = s.Stop(ctx)
+			return err
+		}
+	}
+
+	if serveRunOnStart {
+		s.Trigger()
+	}
+	l.WithField("dryRun", serveDryRun).Info("Serving scheduled pipeline runs")
+
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			l.Info("Received SIGHUP, reloading configuration")
+			if err := s.Reload(ctx); err != nil {
+				l.WithError(err).Error("Failed to reload configuration, keeping current configuration")
+			}
+			continue
+		}
+
+		l.WithFields(log.Fields{
+			"signal":       sig.String(),
+			"drainTimeout": serveDrainTimeout,
+		}).Warn("Received shutdown signal, draining in-flight runs")
+		drainCtx, cancel := context.WithTimeout(ctx, serveDrainTimeout)
+		if apiServer != nil {
+			if err := apiServer.Shutdown(drainCtx); err != nil {
+				l.WithError(err).Warn("Failed to shut down API server")
+			}
+		}
+		err := s.Stop(drainCtx)
+		if api != nil {
+			err = errors.Join(err, api.Wait(drainCtx))
+		}
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to drain: %w", err)
+		}
+		l.Info("Shutdown complete")
+		return nil
+	}
+	return nil
+}
+
+// startAPIServer serves the control API on --api-addr. API runs use the
+// scheduler's current pipeline, so they follow SIGHUP reloads, and its run
+// lock, so they never overlap a scheduled run.
+func startAPIServer(s *scheduler.Scheduler) (*server.Server, *http.Server, error) {
+	token := serveAPIToken
+	if token == "" {
+		token = os.Getenv("SECRETSYNC_API_TOKEN")
+	}
+	api, err := server.New(func() server.Runner {
+		runner, _ := s.Runner().(server.Runner)
+		return runner
+	}, token, s.RunLock())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create API server: %w (set --api-token or SECRETSYNC_API_TOKEN)", err)
+	}
+
+	listener, err := net.Listen("tcp", serveAPIAddr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to listen on %s: %w", serveAPIAddr, err)
+	}
+	srv := &http.Server{
+		Handler:           api.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("API server failed")
+		}
+	}()
+	log.WithFields(log.Fields{
+		"action": "startAPIServer",
+		"addr":   listener.Addr().String(),
+	}).Info("Serving control API")
+	return api, srv, nil
+}
@@ -256,6 +256,8 @@ func (g *AwsClient) GetPath() string {
 	return g.Name
 }
 
+// GetSecret returns the current value of a secret listed by ListSecrets or
+// ListSecretsByFilter: its SecretString, or its SecretBinary for binary secrets
 func (g *AwsClient) GetSecret(ctx context.Context, name string) ([]byte, error) {
 	l := log.WithFields(log.Fields{
 		"action": "GetSecret",
@@ -279,7 +281,13 @@ func (g *AwsClient) GetSecret(ctx context.Context, name string) ([]byte, error)
 		l.WithError(err).Error("Failed to get secret value")
 		return nil, circuitbreaker.WrapError(err, g.breaker.Name(), g.breaker.State())
 	}
-	return []byte(*resp.SecretString), nil
+	switch {
+	case resp.SecretString != nil:
+		return []byte(*resp.SecretString), nil
+	case resp.SecretBinary != nil:
+		return resp.SecretBinary, nil
+	}
+	return nil, fmt.Errorf("secret %s has no value", name)
 }
 
 func (c *AwsClient) createSecret(ctx context.Context, name string, secret []byte) error {
@@ -486,6 +494,12 @@ func (g *AwsClient) getSecretValue(ctx context.Context, arn string) ([]byte, err
 }
 
 func (g *AwsClient) DeleteSecret(ctx context.Context, secret string) error {
+	return g.DeleteSecretWithRecoveryWindow(ctx, secret, 0)
+}
+
+// DeleteSecretWithRecoveryWindow schedules a secret for deletion after the given
+// number of days (7-30). A window of 0 uses the Secrets Manager default of 30 days.
+func (g *AwsClient) DeleteSecretWithRecoveryWindow(ctx context.Context, secret string, recoveryWindowDays int64) error {
 	startTime := time.Now()
 	status := "error"
 	defer func() {
@@ -494,9 +508,10 @@ func (g *AwsClient) DeleteSecret(ctx context.Context, secret string) error {
 	}()
 
 	l := log.WithFields(log.Fields{
-		"action": "DeleteSecret",
-		"driver": g.Driver(),
-		"path":   secret,
+		"action":             "DeleteSecret",
+		"driver":             g.Driver(),
+		"path":               secret,
+		"recoveryWindowDays": recoveryWindowDays,
 	})
 	l.Trace("start")
 	defer l.Trace("end")
@@ -508,10 +523,14 @@ func (g *AwsClient) DeleteSecret(ctx context.Context, secret string) error {
 	g.ensureBreaker()
 
 	// Wrap AWS API call with circuit breaker
+	input := &secretsmanager.DeleteSecretInput{
+		SecretId: &arn,
+	}
+	if recoveryWindowDays > 0 {
+		input.RecoveryWindowInDays = aws.Int64(recoveryWindowDays)
+	}
 	_, err := circuitbreaker.ExecuteTyped(g.breaker, ctx, func(ctx context.Context) (*secretsmanager.DeleteSecretOutput, error) {
-		return g.client.DeleteSecret(ctx, &secretsmanager.DeleteSecretInput{
-			SecretId: &arn,
-		})
+		return g.client.DeleteSecret(ctx, input)
 	})
 	if err != nil {
 		l.WithError(err).Error("Failed to delete secret")
@@ -631,6 +650,110 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 	return secretsList, nil
 }
 
+// ListSecretsByFilter lists secrets whose name starts with prefix and that carry
+// every tag in tags (exact key and value). Unlike ListSecrets it does not touch the
+// list cache; matched ARNs are added to the client's lookup map so GetSecret works.
+func (g *AwsClient) ListSecretsByFilter(ctx context.Context, prefix string, tags map[string]string) ([]string, error) {
+	startTime := time.Now()
+	status := "error"
+	defer func() {
+		observability.RecordDuration(observability.AWSAPICallDuration, startTime, "list_secrets", g.Region, status)
+	}()
+
+	l := log.WithFields(log.Fields{
+		"action": "ListSecretsByFilter",
+		"prefix": prefix,
+		"tags":   tags,
+	})
+	l.Trace("start")
+	defer l.Trace("end")
+
+	// Narrow server-side: the name filter is a prefix match, and tag-key filters
+	// drop secrets missing a required key. Tag values are checked client-side
+	// because AWS evaluates tag-key and tag-value filters independently.
+	var filters []types.Filter
+	if prefix != "" {
+		filters = append(filters, types.Filter{
+			Key:    types.FilterNameStringTypeName,
+			Values: []string{prefix},
+		})
+	}
+	for key := range tags {
+		filters = append(filters, types.Filter{
+			Key:    types.FilterNameStringTypeTagKey,
+			Values: []string{key},
+		})
+	}
+
+	secretsList := []string{}
+	arnMap := make(map[string]string)
+	var nextToken *string
+	pageCount := 0
+	for {
+		params := &secretsmanager.ListSecretsInput{
+			NextToken:              nextToken,
+			Filters:                filters,
+			IncludePlannedDeletion: aws.Bool(false),
+		}
+
+		g.ensureBreaker()
+
+		resp, err := circuitbreaker.ExecuteTyped(g.breaker, ctx, func(ctx context.Context) (*secretsmanager.ListSecretsOutput, error) {
+			return g.client.ListSecrets(ctx, params)
+		})
+		if err != nil {
+			l.Debugf("error: %v", err)
+			return nil, circuitbreaker.WrapError(err, g.breaker.Name(), g.breaker.State())
+		}
+
+		pageCount++
+		for _, secret := range resp.SecretList {
+			secretName := aws.ToString(secret.Name)
+			// The name filter also matches words inside the name; enforce a true prefix
+			if !strings.HasPrefix(secretName, prefix) || !hasTags(secret.Tags, tags) {
+				continue
+			}
+			arnMap[secretName] = aws.ToString(secret.ARN)
+			secretsList = append(secretsList, secretName)
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		nextToken = resp.NextToken
+	}
+
+	observability.AWSPaginationCount.WithLabelValues("list_secrets").Observe(float64(pageCount))
+
+	g.arnMu.Lock()
+	if g.accountSecretArns == nil {
+		g.accountSecretArns = make(map[string]string, len(arnMap))
+	}
+	for name, arn := range arnMap {
+		g.accountSecretArns[name] = arn
+	}
+	g.arnMu.Unlock()
+
+	status = "success"
+	return secretsList, nil
+}
+
+// hasTags reports whether secretTags contains every key/value pair in want
+func hasTags(secretTags []types.Tag, want map[string]string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	have := make(map[string]string, len(secretTags))
+	for _, tag := range secretTags {
+		have[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	for key, value := range want {
+		if v, ok := have[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
 // isSecretEmpty checks if a secret has empty or null value with circuit breaker
 func (g *AwsClient) isSecretEmpty(ctx context.Context, arn string) (bool, error) {
 	// Ensure circuit breaker is initialized
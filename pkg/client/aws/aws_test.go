@@ -2,11 +2,17 @@ package aws
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/extended-data-library/secretssync/pkg/driver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -193,6 +199,47 @@ func TestAwsClient_ListSecrets(t *testing.T) {
 	})
 }
 
+func TestAwsClient_ListSecretsByFilter(t *testing.T) {
+	skipIfNoLocalStack(t)
+
+	client := &AwsClient{
+		Name:   "test",
+		Region: "us-east-1",
+	}
+
+	ctx := context.Background()
+	require.NoError(t, client.CreateClientWithEndpoint(ctx, getTestEndpoint()))
+
+	secrets, err := client.ListSecretsByFilter(ctx, "shared/", map[string]string{"team": "platform"})
+	assert.NoError(t, err)
+	assert.NotNil(t, secrets)
+}
+
+func TestHasTags(t *testing.T) {
+	secretTags := []types.Tag{
+		{Key: aws.String("team"), Value: aws.String("platform")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+	}
+
+	tests := []struct {
+		name string
+		want map[string]string
+		ok   bool
+	}{
+		{name: "no filter", want: nil, ok: true},
+		{name: "single match", want: map[string]string{"team": "platform"}, ok: true},
+		{name: "all match", want: map[string]string{"team": "platform", "env": "prod"}, ok: true},
+		{name: "value mismatch", want: map[string]string{"env": "dev"}, ok: false},
+		{name: "missing key", want: map[string]string{"owner": "me"}, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.ok, hasTags(secretTags, tt.want))
+		})
+	}
+}
+
 func TestAwsClient_GetAlternatePath(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -340,6 +387,53 @@ func TestAwsClient_WriteSecret(t *testing.T) {
 	})
 }
 
+func TestAwsClient_GetSecret(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CA_BUNDLE", "")
+
+	// Secrets Manager speaks JSON 1.1; values are keyed by secret ARN
+	values := map[string]map[string]interface{}{
+		"arn:string": {"SecretString": `{"key":"value"}`},
+		"arn:binary": {"SecretBinary": base64.StdEncoding.EncodeToString([]byte(`{"key":"bin"}`))},
+		"arn:empty":  {},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		var input struct{ SecretId string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		out := map[string]interface{}{"ARN": input.SecretId, "Name": input.SecretId}
+		for k, v := range values[input.SecretId] {
+			out[k] = v
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := &AwsClient{
+		Region: "us-east-1",
+		accountSecretArns: map[string]string{
+			"string": "arn:string",
+			"binary": "arn:binary",
+			"empty":  "arn:empty",
+		},
+	}
+	ctx := context.Background()
+	require.NoError(t, client.CreateClientWithEndpoint(ctx, srv.URL))
+
+	value, err := client.GetSecret(ctx, "string")
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":"value"}`, string(value))
+
+	value, err = client.GetSecret(ctx, "binary")
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":"bin"}`, string(value))
+
+	_, err = client.GetSecret(ctx, "empty")
+	assert.EqualError(t, err, "secret empty has no value")
+}
+
 func TestAwsClient_Close(t *testing.T) {
 	client := &AwsClient{
 		Name: "test",
@@ -0,0 +1,270 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/hashicorp/vault/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// Explicit Vault authentication methods
+const (
+	AuthMethodToken      = "token"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodJWT        = "jwt"
+	AuthMethodAWS        = "aws"
+)
+
+// STS request Vault's AWS auth method verifies to establish the caller's IAM identity
+const (
+	stsGlobalEndpoint       = "https://sts.amazonaws.com/"
+	stsGetCallerIdentity    = "Action=GetCallerIdentity&Version=2011-06-15"
+	awsIAMServerIDHeader    = "X-Vault-AWS-IAM-Server-ID"
+	defaultSTSSigningRegion = "us-east-1"
+)
+
+// defaultServiceAccountTokenPath is where Kubernetes mounts the pod's service account JWT
+const defaultServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Auth configures explicit authentication for a VaultClient.
+//
+// When set, Login uses exactly this method and returns an error if it cannot
+// obtain a token. There is no fallback to VAULT_TOKEN or the in-pod service
+// account, so a misconfigured method fails instead of silently running with
+// different credentials.
+type Auth struct {
+	// Method is one of the AuthMethod* constants
+	Method string
+	// Mount is the auth mount path (defaults to the method name)
+	Mount string
+
+	// Token auth
+	Token string
+
+	// AppRole auth
+	RoleID   string
+	SecretID string
+
+	// Role for Kubernetes, JWT and AWS auth
+	Role string
+
+	// Kubernetes auth
+	TokenPath string // service account JWT path (defaults to the in-pod path)
+
+	// JWT/OIDC auth: the token is read from JWTFile, or else from the JWTEnv variable
+	JWTFile string
+	JWTEnv  string
+
+	// AWS IAM auth
+	AWSRegion      string                     // STS region to sign for (default: global endpoint)
+	AWSServerID    string                     // value for the X-Vault-AWS-IAM-Server-ID header
+	AWSCredentials awssdk.CredentialsProvider // defaults to the SDK default credential chain
+}
+
+// mount returns the auth mount path, defaulting to the method name
+func (a *Auth) mount() string {
+	if a.Mount != "" {
+		return strings.Trim(a.Mount, "/")
+	}
+	return a.Method
+}
+
+// loginWithAuth authenticates with the explicitly configured method
+func (vc *VaultClient) loginWithAuth(ctx context.Context) error {
+	a := vc.Auth
+	l := log.WithFields(log.Fields{
+		"action": "loginWithAuth",
+		"method": a.Method,
+		"mount":  a.mount(),
+	})
+	l.Debug("Logging in to Vault")
+
+	switch a.Method {
+	case AuthMethodToken:
+		if a.Token == "" {
+			return errors.New("vault token auth configured but token is empty")
+		}
+		vc.Client.SetToken(a.Token)
+		// Verify the token now rather than failing on the first read
+		secret, err := vc.Client.Auth().Token().LookupSelfWithContext(ctx)
+		if err != nil {
+			return fmt.Errorf("vault token auth failed: %w", err)
+		}
+		vc.cacheToken(a.Token, secret)
+		return nil
+
+	case AuthMethodAppRole:
+		if a.RoleID == "" {
+			return errors.New("vault approle auth configured but role_id is empty")
+		}
+		data := map[string]interface{}{"role_id": a.RoleID}
+		if a.SecretID != "" {
+			data["secret_id"] = a.SecretID
+		}
+		return vc.writeLogin(ctx, data)
+
+	case AuthMethodKubernetes:
+		if a.Role == "" {
+			return errors.New("vault kubernetes auth configured but role is empty")
+		}
+		tokenPath := a.TokenPath
+		if tokenPath == "" {
+			tokenPath = defaultServiceAccountTokenPath
+		}
+		jwt, err := os.ReadFile(tokenPath)
+		if err != nil {
+			return fmt.Errorf("vault kubernetes auth failed to read service account token: %w", err)
+		}
+		return vc.writeLogin(ctx, map[string]interface{}{
+			"role": a.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		})
+
+	case AuthMethodJWT:
+		if a.Role == "" {
+			return errors.New("vault jwt auth configured but role is empty")
+		}
+		jwt, err := a.readJWT()
+		if err != nil {
+			return err
+		}
+		return vc.writeLogin(ctx, map[string]interface{}{
+			"role": a.Role,
+			"jwt":  jwt,
+		})
+
+	case AuthMethodAWS:
+		data, err := a.signedGetCallerIdentity(ctx)
+		if err != nil {
+			return fmt.Errorf("vault aws auth failed to sign sts:GetCallerIdentity: %w", err)
+		}
+		return vc.writeLogin(ctx, data)
+
+	default:
+		return fmt.Errorf("unsupported vault auth method %q", a.Method)
+	}
+}
+
+// writeLogin posts to auth/{mount}/login and installs the returned client token
+func (vc *VaultClient) writeLogin(ctx context.Context, data map[string]interface{}) error {
+	a := vc.Auth
+	if vc.TTL != "" {
+		data["ttl"] = vc.TTL
+	}
+
+	path := fmt.Sprintf("auth/%s/login", a.mount())
+	secret, err := vc.Client.Logical().WriteWithContext(ctx, path, data)
+	if err != nil {
+		return fmt.Errorf("vault %s login at %s failed: %w", a.Method, path, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login at %s returned no client token", a.Method, path)
+	}
+
+	vc.Client.SetToken(secret.Auth.ClientToken)
+	vc.cacheToken(secret.Auth.ClientToken, secret)
+	return nil
+}
+
+// cacheToken remembers a token issued or verified by loginWithAuth. It is
+// reused until 90% of its TTL has passed; tokens without a TTL until rejected.
+func (vc *VaultClient) cacheToken(token string, secret *api.Secret) {
+	vc.authToken = token
+	vc.authExpiry = time.Time{}
+	if ttl, err := secret.TokenTTL(); err == nil && ttl > 0 {
+		vc.authExpiry = time.Now().Add(ttl - ttl/10)
+	}
+}
+
+// readJWT returns the JWT from the configured file or environment variable
+func (a *Auth) readJWT() (string, error) {
+	if a.JWTFile != "" {
+		data, err := os.ReadFile(a.JWTFile)
+		if err != nil {
+			return "", fmt.Errorf("vault jwt auth failed to read token file: %w", err)
+		}
+		if jwt := strings.TrimSpace(string(data)); jwt != "" {
+			return jwt, nil
+		}
+		return "", fmt.Errorf("vault jwt auth token file %s is empty", a.JWTFile)
+	}
+	if a.JWTEnv != "" {
+		if jwt := strings.TrimSpace(os.Getenv(a.JWTEnv)); jwt != "" {
+			return jwt, nil
+		}
+		return "", fmt.Errorf("vault jwt auth environment variable %s is empty", a.JWTEnv)
+	}
+	return "", errors.New("vault jwt auth requires a token file or environment variable")
+}
+
+// signedGetCallerIdentity builds the login payload for Vault's AWS IAM auth method:
+// an sts:GetCallerIdentity request signed with the caller's AWS credentials, which
+// Vault replays to STS to prove the IAM identity without sharing the credentials.
+func (a *Auth) signedGetCallerIdentity(ctx context.Context) (map[string]interface{}, error) {
+	creds := a.AWSCredentials
+	if creds == nil {
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		creds = cfg.Credentials
+	}
+	if creds == nil {
+		return nil, errors.New("no AWS credentials available")
+	}
+	value, err := creds.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	endpoint := stsGlobalEndpoint
+	region := defaultSTSSigningRegion
+	if a.AWSRegion != "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", a.AWSRegion)
+		region = a.AWSRegion
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(stsGetCallerIdentity))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if a.AWSServerID != "" {
+		req.Header.Set(awsIAMServerIDHeader, a.AWSServerID)
+	}
+
+	payloadHash := sha256.Sum256([]byte(stsGetCallerIdentity))
+	if err := v4.NewSigner().SignHTTP(ctx, value, req, hex.EncodeToString(payloadHash[:]), "sts", region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	headers, err := json.Marshal(req.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"iam_http_request_method": http.MethodPost,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(endpoint)),
+		"iam_request_body":        base64.StdEncoding.EncodeToString([]byte(stsGetCallerIdentity)),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+	}
+	if a.Role != "" {
+		data["role"] = a.Role
+	}
+	return data, nil
+}
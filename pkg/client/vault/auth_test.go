@@ -0,0 +1,243 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeVault returns a server answering login and token lookup requests.
+// Logins are recorded by path so tests can assert on the mount and payload.
+func newFakeVault(t *testing.T, logins map[string]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Token") != "good-token" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"id":"good-token"}}`))
+		case r.Method == http.MethodPut || r.Method == http.MethodPost:
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			logins[r.URL.Path] = body
+			if body["role_id"] == "bad" || body["role"] == "bad" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":["invalid credentials"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"issued-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultClient_LoginWithAuth(t *testing.T) {
+	jwtFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(jwtFile, []byte("sa-jwt\n"), 0600))
+
+	tests := []struct {
+		name      string
+		auth      Auth
+		wantPath  string
+		wantBody  map[string]interface{}
+		wantToken string
+		wantErr   string
+	}{
+		{
+			name:      "token",
+			auth:      Auth{Method: AuthMethodToken, Token: "good-token"},
+			wantToken: "good-token",
+		},
+		{
+			name:    "token rejected",
+			auth:    Auth{Method: AuthMethodToken, Token: "revoked"},
+			wantErr: "vault token auth failed",
+		},
+		{
+			name:    "token empty",
+			auth:    Auth{Method: AuthMethodToken},
+			wantErr: "token is empty",
+		},
+		{
+			name:      "approle with custom mount",
+			auth:      Auth{Method: AuthMethodAppRole, Mount: "ci-approle", RoleID: "rid", SecretID: "sid"},
+			wantPath:  "/v1/auth/ci-approle/login",
+			wantBody:  map[string]interface{}{"role_id": "rid", "secret_id": "sid"},
+			wantToken: "issued-token",
+		},
+		{
+			name:    "approle rejected",
+			auth:    Auth{Method: AuthMethodAppRole, RoleID: "bad"},
+			wantErr: "vault approle login at auth/approle/login failed",
+		},
+		{
+			name:      "kubernetes",
+			auth:      Auth{Method: AuthMethodKubernetes, Mount: "k8s-prod", Role: "secretsync", TokenPath: jwtFile},
+			wantPath:  "/v1/auth/k8s-prod/login",
+			wantBody:  map[string]interface{}{"role": "secretsync", "jwt": "sa-jwt"},
+			wantToken: "issued-token",
+		},
+		{
+			name:    "kubernetes missing token file",
+			auth:    Auth{Method: AuthMethodKubernetes, Role: "secretsync", TokenPath: filepath.Join(t.TempDir(), "missing")},
+			wantErr: "failed to read service account token",
+		},
+		{
+			name:      "jwt from file",
+			auth:      Auth{Method: AuthMethodJWT, Role: "ci", JWTFile: jwtFile},
+			wantPath:  "/v1/auth/jwt/login",
+			wantBody:  map[string]interface{}{"role": "ci", "jwt": "sa-jwt"},
+			wantToken: "issued-token",
+		},
+		{
+			name:      "jwt from env with custom mount",
+			auth:      Auth{Method: AuthMethodJWT, Mount: "github", Role: "ci", JWTEnv: "TEST_VAULT_JWT"},
+			wantPath:  "/v1/auth/github/login",
+			wantBody:  map[string]interface{}{"role": "ci", "jwt": "env-jwt"},
+			wantToken: "issued-token",
+		},
+		{
+			name:    "jwt without source",
+			auth:    Auth{Method: AuthMethodJWT, Role: "ci"},
+			wantErr: "requires a token file or environment variable",
+		},
+		{
+			name:    "jwt env empty",
+			auth:    Auth{Method: AuthMethodJWT, Role: "ci", JWTEnv: "TEST_VAULT_JWT_UNSET"},
+			wantErr: "TEST_VAULT_JWT_UNSET is empty",
+		},
+		{
+			name:    "unsupported method",
+			auth:    Auth{Method: "ldap"},
+			wantErr: "unsupported vault auth method",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Explicit auth must never pick up the ambient token
+			t.Setenv("VAULT_TOKEN", "ambient-token")
+			t.Setenv("TEST_VAULT_JWT", "env-jwt")
+
+			logins := map[string]map[string]interface{}{}
+			srv := newFakeVault(t, logins)
+
+			auth := tt.auth
+			vc := &VaultClient{Address: srv.URL, Auth: &auth}
+			err := vc.Init(context.Background())
+
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantToken, vc.Client.Token())
+			if tt.wantPath != "" {
+				assert.Equal(t, tt.wantBody, logins[tt.wantPath])
+			}
+		})
+	}
+}
+
+func TestVaultClient_LoginWithAWSIAM(t *testing.T) {
+	logins := map[string]map[string]interface{}{}
+	srv := newFakeVault(t, logins)
+
+	vc := &VaultClient{
+		Address: srv.URL,
+		Auth: &Auth{
+			Method:         AuthMethodAWS,
+			Role:           "secretsync-ec2",
+			AWSServerID:    "vault.example.com",
+			AWSCredentials: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", ""),
+		},
+	}
+	require.NoError(t, vc.Init(context.Background()))
+	assert.Equal(t, "issued-token", vc.Client.Token())
+
+	body := logins["/v1/auth/aws/login"]
+	require.NotNil(t, body)
+	assert.Equal(t, "secretsync-ec2", body["role"])
+	assert.Equal(t, "POST", body["iam_http_request_method"])
+
+	decode := func(key string) string {
+		raw, err := base64.StdEncoding.DecodeString(body[key].(string))
+		require.NoError(t, err)
+		return string(raw)
+	}
+	assert.Equal(t, "https://sts.amazonaws.com/", decode("iam_request_url"))
+	assert.Equal(t, "Action=GetCallerIdentity&Version=2011-06-15", decode("iam_request_body"))
+
+	var headers map[string][]string
+	require.NoError(t, json.Unmarshal([]byte(decode("iam_request_headers")), &headers))
+	assert.Equal(t, []string{"vault.example.com"}, headers["X-Vault-Aws-Iam-Server-Id"])
+	require.Len(t, headers["Authorization"], 1)
+	assert.Contains(t, headers["Authorization"][0], "Credential=AKIDEXAMPLE/")
+	assert.Contains(t, headers["Authorization"][0], "/us-east-1/sts/aws4_request")
+}
+
+func TestVaultClient_AuthTokenCache(t *testing.T) {
+	var logins int
+	var revoked bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/approle/login":
+			logins++
+			revoked = false
+			_, _ = fmt.Fprintf(w, `{"auth":{"client_token":"token-%d","lease_duration":3600}}`, logins)
+		case "/v1/secret/data/app":
+			if revoked || r.Header.Get("X-Vault-Token") != fmt.Sprintf("token-%d", logins) {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"hunter2"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	vc := &VaultClient{Address: srv.URL, Auth: &Auth{Method: AuthMethodAppRole, RoleID: "rid"}}
+	require.NoError(t, vc.Init(ctx))
+
+	// Calls reuse the token of the first login
+	for range 3 {
+		_, err := vc.GetSecret(ctx, "secret/app")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, logins)
+	assert.WithinDuration(t, time.Now().Add(54*time.Minute), vc.authExpiry, time.Minute)
+
+	// A rejected token is replaced and the call retried
+	revoked = true
+	_, err := vc.GetSecret(ctx, "secret/app")
+	require.NoError(t, err)
+	assert.Equal(t, 2, logins)
+
+	// So is a token past its TTL
+	vc.authExpiry = time.Now().Add(-time.Second)
+	_, err = vc.GetSecret(ctx, "secret/app")
+	require.NoError(t, err)
+	assert.Equal(t, 3, logins)
+	assert.Equal(t, "token-3", vc.Client.Token())
+}
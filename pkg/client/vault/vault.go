@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -47,6 +49,10 @@ type VaultClient struct {
 
 	Role string `yaml:"role,omitempty" json:"role,omitempty"`
 
+	// Auth selects an explicit authentication method. When nil, Login uses the
+	// in-pod service account JWT if present and otherwise VAULT_TOKEN.
+	Auth *Auth `yaml:"-" json:"-"`
+
 	// Configurable traversal limits (0 = use defaults)
 	MaxTraversalDepth        int `yaml:"maxTraversalDepth,omitempty" json:"maxTraversalDepth,omitempty"`
 	MaxSecretsPerMount       int `yaml:"maxSecretsPerMount,omitempty" json:"maxSecretsPerMount,omitempty"`
@@ -56,6 +62,12 @@ type VaultClient struct {
 	logicalClient LogicalClient                  `yaml:"-" json:"-"` // For dependency injection in tests
 	breaker       *circuitbreaker.CircuitBreaker `yaml:"-" json:"-"` // Circuit breaker for API calls
 	breakerOnce   sync.Once                      `yaml:"-" json:"-"`
+
+	// Token from the last explicit Auth login, reused until authExpiry
+	// (zero for tokens that never expire) or until Vault rejects it
+	authMu     sync.Mutex `yaml:"-" json:"-"`
+	authToken  string     `yaml:"-" json:"-"`
+	authExpiry time.Time  `yaml:"-" json:"-"`
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -76,12 +88,19 @@ func (in *VaultClient) DeepCopyInto(out *VaultClient) {
 	out.MaxTraversalDepth = in.MaxTraversalDepth
 	out.MaxSecretsPerMount = in.MaxSecretsPerMount
 	out.QueueCompactionThreshold = in.QueueCompactionThreshold
+	if in.Auth != nil {
+		auth := *in.Auth
+		out.Auth = &auth
+	}
 
 	// Pointers/interfaces copied as-is; breakerOnce intentionally zeroed to avoid copying locks
 	out.Client = in.Client
 	out.logicalClient = in.logicalClient
 	out.breaker = in.breaker
 	out.breakerOnce = sync.Once{}
+	// The copy logs in on its own; the cached Auth token is not shared
+	out.authToken = ""
+	out.authExpiry = time.Time{}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultClient.
@@ -153,6 +172,18 @@ func (vc *VaultClient) GetPath() string {
 // NewClients creates and returns a new vault client with a valid token or error
 func (vc *VaultClient) NewClient(ctx context.Context) (*api.Client, error) {
 	log.Tracef("vault.NewClient")
+	if err := vc.newAPIClient(); err != nil {
+		return vc.Client, err
+	}
+	terr := vc.NewToken(ctx)
+	if terr != nil {
+		return vc.Client, terr
+	}
+	return vc.Client, nil
+}
+
+// newAPIClient creates the underlying API client without authenticating
+func (vc *VaultClient) newAPIClient() error {
 	config := &api.Config{
 		Address: vc.Address,
 		Timeout: 30 * time.Second, // Prevent hung connections
@@ -160,17 +191,13 @@ func (vc *VaultClient) NewClient(ctx context.Context) (*api.Client, error) {
 	var err error
 	vc.Client, err = api.NewClient(config)
 	if err != nil {
-		return vc.Client, err
+		return err
 	}
 	if vc.Namespace != "" {
 		vc.Client.SetNamespace(vc.Namespace)
 	}
-	terr := vc.NewToken(ctx)
-	if terr != nil {
-		return vc.Client, terr
-	}
 	vc.Client.AddHeader("x-vault-sync", "true")
-	return vc.Client, err
+	return nil
 }
 
 // Login creates a vault token with the k8s auth provider
@@ -184,11 +211,15 @@ func (vc *VaultClient) Login(ctx context.Context) error {
 	})
 	l.Trace("vault.Login")
 	if vc.Client == nil {
-		_, err := vc.NewClient(ctx)
-		if err != nil {
+		if err := vc.newAPIClient(); err != nil {
 			return err
 		}
 	}
+	if vc.Auth != nil {
+		vc.authMu.Lock()
+		defer vc.authMu.Unlock()
+		return vc.loginWithAuth(ctx)
+	}
 	var kubeTokenExists bool
 	ktp := "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	if _, err := os.Stat(ktp); !os.IsNotExist(err) {
@@ -245,12 +276,14 @@ func (vc *VaultClient) NewToken(ctx context.Context) error {
 		"path":    vc.Path,
 		"method":  vc.AuthMethod,
 	})
+	if vc.Auth != nil {
+		return vc.cachedLogin(ctx)
+	}
 	l.Trace("vault.NewToken calling Login")
 	if os.Getenv("VAULT_TOKEN") != "" {
 		l.Trace("using VAULT_TOKEN")
 		if vc.Client == nil {
-			_, err := vc.NewClient(ctx)
-			if err != nil {
+			if err := vc.newAPIClient(); err != nil {
 				return err
 			}
 		}
@@ -262,6 +295,35 @@ func (vc *VaultClient) NewToken(ctx context.Context) error {
 	return nil
 }
 
+// cachedLogin logs in with the explicit Auth method unless the token of the
+// last login is still valid
+func (vc *VaultClient) cachedLogin(ctx context.Context) error {
+	vc.authMu.Lock()
+	defer vc.authMu.Unlock()
+	if vc.authToken != "" && (vc.authExpiry.IsZero() || time.Now().Before(vc.authExpiry)) {
+		vc.Client.SetToken(vc.authToken)
+		return nil
+	}
+	if vc.Client == nil {
+		if err := vc.newAPIClient(); err != nil {
+			return err
+		}
+	}
+	return vc.loginWithAuth(ctx)
+}
+
+// retryToken gets a token for retrying a call that failed with err. A cached
+// Auth token is only dropped when Vault rejected it with a 403.
+func (vc *VaultClient) retryToken(ctx context.Context, err error) error {
+	var respErr *api.ResponseError
+	if vc.Auth != nil && errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden {
+		vc.authMu.Lock()
+		vc.authToken = ""
+		vc.authMu.Unlock()
+	}
+	return vc.NewToken(ctx)
+}
+
 func insertSliceString(a []string, index int, value string) []string {
 	if len(a) == index { // nil or empty slice or after last element
 		return append(a, value)
@@ -337,6 +399,47 @@ func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[strin
 	return data, nil
 }
 
+// GetKVSecretVersion reads the KV v2 secret at p with its version, for a
+// following check-and-set write. A missing secret returns nil data and version 0.
+func (vc *VaultClient) GetKVSecretVersion(ctx context.Context, p string) (map[string]interface{}, int, error) {
+	pp := strings.Split(p, "/")
+	if len(pp) < 2 {
+		return nil, 0, errors.New("secret path must be in kv/path/to/secret format")
+	}
+	pp = insertSliceString(pp, 1, "data")
+	dataPath := strings.Join(pp, "/")
+
+	// Ensure circuit breaker is initialized
+	vc.ensureBreaker()
+
+	secret, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+		return vc.Client.Logical().ReadWithContext(ctx, dataPath)
+	})
+	if err != nil {
+		return nil, 0, circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, 0, nil
+	}
+
+	data, _ := secret.Data["data"].(map[string]interface{})
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	var version int
+	switch v := metadata["version"].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid secret version %q: %w", v, err)
+		}
+		version = int(n)
+	case float64:
+		version = int(v)
+	default:
+		return nil, 0, fmt.Errorf("secret version missing from response: %s", p)
+	}
+	return data, version, nil
+}
+
 // GetKVSecret will login and retry secret access on failure
 // to gracefully handle token expiration
 func (vc *VaultClient) GetSecret(ctx context.Context, s string) ([]byte, error) {
@@ -348,7 +451,7 @@ func (vc *VaultClient) GetSecret(ctx context.Context, s string) ([]byte, error)
 	}
 	sec, err = vc.GetKVSecretOnce(ctx, s)
 	if err != nil {
-		terr := vc.NewToken(ctx)
+		terr := vc.retryToken(ctx, err)
 		if terr != nil {
 			return nil, terr
 		}
@@ -421,7 +524,7 @@ func (vc *VaultClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta,
 	}
 	secrets, err = vc.WriteSecretWithLatestCAS(ctx, s, data)
 	if err != nil {
-		terr := vc.NewToken(ctx)
+		terr := vc.retryToken(ctx, err)
 		if terr != nil {
 			return nil, terr
 		}
@@ -475,6 +578,21 @@ func (vc *VaultClient) WriteSecretOnce(ctx context.Context, p string, s map[stri
 	return secrets, nil
 }
 
+// IsCASConflict reports whether err is Vault rejecting a KV v2 write because
+// its check-and-set version did not match the secret's current version
+func IsCASConflict(err error) bool {
+	var respErr *api.ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	for _, msg := range respErr.Errors {
+		if strings.Contains(msg, "check-and-set") {
+			return true
+		}
+	}
+	return false
+}
+
 func (vc *VaultClient) WriteSecretWithLatestCAS(ctx context.Context, p string, s map[string]interface{}) (map[string]interface{}, error) {
 	var secrets map[string]interface{}
 	originalPath := p
@@ -526,10 +644,124 @@ func (vc *VaultClient) WriteSecretWithLatestCAS(ctx context.Context, p string, s
 		}
 	}
 
+	// No metadata means the secret does not exist yet: cas=0 only allows creating it,
+	// so a concurrent writer creating the same path is detected instead of overwritten
+	if err == nil && metadata == nil {
+		created := 0
+		cas = &created
+	}
+
 	// Use the WriteSecretOnce function with the cas value
 	return vc.WriteSecretOnce(ctx, originalPath, s, cas)
 }
 
+// GetSecretCustomMetadata returns the KV v2 custom_metadata of the secret at p.
+// found is false when the secret has no metadata, i.e. it has never been written.
+func (vc *VaultClient) GetSecretCustomMetadata(ctx context.Context, p string) (map[string]string, bool, error) {
+	pp := strings.Split(p, "/")
+	if len(pp) < 2 {
+		return nil, false, errors.New("secret path must be in kv/path/to/secret format")
+	}
+	pp = insertSliceString(pp, 1, "metadata")
+	metadataPath := strings.Join(pp, "/")
+
+	// Ensure circuit breaker is initialized
+	vc.ensureBreaker()
+
+	metadata, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+		return vc.Client.Logical().ReadWithContext(ctx, metadataPath)
+	})
+	if err != nil {
+		return nil, false, circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
+	}
+	if metadata == nil || metadata.Data == nil {
+		return nil, false, nil
+	}
+
+	custom := make(map[string]string)
+	if raw, ok := metadata.Data["custom_metadata"].(map[string]interface{}); ok {
+		for k, v := range raw {
+			if str, ok := v.(string); ok {
+				custom[k] = str
+			}
+		}
+	}
+	return custom, true, nil
+}
+
+// GetKVSecretCurrentVersion returns the current version of the KV v2 secret at
+// p from its metadata, without reading its data. A secret that was never
+// written, or whose current version is deleted or destroyed, returns 0.
+func (vc *VaultClient) GetKVSecretCurrentVersion(ctx context.Context, p string) (int, error) {
+	pp := strings.Split(p, "/")
+	if len(pp) < 2 {
+		return 0, errors.New("secret path must be in kv/path/to/secret format")
+	}
+	pp = insertSliceString(pp, 1, "metadata")
+	metadataPath := strings.Join(pp, "/")
+
+	// Ensure circuit breaker is initialized
+	vc.ensureBreaker()
+
+	metadata, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+		return vc.Client.Logical().ReadWithContext(ctx, metadataPath)
+	})
+	if err != nil {
+		return 0, circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
+	}
+	if metadata == nil || metadata.Data == nil {
+		return 0, nil
+	}
+
+	var version int
+	switch v := metadata.Data["current_version"].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid secret version %q: %w", v, err)
+		}
+		version = int(n)
+	case float64:
+		version = int(v)
+	default:
+		return 0, fmt.Errorf("secret version missing from metadata: %s", p)
+	}
+
+	if versions, ok := metadata.Data["versions"].(map[string]interface{}); ok {
+		if current, ok := versions[strconv.Itoa(version)].(map[string]interface{}); ok {
+			deleted, _ := current["deletion_time"].(string)
+			destroyed, _ := current["destroyed"].(bool)
+			if deleted != "" || destroyed {
+				return 0, nil
+			}
+		}
+	}
+	return version, nil
+}
+
+// SetSecretCustomMetadata replaces the KV v2 custom_metadata of the secret at p
+func (vc *VaultClient) SetSecretCustomMetadata(ctx context.Context, p string, custom map[string]string) error {
+	pp := strings.Split(p, "/")
+	if len(pp) < 2 {
+		return errors.New("secret path must be in kv/path/to/secret format")
+	}
+	pp = insertSliceString(pp, 1, "metadata")
+	metadataPath := strings.Join(pp, "/")
+
+	// Ensure circuit breaker is initialized
+	vc.ensureBreaker()
+
+	_, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+		return vc.Client.Logical().WriteWithContext(ctx, metadataPath, map[string]interface{}{
+			"custom_metadata": custom,
+		})
+	})
+	if err != nil {
+		return circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
+	}
+	return nil
+}
+
 // DeleteSecret deletes a secret from path p
 func (vc *VaultClient) DeleteSecret(ctx context.Context, p string) error {
 	l := log.WithFields(log.Fields{
@@ -874,7 +1106,7 @@ func (vc *VaultClient) ListSecrets(ctx context.Context, p string) ([]string, err
 	}
 	keys, err = vc.ListSecretsOnce(ctx, p)
 	if err != nil {
-		terr := vc.NewToken(ctx)
+		terr := vc.retryToken(ctx, err)
 		if terr != nil {
 			return keys, terr
 		}
@@ -3,6 +3,8 @@ package vault
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -416,6 +418,31 @@ func TestVaultClient_WriteSecretOnce(t *testing.T) {
 	}
 }
 
+func TestIsCASConflict(t *testing.T) {
+	casErr := &api.ResponseError{
+		StatusCode: 400,
+		Errors:     []string{"check-and-set parameter did not match the current version"},
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "check-and-set mismatch", err: casErr, want: true},
+		{name: "wrapped", err: fmt.Errorf("failed to write: %w", casErr), want: true},
+		{name: "other bad request", err: &api.ResponseError{StatusCode: 400, Errors: []string{"no data provided"}}},
+		{name: "forbidden", err: &api.ResponseError{StatusCode: 403, Errors: []string{"check-and-set denied"}}},
+		{name: "plain error mentioning check-and-set", err: errors.New("check-and-set parameter did not match")},
+		{name: "nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsCASConflict(tt.err))
+		})
+	}
+}
+
 func TestVaultClient_DeleteSecret(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,231 @@
+// Package controller reconciles SecretSync custom resources: each resource is
+// synced from its Vault source to its destinations on an interval, and the
+// outcome is recorded in its status.
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// DefaultInterval is how often a SecretSync is re-synced when no interval is set
+const DefaultInterval = 5 * time.Minute
+
+// defaultURLSecretKey is the Secret key holding a Slack webhook URL when
+// urlSecretKey is not set
+const defaultURLSecretKey = "url"
+
+// SyncFunc syncs one SecretSync resource
+type SyncFunc func(ctx context.Context, ss *v1alpha1.SecretSync) (*pipeline.SecretSyncResult, error)
+
+// SecretSyncReconciler reconciles SecretSync resources
+type SecretSyncReconciler struct {
+	client.Client
+
+	// Interval between syncs of a resource (default DefaultInterval)
+	Interval time.Duration
+	// Sync runs the sync (default pipeline.SyncSecretSync)
+	Sync SyncFunc
+}
+
+// +kubebuilder:rbac:groups=secretsync.extendeddata.dev,resources=secretsyncs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secretsync.extendeddata.dev,resources=secretsyncs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile syncs a SecretSync and records the outcome in its status.
+// Suspended resources are left alone until they are resumed.
+func (r *SecretSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.WithFields(log.Fields{
+		"action":     "SecretSyncReconciler.Reconcile",
+		"secretsync": req.NamespacedName.String(),
+	})
+
+	var ss v1alpha1.SecretSync
+	if err := r.Get(ctx, req.NamespacedName, &ss); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if ss.Spec.Suspend != nil && *ss.Spec.Suspend {
+		l.Debug("SecretSync is suspended")
+		if ss.Status.Status == v1alpha1.SecretSyncStatusSuspended {
+			return ctrl.Result{}, nil
+		}
+		ss.Status.Status = v1alpha1.SecretSyncStatusSuspended
+		return ctrl.Result{}, r.Status().Update(ctx, &ss)
+	}
+
+	syncFn := r.Sync
+	if syncFn == nil {
+		syncFn = pipeline.SyncSecretSync
+	}
+	result, syncErr := syncFn(ctx, &ss)
+	if syncErr == nil && !result.Succeeded() {
+		syncErr = resultsError(result.Results)
+	}
+
+	var report notify.Report
+	if result != nil {
+		report = result.Report
+		ss.Status.SyncDestinations = result.Synced()
+	} else {
+		report = notify.Report{
+			Operation: "secretsync",
+			Error:     syncErr.Error(),
+			Events:    notify.Events(false, diff.ChangeSummary{}),
+		}
+	}
+
+	switch {
+	case syncErr != nil:
+		l.WithError(syncErr).Error("SecretSync failed")
+		ss.Status.Status = v1alpha1.SecretSyncStatusFailed
+	case ss.Spec.DryRun != nil && *ss.Spec.DryRun:
+		ss.Status.Status = v1alpha1.SecretSyncStatusDryRun
+	default:
+		ss.Status.Status = v1alpha1.SecretSyncStatusSynced
+		ss.Status.LastSyncTime = metav1.Now()
+		ss.Status.Hash = result.Hash
+		l.WithFields(log.Fields{
+			"destinations": ss.Status.SyncDestinations,
+			"hash":         result.Hash,
+		}).Info("SecretSync synced")
+	}
+
+	if err := r.Status().Update(ctx, &ss); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+	}
+
+	r.notify(ctx, &ss, report)
+
+	if syncErr != nil {
+		// Returning the error requeues with backoff
+		return ctrl.Result{}, syncErr
+	}
+	return ctrl.Result{RequeueAfter: r.interval()}, nil
+}
+
+func (r *SecretSyncReconciler) interval() time.Duration {
+	if r.Interval <= 0 {
+		return DefaultInterval
+	}
+	return r.Interval
+}
+
+// resultsError joins the errors of failed destinations
+func resultsError(results []pipeline.Result) error {
+	var errs []error
+	for _, res := range results {
+		if !res.Success {
+			errs = append(errs, fmt.Errorf("%s: %w", res.Target, res.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notify sends the report to the resource's notification sinks. Delivery
+// failures are logged and do not fail the reconcile.
+func (r *SecretSyncReconciler) notify(ctx context.Context, ss *v1alpha1.SecretSync, report notify.Report) {
+	if len(ss.Spec.Notifications) == 0 {
+		return
+	}
+	l := log.WithFields(log.Fields{
+		"action":     "SecretSyncReconciler.notify",
+		"secretsync": ss.Namespace + "/" + ss.Name,
+	})
+
+	specs, err := r.resolveNotifications(ctx, ss)
+	if err != nil {
+		l.WithError(err).Warn("Failed to resolve notifications")
+		return
+	}
+	var tmpl string
+	if ss.Spec.NotificationsTemplate != nil {
+		tmpl = *ss.Spec.NotificationsTemplate
+	}
+
+	notifier := notify.New(specs, tmpl)
+	if err := notifier.Validate(); err != nil {
+		l.WithError(err).Warn("Invalid notifications")
+		return
+	}
+	if err := notifier.Notify(ctx, report); err != nil {
+		l.WithError(err).Warn("Failed to deliver notifications")
+	}
+}
+
+// resolveNotifications returns copies of the notification specs with webhook
+// headerSecret and Slack urlSecret read from Secrets in the resource's namespace
+func (r *SecretSyncReconciler) resolveNotifications(ctx context.Context, ss *v1alpha1.SecretSync) ([]*v1alpha1.NotificationSpec, error) {
+	specs := make([]*v1alpha1.NotificationSpec, 0, len(ss.Spec.Notifications))
+	for i, n := range ss.Spec.Notifications {
+		n = n.DeepCopy()
+		if n == nil {
+			continue
+		}
+
+		if w := n.Webhook; w != nil && w.HeaderSecret != nil {
+			secret, err := r.secret(ctx, ss.Namespace, *w.HeaderSecret)
+			if err != nil {
+				return nil, fmt.Errorf("notifications[%d]: %w", i, err)
+			}
+			if w.Headers == nil {
+				w.Headers = make(map[string]string, len(secret.Data))
+			}
+			for k, v := range secret.Data {
+				w.Headers[k] = string(v)
+			}
+			w.HeaderSecret = nil
+		}
+
+		if s := n.Slack; s != nil && s.URLSecret != nil {
+			secret, err := r.secret(ctx, ss.Namespace, *s.URLSecret)
+			if err != nil {
+				return nil, fmt.Errorf("notifications[%d]: %w", i, err)
+			}
+			key := defaultURLSecretKey
+			if s.URLSecretKey != nil {
+				key = *s.URLSecretKey
+			}
+			url, ok := secret.Data[key]
+			if !ok {
+				return nil, fmt.Errorf("notifications[%d]: secret %s has no key %q", i, *s.URLSecret, key)
+			}
+			u := string(url)
+			s.URL, s.URLSecret, s.URLSecretKey = &u, nil, nil
+		}
+
+		specs = append(specs, n)
+	}
+	return specs, nil
+}
+
+func (r *SecretSyncReconciler) secret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+		return nil, fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	return &secret, nil
+}
+
+// SetupWithManager registers the reconciler with a manager. Status-only
+// updates do not trigger a sync; the interval requeue does.
+func (r *SecretSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.SecretSync{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
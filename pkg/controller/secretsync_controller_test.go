@@ -0,0 +1,302 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+func testScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	return scheme
+}
+
+func newSecretSync(name string) *v1alpha1.SecretSync {
+	return &v1alpha1.SecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: name},
+		Spec: v1alpha1.SecretSyncSpec{
+			Source: &vault.VaultClient{Address: "https://vault.example.com", Path: "kv/team/"},
+			Dest:   []*v1alpha1.StoreConfig{{Vault: &vault.VaultClient{Address: "https://vault.example.com", Path: "kv/copy"}}},
+		},
+	}
+}
+
+// syncResult returns a sync function reporting one destination per result
+func syncResult(results ...pipeline.Result) SyncFunc {
+	return func(ctx context.Context, ss *v1alpha1.SecretSync) (*pipeline.SecretSyncResult, error) {
+		res := &pipeline.SecretSyncResult{Results: results, Hash: "abc123"}
+		success := res.Succeeded()
+		res.Report = notify.Report{Operation: "secretsync", Success: success, Events: notify.Events(success, diff.ChangeSummary{})}
+		return res, nil
+	}
+}
+
+func reconcile(t *testing.T, r *SecretSyncReconciler, name string) (ctrl.Result, error, *v1alpha1.SecretSync) {
+	t.Helper()
+	key := types.NamespacedName{Namespace: "team", Name: name}
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+
+	var ss v1alpha1.SecretSync
+	require.NoError(t, r.Get(context.Background(), key, &ss))
+	return res, err, &ss
+}
+
+func TestReconcile(t *testing.T) {
+	suspended := newSecretSync("suspended")
+	suspend := true
+	suspended.Spec.Suspend = &suspend
+
+	dryRun := newSecretSync("dry-run")
+	enabled := true
+	dryRun.Spec.DryRun = &enabled
+
+	tests := []struct {
+		name        string
+		ss          *v1alpha1.SecretSync
+		sync        SyncFunc
+		wantStatus  string
+		wantDests   int
+		wantHash    string
+		wantErr     string
+		wantSynced  bool
+		wantRequeue time.Duration
+	}{
+		{
+			name:        "synced",
+			ss:          newSecretSync("ok"),
+			sync:        syncResult(pipeline.Result{Success: true}, pipeline.Result{Success: true}),
+			wantStatus:  v1alpha1.SecretSyncStatusSynced,
+			wantDests:   2,
+			wantHash:    "abc123",
+			wantSynced:  true,
+			wantRequeue: time.Minute,
+		},
+		{
+			name:        "dry run",
+			ss:          dryRun,
+			sync:        syncResult(pipeline.Result{Success: true}),
+			wantStatus:  v1alpha1.SecretSyncStatusDryRun,
+			wantDests:   1,
+			wantRequeue: time.Minute,
+		},
+		{
+			name:       "destination failure",
+			ss:         newSecretSync("partial"),
+			sync:       syncResult(pipeline.Result{Success: true}, pipeline.Result{Target: "team/partial[1]", Error: errors.New("denied")}),
+			wantStatus: v1alpha1.SecretSyncStatusFailed,
+			wantDests:  1,
+			wantErr:    "team/partial[1]: denied",
+		},
+		{
+			name: "source failure",
+			ss:   newSecretSync("broken"),
+			sync: func(context.Context, *v1alpha1.SecretSync) (*pipeline.SecretSyncResult, error) {
+				return nil, errors.New("permission denied")
+			},
+			wantStatus: v1alpha1.SecretSyncStatusFailed,
+			wantErr:    "permission denied",
+		},
+		{
+			name: "suspended",
+			ss:   suspended,
+			sync: func(context.Context, *v1alpha1.SecretSync) (*pipeline.SecretSyncResult, error) {
+				t.Fatal("suspended SecretSync was synced")
+				return nil, nil
+			},
+			wantStatus: v1alpha1.SecretSyncStatusSuspended,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme(t)).
+				WithObjects(tt.ss).
+				WithStatusSubresource(&v1alpha1.SecretSync{}).
+				Build()
+			r := &SecretSyncReconciler{Client: c, Interval: time.Minute, Sync: tt.sync}
+
+			res, err, ss := reconcile(t, r, tt.ss.Name)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantRequeue, res.RequeueAfter)
+			assert.Equal(t, tt.wantStatus, ss.Status.Status)
+			assert.Equal(t, tt.wantDests, ss.Status.SyncDestinations)
+			assert.Equal(t, tt.wantHash, ss.Status.Hash)
+			assert.Equal(t, tt.wantSynced, !ss.Status.LastSyncTime.IsZero())
+		})
+	}
+}
+
+func TestReconcile_NotFound(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).Build()
+	r := &SecretSyncReconciler{Client: c}
+
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team", Name: "gone"}})
+	assert.NoError(t, err)
+	assert.Equal(t, ctrl.Result{}, res)
+}
+
+func TestReconcile_Notifications(t *testing.T) {
+	var body []byte
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	ss := newSecretSync("notify")
+	headerSecret := "hook-headers"
+	ss.Spec.Notifications = []*v1alpha1.NotificationSpec{{
+		Webhook: &v1alpha1.WebhookNotification{URL: srv.URL, HeaderSecret: &headerSecret},
+	}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: headerSecret},
+		Data:       map[string][]byte{"Authorization": []byte("Bearer s3cr3t")},
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(testScheme(t)).
+		WithObjects(ss, secret).
+		WithStatusSubresource(&v1alpha1.SecretSync{}).
+		Build()
+	r := &SecretSyncReconciler{Client: c, Sync: syncResult(pipeline.Result{Success: true})}
+
+	_, err, _ := reconcile(t, r, "notify")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer s3cr3t", auth)
+
+	var report notify.Report
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.True(t, report.Success)
+	assert.Equal(t, []v1alpha1.NotificationEvent{"success"}, report.Events)
+}
+
+func TestResolveNotifications(t *testing.T) {
+	urlSecret, key := "slack", "webhook"
+	ss := newSecretSync("slack")
+	ss.Spec.Notifications = []*v1alpha1.NotificationSpec{{
+		Slack: &v1alpha1.SlackNotification{URLSecret: &urlSecret, URLSecretKey: &key},
+	}}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: urlSecret},
+		Data:       map[string][]byte{"webhook": []byte("https://hooks.slack.com/services/x")},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(testScheme(t)).WithObjects(secret).Build()
+	r := &SecretSyncReconciler{Client: c}
+
+	specs, err := r.resolveNotifications(context.Background(), ss)
+	require.NoError(t, err)
+	require.Len(t, specs, 1)
+	assert.Equal(t, "https://hooks.slack.com/services/x", *specs[0].Slack.URL)
+	assert.Nil(t, specs[0].Slack.URLSecret)
+	// The resource itself is not modified
+	assert.Nil(t, ss.Spec.Notifications[0].Slack.URL)
+
+	key = "missing"
+	_, err = r.resolveNotifications(context.Background(), ss)
+	assert.ErrorContains(t, err, `has no key "missing"`)
+}
+
+// TestSecretSyncReconciler_EnvTest runs the controller against a real API
+// server. It needs the envtest binaries (setup-envtest use -p path).
+func TestSecretSyncReconciler_EnvTest(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS not set")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "deploy", "charts", "secretsync", "charts", "secretsync-operator", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, env.Stop()) }()
+
+	scheme := testScheme(t)
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:  scheme,
+		Metrics: metricsserver.Options{BindAddress: "0"},
+	})
+	require.NoError(t, err)
+
+	synced := make(chan string, 10)
+	r := &SecretSyncReconciler{
+		Client:   mgr.GetClient(),
+		Interval: time.Hour,
+		Sync: func(ctx context.Context, ss *v1alpha1.SecretSync) (*pipeline.SecretSyncResult, error) {
+			synced <- ss.Name
+			return syncResult(pipeline.Result{Success: true})(ctx, ss)
+		},
+	}
+	require.NoError(t, r.SetupWithManager(mgr))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { assert.NoError(t, mgr.Start(ctx)) }()
+
+	c := mgr.GetClient()
+	require.NoError(t, c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}}))
+	ss := newSecretSync("envtest")
+	require.NoError(t, c.Create(ctx, ss))
+
+	select {
+	case name := <-synced:
+		assert.Equal(t, "envtest", name)
+	case <-time.After(30 * time.Second):
+		t.Fatal("SecretSync was not reconciled")
+	}
+
+	key := types.NamespacedName{Namespace: "team", Name: "envtest"}
+	require.Eventually(t, func() bool {
+		var got v1alpha1.SecretSync
+		if err := c.Get(ctx, key, &got); err != nil {
+			return false
+		}
+		return got.Status.Status == v1alpha1.SecretSyncStatusSynced && got.Status.SyncDestinations == 1 && got.Status.Hash == "abc123"
+	}, 30*time.Second, 100*time.Millisecond)
+
+	// Suspending stops syncs and is reflected in the status
+	var got v1alpha1.SecretSync
+	require.NoError(t, c.Get(ctx, key, &got))
+	suspend := true
+	got.Spec.Suspend = &suspend
+	require.NoError(t, c.Update(ctx, &got))
+	require.Eventually(t, func() bool {
+		var got v1alpha1.SecretSync
+		return c.Get(ctx, key, &got) == nil && got.Status.Status == v1alpha1.SecretSyncStatusSuspended
+	}, 30*time.Second, 100*time.Millisecond)
+	assert.Empty(t, synced)
+}
@@ -37,6 +37,13 @@ type SecretChange struct {
 	KeysRemoved  []string `json:"keys_removed,omitempty"`
 	KeysModified []string `json:"keys_modified,omitempty"`
 
+	// MergeStrategies maps keys resolved by a non-default merge strategy to
+	// the strategy, e.g. "cidrs": "list:union" (merge diffs only)
+	MergeStrategies map[string]string `json:"merge_strategies,omitempty"`
+
+	// Tombstoned lists keys deleted by the target's remove tombstones (merge diffs only)
+	Tombstoned []string `json:"tombstoned,omitempty"`
+
 	// Current and desired states (values redacted by default)
 	CurrentKeys []string `json:"current_keys,omitempty"`
 	DesiredKeys []string `json:"desired_keys,omitempty"`
@@ -377,6 +384,12 @@ func formatHuman(diff *PipelineDiff) string {
 					sb.WriteString(fmt.Sprintf("    ~ keys: %v\n", c.KeysModified))
 				}
 			}
+			if len(c.MergeStrategies) > 0 {
+				sb.WriteString(fmt.Sprintf("    strategies: %s\n", formatMergeStrategies(c.MergeStrategies)))
+			}
+			if len(c.Tombstoned) > 0 {
+				sb.WriteString(fmt.Sprintf("    tombstoned: %s\n", strings.Join(c.Tombstoned, ", ")))
+			}
 		}
 		sb.WriteString("\n")
 	}
@@ -384,6 +397,20 @@ func formatHuman(diff *PipelineDiff) string {
 	return sb.String()
 }
 
+// formatMergeStrategies formats merge strategies as sorted key=strategy pairs
+func formatMergeStrategies(strategies map[string]string) string {
+	keys := make([]string, 0, len(strategies))
+	for k := range strategies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+strategies[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
 func formatGitHub(diff *PipelineDiff) string {
 	var sb strings.Builder
 
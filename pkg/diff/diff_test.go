@@ -231,6 +231,51 @@ func TestFormatDiff_Human(t *testing.T) {
 	}
 }
 
+func TestFormatDiff_HumanMergeStrategies(t *testing.T) {
+	diff := &PipelineDiff{
+		Targets: []TargetDiff{
+			{
+				Target: "Serverless_Stg",
+				Changes: []SecretChange{
+					{Path: "network", ChangeType: ChangeTypeModified, MergeStrategies: map[string]string{"hosts": "list:union", "cidrs": "list:replace"}},
+				},
+				Summary: ChangeSummary{Modified: 1, Total: 1},
+			},
+		},
+		Summary: ChangeSummary{Modified: 1, Total: 1},
+	}
+
+	output := FormatDiff(diff, OutputFormatHuman)
+
+	if !strings.Contains(output, "~ network") {
+		t.Error("expected modified secret")
+	}
+	if !strings.Contains(output, "strategies: cidrs=list:replace, hosts=list:union") {
+		t.Error("expected merge strategies")
+	}
+}
+
+func TestFormatDiff_HumanTombstoned(t *testing.T) {
+	diff := &PipelineDiff{
+		Targets: []TargetDiff{
+			{
+				Target: "Serverless_Stg",
+				Changes: []SecretChange{
+					{Path: "network", ChangeType: ChangeTypeModified, Tombstoned: []string{"legacy"}},
+				},
+				Summary: ChangeSummary{Modified: 1, Total: 1},
+			},
+		},
+		Summary: ChangeSummary{Modified: 1, Total: 1},
+	}
+
+	output := FormatDiff(diff, OutputFormatHuman)
+
+	if !strings.Contains(output, "tombstoned: legacy") {
+		t.Error("expected tombstoned keys")
+	}
+}
+
 func TestFormatDiff_JSON(t *testing.T) {
 	diff := &PipelineDiff{
 		Summary: ChangeSummary{Added: 1, Total: 1},
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+)
+
+// defaultSMTPPort is used when the sink does not set a port
+const defaultSMTPPort = 25
+
+// sendEmail sends the rendered message over SMTP, upgrading with STARTTLS when
+// the server offers it and authenticating when a username is set
+func (n *Notifier) sendEmail(ctx context.Context, e *v1alpha1.EmailNotification, report Report) error {
+	subject, err := render(report, e.Subject, DefaultSubject)
+	if err != nil {
+		return err
+	}
+	body, err := render(report, e.Body, n.template, DefaultTemplate)
+	if err != nil {
+		return err
+	}
+
+	var recipients []string
+	for _, to := range strings.Split(e.To, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			recipients = append(recipients, to)
+		}
+	}
+
+	port := e.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(e.Host, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		// #nosec G402 -- skipping verification is an explicit per-sink opt-in
+		if err := c.StartTLS(&tls.Config{ServerName: e.Host, InsecureSkipVerify: e.InsecureSkipVerify}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(e.From); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, to := range recipients {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		e.From, strings.Join(recipients, ", "), headerValue(subject), time.Now().Format(time.RFC1123Z), body)
+	if _, err := w.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return c.Quit()
+}
+
+// headerValue keeps a rendered subject on one header line
+func headerValue(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,273 @@
+// Package notify delivers pipeline run reports to webhooks, Slack incoming
+// webhooks and SMTP mail, as configured by SecretSync NotificationSpecs.
+//
+// Reports carry target names, counts, secret paths and key names only; secret
+// values are never part of a report, so no template can render them.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultTemplate renders the message for Slack and email when neither the sink
+// nor the notifier sets a template
+const DefaultTemplate = `SecretSync {{ .Operation }} {{ if .Success }}succeeded{{ else }}failed{{ end }}{{ if .DryRun }} (dry run){{ end }}: ` +
+	`{{ .Summary.Added }} added, {{ .Summary.Modified }} modified, {{ .Summary.Removed }} removed across {{ len .Targets }} target results` +
+	`{{ range .Targets }}{{ if not .Success }}
+- {{ .Target }} ({{ .Phase }}): {{ .Error }}{{ end }}{{ end }}`
+
+// DefaultSubject is the email subject when the sink does not set one
+const DefaultSubject = `SecretSync {{ .Operation }} {{ if .Success }}succeeded{{ else }}failed{{ end }}`
+
+// defaultTimeout bounds each delivery
+const defaultTimeout = 10 * time.Second
+
+// Change is one secret change, without values or value hashes
+type Change struct {
+	Path         string          `json:"path"`
+	ChangeType   diff.ChangeType `json:"change_type"`
+	KeysAdded    []string        `json:"keys_added,omitempty"`
+	KeysRemoved  []string        `json:"keys_removed,omitempty"`
+	KeysModified []string        `json:"keys_modified,omitempty"`
+}
+
+// TargetReport is the outcome of one target phase
+type TargetReport struct {
+	Target           string        `json:"target"`
+	Phase            string        `json:"phase"`
+	Success          bool          `json:"success"`
+	Error            string        `json:"error,omitempty"`
+	Duration         time.Duration `json:"duration"`
+	SecretsProcessed int           `json:"secrets_processed"`
+	SecretsRemoved   int           `json:"secrets_removed"`
+	SecretsFiltered  int           `json:"secrets_filtered"`
+	Changes          []Change      `json:"changes,omitempty"`
+}
+
+// Report describes a pipeline run. It is the data passed to templates and the
+// default webhook body.
+type Report struct {
+	Events    []v1alpha1.NotificationEvent `json:"events"`
+	Operation string                       `json:"operation"`
+	Success   bool                         `json:"success"`
+	DryRun    bool                         `json:"dry_run"`
+	RequestID string                       `json:"request_id,omitempty"`
+	Error     string                       `json:"error,omitempty"`
+	Summary   diff.ChangeSummary           `json:"summary"`
+	Targets   []TargetReport               `json:"targets"`
+}
+
+// NewChanges converts diff changes, dropping values and hashes. Unchanged
+// secrets are omitted.
+func NewChanges(changes []diff.SecretChange) []Change {
+	var out []Change
+	for _, c := range changes {
+		if c.ChangeType == diff.ChangeTypeUnchanged {
+			continue
+		}
+		out = append(out, Change{
+			Path:         c.Path,
+			ChangeType:   c.ChangeType,
+			KeysAdded:    c.KeysAdded,
+			KeysRemoved:  c.KeysRemoved,
+			KeysModified: c.KeysModified,
+		})
+	}
+	return out
+}
+
+// Events returns the events a run emits: success or failure, plus changes
+// when the summary has any
+func Events(success bool, summary diff.ChangeSummary) []v1alpha1.NotificationEvent {
+	events := []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}
+	if success {
+		events[0] = v1alpha1.NotificationEventSyncSuccess
+	}
+	if summary.HasChanges() {
+		events = append(events, v1alpha1.NotificationEventChangesDetected)
+	}
+	return events
+}
+
+// templateFuncs are available in notification templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Notifier sends reports to every sink subscribed to one of the report's events
+type Notifier struct {
+	specs    []*v1alpha1.NotificationSpec
+	template string
+	client   *http.Client
+}
+
+// New creates a notifier. tmpl is the shared message template used by sinks
+// without their own body; empty means DefaultTemplate.
+func New(specs []*v1alpha1.NotificationSpec, tmpl string) *Notifier {
+	return &Notifier{
+		specs:    specs,
+		template: tmpl,
+		client:   &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// Validate checks that every sink has a destination and that every template parses
+func (n *Notifier) Validate() error {
+	if _, err := parse(n.template); err != nil {
+		return fmt.Errorf("notifications template: %w", err)
+	}
+	for i, spec := range n.specs {
+		if err := validateSpec(spec); err != nil {
+			return fmt.Errorf("notifications[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateSpec(spec *v1alpha1.NotificationSpec) error {
+	if spec == nil {
+		return errors.New("empty notification")
+	}
+
+	var bodies []string
+	set := 0
+	if w := spec.Webhook; w != nil {
+		set++
+		if w.URL == "" {
+			return errors.New("webhook.url is required")
+		}
+		bodies = append(bodies, w.Body)
+	}
+	if s := spec.Slack; s != nil {
+		set++
+		if s.URL == nil || *s.URL == "" {
+			return errors.New("slack.url is required")
+		}
+		bodies = append(bodies, s.Body)
+	}
+	if e := spec.Email; e != nil {
+		set++
+		if e.Host == "" || e.To == "" || e.From == "" {
+			return errors.New("email.host, email.to and email.from are required")
+		}
+		bodies = append(bodies, e.Body, e.Subject)
+	}
+	if set != 1 {
+		return errors.New("exactly one of webhook, slack or email must be configured")
+	}
+
+	for _, body := range bodies {
+		if _, err := parse(body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse parses a template; an empty text parses as nil
+func parse(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New("notification").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+}
+
+// render executes the first non-empty template of texts against the report
+func render(report Report, texts ...string) (string, error) {
+	for _, text := range texts {
+		if text == "" {
+			continue
+		}
+		tmpl, err := parse(text)
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, report); err != nil {
+			return "", fmt.Errorf("failed to render template: %w", err)
+		}
+		return buf.String(), nil
+	}
+	return "", nil
+}
+
+// subscribed reports whether a sink listens for any of the events.
+// A sink without events receives every event.
+func subscribed(sinkEvents, events []v1alpha1.NotificationEvent) bool {
+	if len(sinkEvents) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if slices.Contains(sinkEvents, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribes reports whether any sink listens for the event
+func (n *Notifier) Subscribes(event v1alpha1.NotificationEvent) bool {
+	events := []v1alpha1.NotificationEvent{event}
+	for _, spec := range n.specs {
+		if spec == nil {
+			continue
+		}
+		switch {
+		case spec.Webhook != nil && subscribed(spec.Webhook.Events, events),
+			spec.Slack != nil && subscribed(spec.Slack.Events, events),
+			spec.Email != nil && subscribed(spec.Email.Events, events):
+			return true
+		}
+	}
+	return false
+}
+
+// Notify delivers the report to every subscribed sink. A failing sink does not
+// stop the others; all delivery errors are returned joined.
+func (n *Notifier) Notify(ctx context.Context, report Report) error {
+	l := log.WithFields(log.Fields{
+		"action": "Notifier.Notify",
+		"events": report.Events,
+	})
+
+	var errs []error
+	for i, spec := range n.specs {
+		if spec == nil {
+			continue
+		}
+
+		var err error
+		switch {
+		case spec.Webhook != nil && subscribed(spec.Webhook.Events, report.Events):
+			err = n.sendWebhook(ctx, spec.Webhook, report)
+		case spec.Slack != nil && subscribed(spec.Slack.Events, report.Events):
+			err = n.sendSlack(ctx, spec.Slack, report)
+		case spec.Email != nil && subscribed(spec.Email.Events, report.Events):
+			err = n.sendEmail(ctx, spec.Email, report)
+		default:
+			continue
+		}
+		if err != nil {
+			l.WithError(err).WithField("notification", i).Warn("Failed to send notification")
+			errs = append(errs, fmt.Errorf("notifications[%d]: %w", i, err))
+			continue
+		}
+		l.WithField("notification", i).Debug("Notification sent")
+	}
+	return errors.Join(errs...)
+}
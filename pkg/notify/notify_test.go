@@ -0,0 +1,281 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() Report {
+	summary := diff.ChangeSummary{Added: 1, Modified: 1, Total: 2}
+	return Report{
+		Events:    Events(true, summary),
+		Operation: "pipeline",
+		Success:   true,
+		RequestID: "req-1",
+		Summary:   summary,
+		Targets: []TargetReport{{
+			Target:           "Staging",
+			Phase:            "sync",
+			Success:          true,
+			SecretsProcessed: 2,
+			Changes: NewChanges([]diff.SecretChange{
+				{Path: "api", ChangeType: diff.ChangeTypeAdded, DesiredValues: map[string]interface{}{"token": "s3cr3t"}},
+				{Path: "db", ChangeType: diff.ChangeTypeModified, KeysModified: []string{"password"}, CurrentValues: map[string]interface{}{"password": "old-value"}},
+				{Path: "same", ChangeType: diff.ChangeTypeUnchanged},
+			}),
+		}},
+	}
+}
+
+// capture records the requests received by an httptest server
+type capture struct {
+	method string
+	header http.Header
+	body   string
+}
+
+func newServer(t *testing.T, status int) (*httptest.Server, *capture) {
+	t.Helper()
+	c := &capture{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		c.method, c.header, c.body = r.Method, r.Header, string(b)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, c
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		success bool
+		summary diff.ChangeSummary
+		want    []v1alpha1.NotificationEvent
+	}{
+		{"success without changes", true, diff.ChangeSummary{Unchanged: 3}, []v1alpha1.NotificationEvent{"success"}},
+		{"success with changes", true, diff.ChangeSummary{Removed: 1}, []v1alpha1.NotificationEvent{"success", "changes"}},
+		{"failure", false, diff.ChangeSummary{}, []v1alpha1.NotificationEvent{"failure"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Events(tt.success, tt.summary))
+		})
+	}
+}
+
+func TestNotify_Webhook(t *testing.T) {
+	t.Run("default body is the report without values", func(t *testing.T) {
+		srv, got := newServer(t, http.StatusOK)
+		n := New([]*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{
+			URL:     srv.URL,
+			Headers: map[string]string{"Authorization": "Bearer abc"},
+		}}}, "")
+
+		require.NoError(t, n.Notify(context.Background(), testReport()))
+		assert.Equal(t, http.MethodPost, got.method)
+		assert.Equal(t, "Bearer abc", got.header.Get("Authorization"))
+		assert.NotContains(t, got.body, "s3cr3t")
+		assert.NotContains(t, got.body, "old-value")
+
+		var report Report
+		require.NoError(t, json.Unmarshal([]byte(got.body), &report))
+		assert.Equal(t, "req-1", report.RequestID)
+		require.Len(t, report.Targets, 1)
+		assert.Len(t, report.Targets[0].Changes, 2)
+		assert.Equal(t, []string{"password"}, report.Targets[0].Changes[1].KeysModified)
+	})
+
+	t.Run("custom template and method", func(t *testing.T) {
+		srv, got := newServer(t, http.StatusOK)
+		n := New([]*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{
+			URL:    srv.URL,
+			Method: "put",
+			Body:   `{"added":{{ .Summary.Added }},"targets":{{ json .Targets }}}`,
+		}}}, "")
+
+		require.NoError(t, n.Notify(context.Background(), testReport()))
+		assert.Equal(t, http.MethodPut, got.method)
+		assert.True(t, strings.HasPrefix(got.body, `{"added":1,"targets":[`))
+	})
+
+	t.Run("exclude body", func(t *testing.T) {
+		srv, got := newServer(t, http.StatusOK)
+		n := New([]*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: srv.URL, ExcludeBody: true}}}, "")
+
+		require.NoError(t, n.Notify(context.Background(), testReport()))
+		assert.Empty(t, got.body)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv, _ := newServer(t, http.StatusInternalServerError)
+		n := New([]*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: srv.URL}}}, "")
+
+		err := n.Notify(context.Background(), testReport())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "notifications[0]")
+	})
+}
+
+func TestNotify_Slack(t *testing.T) {
+	srv, got := newServer(t, http.StatusOK)
+	url := srv.URL
+	n := New([]*v1alpha1.NotificationSpec{{Slack: &v1alpha1.SlackNotification{URL: &url}}},
+		"{{ .Operation }}: {{ .Summary.Added }} added")
+
+	require.NoError(t, n.Notify(context.Background(), testReport()))
+	var msg map[string]string
+	require.NoError(t, json.Unmarshal([]byte(got.body), &msg))
+	assert.Equal(t, "pipeline: 1 added", msg["text"])
+}
+
+func TestNotify_Events(t *testing.T) {
+	failures, failureGot := newServer(t, http.StatusOK)
+	changes, changesGot := newServer(t, http.StatusOK)
+	n := New([]*v1alpha1.NotificationSpec{
+		{Webhook: &v1alpha1.WebhookNotification{URL: failures.URL, Events: []v1alpha1.NotificationEvent{"failure"}}},
+		{Webhook: &v1alpha1.WebhookNotification{URL: changes.URL, Events: []v1alpha1.NotificationEvent{"changes"}}},
+	}, "")
+
+	assert.True(t, n.Subscribes(v1alpha1.NotificationEventChangesDetected))
+	assert.False(t, n.Subscribes(v1alpha1.NotificationEventSyncSuccess))
+
+	require.NoError(t, n.Notify(context.Background(), testReport()))
+	assert.Empty(t, failureGot.method)
+	assert.Equal(t, http.MethodPost, changesGot.method)
+}
+
+// fakeSMTP is a minimal SMTP server that records one message
+type fakeSMTP struct {
+	addr string
+	rcpt []string
+	from string
+	data chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{addr: ln.Addr().String(), data: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch upper := strings.ToUpper(cmd); {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				s.rcpt = append(s.rcpt, strings.Trim(cmd[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				s.data <- msg.String()
+				reply("250 OK")
+			case upper == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return s
+}
+
+func TestNotify_Email(t *testing.T) {
+	srv := newFakeSMTP(t)
+	host, port, err := net.SplitHostPort(srv.addr)
+	require.NoError(t, err)
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	n := New([]*v1alpha1.NotificationSpec{{Email: &v1alpha1.EmailNotification{
+		Host:    host,
+		Port:    portNum,
+		From:    "secretsync@example.com",
+		To:      "ops@example.com, sec@example.com",
+		Subject: "{{ .Operation }} {{ .Events }}",
+	}}}, "")
+
+	require.NoError(t, n.Notify(context.Background(), testReport()))
+	msg := <-srv.data
+	assert.Equal(t, "secretsync@example.com", srv.from)
+	assert.Equal(t, []string{"ops@example.com", "sec@example.com"}, srv.rcpt)
+	assert.Contains(t, msg, "Subject: pipeline [success changes]")
+	assert.Contains(t, msg, "SecretSync pipeline succeeded: 1 added, 1 modified, 0 removed")
+	assert.NotContains(t, msg, "s3cr3t")
+}
+
+func TestNotifier_Validate(t *testing.T) {
+	url := "https://hooks.slack.com/x"
+	tests := []struct {
+		name     string
+		specs    []*v1alpha1.NotificationSpec
+		template string
+		wantErr  string
+	}{
+		{"valid", []*v1alpha1.NotificationSpec{{Slack: &v1alpha1.SlackNotification{URL: &url}}}, "", ""},
+		{"no sink", []*v1alpha1.NotificationSpec{{}}, "", "exactly one of"},
+		{"two sinks", []*v1alpha1.NotificationSpec{{
+			Slack:   &v1alpha1.SlackNotification{URL: &url},
+			Webhook: &v1alpha1.WebhookNotification{URL: url},
+		}}, "", "exactly one of"},
+		{"missing webhook url", []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{}}}, "", "webhook.url"},
+		{"missing email host", []*v1alpha1.NotificationSpec{{Email: &v1alpha1.EmailNotification{To: "a@b", From: "c@d"}}}, "", "email.host"},
+		{"bad body", []*v1alpha1.NotificationSpec{{Slack: &v1alpha1.SlackNotification{URL: &url, Body: "{{ .Oops"}}}, "", "notifications[0]"},
+		{"bad template", nil, "{{ end }}", "notifications template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.specs, tt.template).Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+)
+
+// sendWebhook sends the rendered body, or the report as JSON when no template is
+// set, with the configured method and headers
+func (n *Notifier) sendWebhook(ctx context.Context, w *v1alpha1.WebhookNotification, report Report) error {
+	var body []byte
+	if !w.ExcludeBody {
+		text, err := render(report, w.Body, n.template)
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			body = []byte(text)
+		} else if body, err = json.Marshal(report); err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+	}
+
+	method := strings.ToUpper(w.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range w.Headers {
+		headers[k] = v
+	}
+	return n.post(ctx, method, w.URL, headers, body)
+}
+
+// sendSlack posts the rendered message to a Slack incoming webhook
+func (n *Notifier) sendSlack(ctx context.Context, s *v1alpha1.SlackNotification, report Report) error {
+	text, err := render(report, s.Body, n.template, DefaultTemplate)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("failed to marshal slack message: %w", err)
+	}
+	return n.post(ctx, http.MethodPost, *s.URL, map[string]string{"Content-Type": "application/json"}, body)
+}
+
+// post sends a request and fails on any non-2xx response
+func (n *Notifier) post(ctx context.Context, method, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -169,6 +169,27 @@ var (
 		[]string{"phase", "error_type"},
 	)
 
+	// Scheduled run metrics (secretsync serve)
+	PipelineScheduledRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystemPipeline,
+			Name:      "scheduled_runs_total",
+			Help:      "Total number of scheduled pipeline runs",
+		},
+		[]string{"schedule", "status"},
+	)
+
+	PipelineLastRunTimestamp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystemPipeline,
+			Name:      "last_run_timestamp_seconds",
+			Help:      "Unix time the last scheduled pipeline run finished",
+		},
+		[]string{"schedule", "status"},
+	)
+
 	// S3 merge store metrics
 	S3OperationDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -217,6 +238,8 @@ func init() {
 	Registry.MustRegister(PipelineTargetsProcessed)
 	Registry.MustRegister(PipelineParallelWorkers)
 	Registry.MustRegister(PipelineErrors)
+	Registry.MustRegister(PipelineScheduledRuns)
+	Registry.MustRegister(PipelineLastRunTimestamp)
 
 	// S3 metrics
 	Registry.MustRegister(S3OperationDuration)
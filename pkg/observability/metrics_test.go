@@ -28,6 +28,8 @@ func TestMetricsRegistration(t *testing.T) {
 		PipelineTargetsProcessed,
 		PipelineParallelWorkers,
 		PipelineErrors,
+		PipelineScheduledRuns,
+		PipelineLastRunTimestamp,
 		S3OperationDuration,
 		S3ObjectSize,
 	}
@@ -136,6 +138,16 @@ func TestPipelineMetrics(t *testing.T) {
 	if count := testutil.CollectAndCount(PipelineErrors); count == 0 {
 		t.Error("PipelineErrors metric not recorded")
 	}
+
+	// Test scheduled runs
+	PipelineScheduledRuns.WithLabelValues("@hourly", "success").Inc()
+	if count := testutil.CollectAndCount(PipelineScheduledRuns); count == 0 {
+		t.Error("PipelineScheduledRuns metric not recorded")
+	}
+	PipelineLastRunTimestamp.WithLabelValues("@hourly", "success").SetToCurrentTime()
+	if count := testutil.CollectAndCount(PipelineLastRunTimestamp); count == 0 {
+		t.Error("PipelineLastRunTimestamp metric not recorded")
+	}
 }
 
 func TestS3Metrics(t *testing.T) {
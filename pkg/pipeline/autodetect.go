@@ -21,7 +21,7 @@ type DetectedClients struct {
 type VaultDetection struct {
 	Available bool
 	Address   string
-	AuthType  string // token, approle, kubernetes, aws-iam
+	AuthType  string // token, approle, kubernetes, jwt, aws-iam
 }
 
 // AWSDetection contains AWS auto-detection results
@@ -75,6 +75,8 @@ func detectVault() VaultDetection {
 			d.AuthType = "approle"
 		} else if os.Getenv("VAULT_ROLE") != "" && isKubernetes() {
 			d.AuthType = "kubernetes"
+		} else if os.Getenv("VAULT_JWT_ROLE") != "" && (os.Getenv("VAULT_JWT_FILE") != "" || os.Getenv("VAULT_JWT") != "") {
+			d.AuthType = "jwt"
 		} else if os.Getenv("VAULT_AWS_ROLE") != "" {
 			d.AuthType = "aws-iam"
 		} else {
@@ -166,7 +168,7 @@ func (c *Config) ApplyAutoDetection(detected DetectedClients) {
 		l.WithField("address", detected.Vault.Address).Info("Applied auto-detected Vault address")
 
 		// Set auth if not configured
-		if c.Vault.Auth.Token == nil && c.Vault.Auth.AppRole == nil && c.Vault.Auth.Kubernetes == nil {
+		if c.Vault.Auth.method() == "" {
 			switch detected.Vault.AuthType {
 			case "token":
 				token := os.Getenv("VAULT_TOKEN")
@@ -190,6 +192,22 @@ func (c *Config) ApplyAutoDetection(detected DetectedClients) {
 					Role:      os.Getenv("VAULT_ROLE"),
 					MountPath: getEnvOrDefault("VAULT_K8S_MOUNT", "kubernetes"),
 				}
+			case "jwt":
+				jwtAuth := &JWTAuth{
+					Role:      os.Getenv("VAULT_JWT_ROLE"),
+					MountPath: getEnvOrDefault("VAULT_JWT_MOUNT", "jwt"),
+					TokenFile: os.Getenv("VAULT_JWT_FILE"),
+				}
+				if jwtAuth.TokenFile == "" {
+					jwtAuth.TokenEnv = "VAULT_JWT"
+				}
+				c.Vault.Auth.JWT = jwtAuth
+			case "aws-iam":
+				c.Vault.Auth.AWS = &AWSIAMAuth{
+					Role:      os.Getenv("VAULT_AWS_ROLE"),
+					MountPath: getEnvOrDefault("VAULT_AWS_MOUNT", "aws"),
+					ServerID:  os.Getenv("VAULT_AWS_SERVER_ID"),
+				}
 			}
 		}
 
@@ -208,7 +226,7 @@ func (c *Config) ApplyAutoDetection(detected DetectedClients) {
 	}
 
 	// Auto-configure merge store if not set
-	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil {
+	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil && c.MergeStore.File == nil {
 		if detected.Vault.Available {
 			c.MergeStore.Vault = &MergeStoreVault{Mount: "merged-secrets"}
 			l.Info("Auto-configured Vault merge store")
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDetectVault(t *testing.T) {
@@ -49,6 +50,30 @@ func TestDetectVault(t *testing.T) {
 		assert.True(t, d.Available)
 		assert.Equal(t, "approle", d.AuthType)
 	})
+
+	t.Run("vault with jwt", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", "http://vault:8200")
+		t.Setenv("VAULT_TOKEN", "")
+		t.Setenv("VAULT_ROLE_ID", "")
+		t.Setenv("VAULT_ROLE", "")
+		t.Setenv("VAULT_JWT_ROLE", "ci")
+		t.Setenv("VAULT_JWT_FILE", "/tmp/oidc-token")
+
+		d := detectVault()
+		assert.Equal(t, "jwt", d.AuthType)
+	})
+
+	t.Run("vault with aws iam", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", "http://vault:8200")
+		t.Setenv("VAULT_TOKEN", "")
+		t.Setenv("VAULT_ROLE_ID", "")
+		t.Setenv("VAULT_ROLE", "")
+		t.Setenv("VAULT_JWT_ROLE", "")
+		t.Setenv("VAULT_AWS_ROLE", "secretsync-ec2")
+
+		d := detectVault()
+		assert.Equal(t, "aws-iam", d.AuthType)
+	})
 }
 
 func TestDetectAWS(t *testing.T) {
@@ -189,6 +214,34 @@ func TestApplyAutoDetection(t *testing.T) {
 		assert.Equal(t, "explicit-region", cfg.AWS.Region)
 	})
 
+	t.Run("applies jwt detection", func(t *testing.T) {
+		t.Setenv("VAULT_JWT_ROLE", "ci")
+		t.Setenv("VAULT_JWT_FILE", "")
+		t.Setenv("VAULT_JWT_MOUNT", "github")
+
+		cfg := &Config{}
+		cfg.ApplyAutoDetection(DetectedClients{
+			Vault: VaultDetection{Available: true, Address: "http://vault:8200", AuthType: "jwt"},
+		})
+
+		require.NotNil(t, cfg.Vault.Auth.JWT)
+		assert.Equal(t, &JWTAuth{Role: "ci", MountPath: "github", TokenEnv: "VAULT_JWT"}, cfg.Vault.Auth.JWT)
+	})
+
+	t.Run("applies aws iam detection", func(t *testing.T) {
+		t.Setenv("VAULT_AWS_ROLE", "secretsync-ec2")
+		t.Setenv("VAULT_AWS_MOUNT", "")
+		t.Setenv("VAULT_AWS_SERVER_ID", "vault.example.com")
+
+		cfg := &Config{}
+		cfg.ApplyAutoDetection(DetectedClients{
+			Vault: VaultDetection{Available: true, Address: "http://vault:8200", AuthType: "aws-iam"},
+		})
+
+		require.NotNil(t, cfg.Vault.Auth.AWS)
+		assert.Equal(t, &AWSIAMAuth{Role: "secretsync-ec2", MountPath: "aws", ServerID: "vault.example.com"}, cfg.Vault.Auth.AWS)
+	})
+
 	t.Run("auto-configures merge store", func(t *testing.T) {
 		cfg := &Config{}
 		detected := DetectedClients{
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/extended-data-library/secretssync/pkg/notify"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -126,12 +127,48 @@ func (c *Config) expandEnvVars() {
 		})
 	}
 
-	if c.Vault.Auth.AppRole != nil {
-		c.Vault.Auth.AppRole.RoleID = expand(c.Vault.Auth.AppRole.RoleID)
-		c.Vault.Auth.AppRole.SecretID = expand(c.Vault.Auth.AppRole.SecretID)
+	expandAuth := func(auth *VaultAuthConfig) {
+		if auth == nil {
+			return
+		}
+		if auth.AppRole != nil {
+			auth.AppRole.RoleID = expand(auth.AppRole.RoleID)
+			auth.AppRole.SecretID = expand(auth.AppRole.SecretID)
+		}
+		if auth.Token != nil {
+			auth.Token.Token = expand(auth.Token.Token)
+		}
+	}
+
+	expandAuth(&c.Vault.Auth)
+	for _, src := range c.Sources {
+		if src.Vault != nil {
+			expandAuth(src.Vault.Auth)
+		}
+	}
+	for _, target := range c.Targets {
+		if target.Destination.Vault != nil {
+			expandAuth(target.Destination.Vault.Auth)
+		}
+	}
+	if c.MergeStore.File != nil {
+		c.MergeStore.File.Key = expand(c.MergeStore.File.Key)
 	}
-	if c.Vault.Auth.Token != nil {
-		c.Vault.Auth.Token.Token = expand(c.Vault.Auth.Token.Token)
+	for _, n := range c.Notifications {
+		switch {
+		case n == nil:
+		case n.Webhook != nil:
+			n.Webhook.URL = expand(n.Webhook.URL)
+			for k, v := range n.Webhook.Headers {
+				n.Webhook.Headers[k] = expand(v)
+			}
+		case n.Slack != nil && n.Slack.URL != nil:
+			url := expand(*n.Slack.URL)
+			n.Slack.URL = &url
+		case n.Email != nil:
+			n.Email.Username = expand(n.Email.Username)
+			n.Email.Password = expand(n.Email.Password)
+		}
 	}
 }
 
@@ -146,17 +183,104 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("at least one target or dynamic_target is required")
 	}
 
+	if err := c.Vault.Auth.validate(); err != nil {
+		return err
+	}
+
+	for name, src := range c.Sources {
+		if _, err := newSecretFilter(src.Filters); err != nil {
+			return fmt.Errorf("source %q: %w", name, err)
+		}
+		if src.Vault == nil {
+			continue
+		}
+		for _, path := range src.Vault.Paths {
+			if strings.Trim(strings.TrimSuffix(path, "*"), "/") == "" || strings.Contains(path, "..") {
+				return fmt.Errorf("source %q: invalid vault path %q", name, path)
+			}
+		}
+		if src.Vault.Auth != nil {
+			if err := src.Vault.Auth.validate(); err != nil {
+				return fmt.Errorf("source %q: vault: %w", name, err)
+			}
+		}
+	}
+
 	// Validate S3 merge store if explicitly configured
 	if c.MergeStore.S3 != nil && c.MergeStore.S3.Bucket == "" {
 		return fmt.Errorf("merge_store.s3.bucket is required when using S3 merge store")
 	}
 
+	// Validate file merge store if explicitly configured
+	if c.MergeStore.File != nil {
+		if c.MergeStore.File.Path == "" {
+			return fmt.Errorf("merge_store.file.path is required when using file merge store")
+		}
+		if c.MergeStore.File.Key == "" && c.MergeStore.File.KeyFile == "" {
+			return fmt.Errorf("merge_store.file.key or merge_store.file.key_file is required when using file merge store")
+		}
+	}
+
+	// Secrets Manager only accepts recovery windows of 7-30 days
+	if rw := c.Pipeline.Sync.RecoveryWindowDays; rw != 0 && (rw < 7 || rw > 30) {
+		return fmt.Errorf("pipeline.sync.recovery_window_days must be between 7 and 30, got %d", rw)
+	}
+
+	if err := c.Pipeline.Sync.Freshness.validate(); err != nil {
+		return fmt.Errorf("pipeline.sync.freshness: %w", err)
+	}
+
+	if err := validateSchedule(c.Pipeline.Schedule); err != nil {
+		return fmt.Errorf("pipeline.schedule: %w", err)
+	}
+
+	if err := c.validateLock(); err != nil {
+		return fmt.Errorf("pipeline.lock: %w", err)
+	}
+
+	if err := c.Pipeline.Guardrails.validate(); err != nil {
+		return fmt.Errorf("pipeline.guardrails: %w", err)
+	}
+
 	// Validate target account_id format IF explicitly provided
 	// (account_id is NOT required - can be resolved via fuzzy matching)
 	for name, target := range c.Targets {
 		if target.AccountID != "" && !isValidAWSAccountID(target.AccountID) {
 			return fmt.Errorf("target %q: invalid account_id format %q (must be 12 digits)", name, target.AccountID)
 		}
+		if _, err := ParseSecretNameTemplate(target.SecretNameTemplate); err != nil {
+			return fmt.Errorf("target %q: invalid secret_name_template: %w", name, err)
+		}
+		if err := target.Destination.validate(); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		if _, err := newSecretFilter(target.Filters); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		if _, err := newSecretTransform(target.Transforms); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		if err := validateSchedule(target.Schedule); err != nil {
+			return fmt.Errorf("target %q: schedule: %w", name, err)
+		}
+		if err := target.Merge.validate(); err != nil {
+			return fmt.Errorf("target %q: merge: %w", name, err)
+		}
+		for i, o := range target.Overrides {
+			if err := o.validate(); err != nil {
+				return fmt.Errorf("target %q: overrides[%d]: %w", name, i, err)
+			}
+		}
+		for i, r := range target.Remove {
+			if err := r.validate(); err != nil {
+				return fmt.Errorf("target %q: remove[%d]: %w", name, i, err)
+			}
+		}
+		if target.Guardrails != nil {
+			if err := target.Guardrails.validate(); err != nil {
+				return fmt.Errorf("target %q: guardrails: %w", name, err)
+			}
+		}
 		// Note: imports are NOT validated here - they can be resolved dynamically
 		// via fuzzy matching against AWS Organizations or Vault mounts
 	}
@@ -175,6 +299,29 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("dynamic_target %q: invalid name_matching.strategy %q (must be exact, fuzzy, or loose)", name, nm.Strategy)
 			}
 		}
+		if _, err := ParseSecretNameTemplate(dt.SecretNameTemplate); err != nil {
+			return fmt.Errorf("dynamic_target %q: invalid secret_name_template: %w", name, err)
+		}
+		if err := dt.Destination.validate(); err != nil {
+			return fmt.Errorf("dynamic_target %q: %w", name, err)
+		}
+		if _, err := newSecretFilter(dt.Filters); err != nil {
+			return fmt.Errorf("dynamic_target %q: %w", name, err)
+		}
+		if _, err := newSecretTransform(dt.Transforms); err != nil {
+			return fmt.Errorf("dynamic_target %q: %w", name, err)
+		}
+		if err := validateSchedule(dt.Schedule); err != nil {
+			return fmt.Errorf("dynamic_target %q: schedule: %w", name, err)
+		}
+		if err := dt.Merge.validate(); err != nil {
+			return fmt.Errorf("dynamic_target %q: merge: %w", name, err)
+		}
+		if dt.Guardrails != nil {
+			if err := dt.Guardrails.validate(); err != nil {
+				return fmt.Errorf("dynamic_target %q: guardrails: %w", name, err)
+			}
+		}
 		// Validate account_name_patterns regex if present
 		for i, pattern := range dt.AccountNamePatterns {
 			if pattern.Pattern != "" {
@@ -185,6 +332,19 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Kubernetes secret references only resolve in the controller
+	for i, n := range c.Notifications {
+		if n != nil && n.Webhook != nil && n.Webhook.HeaderSecret != nil {
+			return fmt.Errorf("notifications[%d]: webhook.headerSecret is not supported in pipeline config, use headers", i)
+		}
+		if n != nil && n.Slack != nil && n.Slack.URLSecret != nil {
+			return fmt.Errorf("notifications[%d]: slack.urlSecret is not supported in pipeline config, use url", i)
+		}
+	}
+	if err := notify.New(c.Notifications, c.NotificationsTemplate).Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -192,8 +352,8 @@ func (c *Config) Validate() error {
 // Call this after loading config but before validation to fill in gaps.
 func (c *Config) AutoConfigure() {
 	// Auto-detect merge store if not specified
-	// MergeStore is a value type, so check if both sub-configs are nil
-	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil {
+	// MergeStore is a value type, so check if all sub-configs are nil
+	if c.MergeStore.Vault == nil && c.MergeStore.S3 == nil && c.MergeStore.File == nil {
 		if c.Vault.Address != "" {
 			// Default to Vault merge store if Vault is configured
 			c.MergeStore.Vault = &MergeStoreVault{Mount: "merged-secrets"}
@@ -4,7 +4,9 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -53,6 +55,11 @@ targets:
     imports:
       - analytics
       - analytics-engineers
+    transforms:
+      exclude: ["^debug_"]
+      rename:
+        - from: user
+          to: DB_USER
   Serverless_Prod:
     account_id: "222222222222"
     imports:
@@ -112,9 +119,14 @@ pipeline:
 	assert.Equal(t, "111111111111", cfg.Targets["Serverless_Stg"].AccountID)
 	assert.Equal(t, []string{"analytics", "analytics-engineers"}, cfg.Targets["Serverless_Stg"].Imports)
 	assert.Equal(t, []string{"Serverless_Stg"}, cfg.Targets["Serverless_Prod"].Imports)
+	assert.Equal(t, &v1alpha1.TransformSpec{
+		Exclude: []string{"^debug_"},
+		Rename:  []v1alpha1.RenameTransform{{From: "user", To: "DB_USER"}},
+	}, cfg.Targets["Serverless_Stg"].Transforms)
 }
 
 func TestConfigValidate(t *testing.T) {
+	slackSecret := "slack-webhook"
 	tests := []struct {
 		name    string
 		config  Config
@@ -197,6 +209,237 @@ func TestConfigValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "merge_store.s3.bucket is required",
 		},
+		{
+			name: "valid file merge store",
+			config: Config{
+				MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: "/var/lib/secretsync", KeyFile: "/etc/secretsync/key"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "file merge store missing path",
+			config: Config{
+				MergeStore: MergeStoreConfig{File: &MergeStoreFile{Key: "a2V5"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "merge_store.file.path is required",
+		},
+		{
+			name: "file merge store missing key",
+			config: Config{
+				MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: "/var/lib/secretsync"}},
+				Targets: map[string]Target{
+					"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  "merge_store.file.key or merge_store.file.key_file is required",
+		},
+		{
+			name: "valid orphan recovery window",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}},
+				},
+				Pipeline: PipelineSettings{Sync: SyncSettings{DeleteOrphans: true, RecoveryWindowDays: 7}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "orphan recovery window out of range",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}},
+				},
+				Pipeline: PipelineSettings{Sync: SyncSettings{DeleteOrphans: true, RecoveryWindowDays: 3}},
+			},
+			wantErr: true,
+			errMsg:  "recovery_window_days must be between 7 and 30",
+		},
+		{
+			name: "freshness max age negative",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}},
+				},
+				Pipeline: PipelineSettings{Sync: SyncSettings{Freshness: FreshnessSettings{MaxAge: -time.Minute}}},
+			},
+			wantErr: true,
+			errMsg:  "pipeline.sync.freshness: max_age must not be negative",
+		},
+		{
+			name: "guardrail percent out of range",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}},
+				},
+				Pipeline: PipelineSettings{Guardrails: GuardrailSettings{MaxChangedPercent: ptr(150.0)}},
+			},
+			wantErr: true,
+			errMsg:  "pipeline.guardrails: max_changed_percent must be between 0 and 100",
+		},
+		{
+			name: "target guardrail negative",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}, Guardrails: &GuardrailSettings{MaxRemoved: ptr(-1)}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `target "Stg": guardrails: max_removed must not be negative`,
+		},
+		{
+			name: "dynamic target guardrail out of range",
+			config: Config{
+				DynamicTargets: map[string]DynamicTarget{
+					"accounts": {Imports: []string{"analytics"}, Guardrails: &GuardrailSettings{MaxChangedPercent: ptr(-5.0)}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `dynamic_target "accounts": guardrails: max_changed_percent must be between 0 and 100`,
+		},
+		{
+			name: "invalid secret name template",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"analytics"}, SecretNameTemplate: "{{.Path"},
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid secret_name_template",
+		},
+		{
+			name: "ssm destination",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{SSM: &SSMDestination{Mode: "flatten", Tier: "Advanced"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ssm destination invalid mode",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{SSM: &SSMDestination{Mode: "yaml"}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "destination.ssm.mode must be",
+		},
+		{
+			name: "ssm destination invalid tier",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{SSM: &SSMDestination{Tier: "Premium"}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "destination.ssm.tier must be",
+		},
+		{
+			name: "vault destination without mount",
+			config: Config{
+				Targets: map[string]Target{
+					"EU": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{Vault: &VaultDestination{Address: "https://vault-eu:8200"}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "destination.vault.mount is required",
+		},
+		{
+			name: "kubernetes destination without namespace",
+			config: Config{
+				Targets: map[string]Target{
+					"Cluster": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{Kubernetes: &KubernetesDestination{Mode: KubernetesModeSingleSecret}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "destination.kubernetes.namespace is required",
+		},
+		{
+			name: "transform rename without target key",
+			config: Config{
+				Targets: map[string]Target{
+					"Stg": {
+						Imports:    []string{"analytics"},
+						Transforms: &v1alpha1.TransformSpec{Rename: []v1alpha1.RenameTransform{{From: "user"}}},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "transforms.rename[0] requires from and to",
+		},
+		{
+			name: "vault source path escaping the mount",
+			config: Config{
+				Sources: map[string]Source{
+					"shared": {Vault: &VaultSource{Mount: "shared", Paths: []string{"team-a/../team-b"}}},
+				},
+				Targets: map[string]Target{
+					"Stg": {Imports: []string{"shared"}},
+				},
+			},
+			wantErr: true,
+			errMsg:  `source "shared": invalid vault path`,
+		},
+		{
+			name: "multiple destinations",
+			config: Config{
+				Targets: map[string]Target{
+					"EU": {
+						Imports: []string{"analytics"},
+						Destination: DestinationConfig{
+							SSM:   &SSMDestination{},
+							Vault: &VaultDestination{Mount: "replica"},
+						},
+					},
+				},
+			},
+			wantErr: true,
+			errMsg:  "only one of ssm, vault or kubernetes",
+		},
+		{
+			name: "notification without a sink",
+			config: Config{
+				Targets:       map[string]Target{"Stg": {Imports: []string{"analytics"}}},
+				Notifications: []*v1alpha1.NotificationSpec{{}},
+			},
+			wantErr: true,
+			errMsg:  "exactly one of webhook, slack or email",
+		},
+		{
+			name: "slack notification from a kubernetes secret",
+			config: Config{
+				Targets: map[string]Target{"Stg": {Imports: []string{"analytics"}}},
+				Notifications: []*v1alpha1.NotificationSpec{{
+					Slack: &v1alpha1.SlackNotification{URLSecret: &slackSecret},
+				}},
+			},
+			wantErr: true,
+			errMsg:  "slack.urlSecret is not supported",
+		},
 		{
 			name: "valid dynamic target with discovery",
 			config: Config{
@@ -259,6 +502,109 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid schedules",
+			config: Config{
+				Pipeline: PipelineSettings{Schedule: "@every 1h"},
+				Targets: map[string]Target{
+					"Production": {Imports: []string{"shared"}, Schedule: "*/15 * * * *"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid pipeline schedule",
+			config: Config{
+				Pipeline: PipelineSettings{Schedule: "every hour"},
+				Targets:  map[string]Target{"Production": {Imports: []string{"shared"}}},
+			},
+			wantErr: true,
+			errMsg:  "pipeline.schedule",
+		},
+		{
+			name: "invalid target schedule",
+			config: Config{
+				Targets: map[string]Target{
+					"Production": {Imports: []string{"shared"}, Schedule: "* * *"},
+				},
+			},
+			wantErr: true,
+			errMsg:  `target "Production": schedule`,
+		},
+		{
+			name: "valid run lock",
+			config: Config{
+				MergeStore: MergeStoreConfig{S3: &MergeStoreS3{Bucket: "merge"}},
+				Pipeline:   PipelineSettings{Lock: LockSettings{Backend: "s3", Scope: "target", TTL: time.Minute}},
+				Targets:    map[string]Target{"Production": {Imports: []string{"shared"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "run lock without store",
+			config: Config{
+				Pipeline: PipelineSettings{Lock: LockSettings{Backend: "vault"}},
+				Targets:  map[string]Target{"Production": {Imports: []string{"shared"}}},
+			},
+			wantErr: true,
+			errMsg:  "pipeline.lock: mount is required",
+		},
+		{
+			name: "invalid run lock scope",
+			config: Config{
+				Pipeline: PipelineSettings{Lock: LockSettings{Backend: "vault", Mount: "locks", Scope: "global"}},
+				Targets:  map[string]Target{"Production": {Imports: []string{"shared"}}},
+			},
+			wantErr: true,
+			errMsg:  "pipeline.lock: scope must be config or target",
+		},
+		{
+			name: "valid merge strategy",
+			config: Config{
+				Targets: map[string]Target{"Production": {
+					Imports: []string{"shared"},
+					Merge: &MergeStrategy{List: "union", Conflict: "keep-first", Rules: []MergeRule{
+						{Secret: "network/*", Key: "cidrs", List: "replace"},
+					}},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid merge strategy",
+			config: Config{
+				Targets: map[string]Target{"Production": {Imports: []string{"shared"}, Merge: &MergeStrategy{Conflict: "last"}}},
+			},
+			wantErr: true,
+			errMsg:  `target "Production": merge: conflict must be override, keep-first or error`,
+		},
+		{
+			name: "invalid merge rule glob",
+			config: Config{
+				Targets: map[string]Target{"Production": {
+					Imports: []string{"shared"},
+					Merge:   &MergeStrategy{Rules: []MergeRule{{Secret: "network/[", List: "union"}}},
+				}},
+			},
+			wantErr: true,
+			errMsg:  "merge: rules[0]: invalid glob",
+		},
+		{
+			name: "invalid override",
+			config: Config{
+				Targets: map[string]Target{"Production": {Imports: []string{"shared"}, Overrides: []SecretOverride{{Secret: "api"}}}},
+			},
+			wantErr: true,
+			errMsg:  `target "Production": overrides[0]: values or vault_refs is required`,
+		},
+		{
+			name: "invalid remove glob",
+			config: Config{
+				Targets: map[string]Target{"Production": {Imports: []string{"shared"}, Remove: []SecretRemoval{{Secret: "api", Keys: []string{"[x"}}}}},
+			},
+			wantErr: true,
+			errMsg:  `target "Production": remove[0]: invalid glob`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -376,10 +722,12 @@ func TestGetSourcePath(t *testing.T) {
 	cfg := Config{
 		Sources: map[string]Source{
 			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+			"shared":    {AWS: &AWSSource{AccountID: "333333333333", Prefix: "shared/"}},
 		},
+		AWS:        AWSConfig{Region: "us-east-1"},
 		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged-secrets"}},
 		Targets: map[string]Target{
-			"Stg":  {AccountID: "111111111111", Imports: []string{"analytics"}},
+			"Stg":  {AccountID: "111111111111", Imports: []string{"analytics", "shared"}},
 			"Prod": {AccountID: "222222222222", Imports: []string{"Stg"}},
 		},
 	}
@@ -387,8 +735,15 @@ func TestGetSourcePath(t *testing.T) {
 	// Direct source
 	assert.Equal(t, "analytics", cfg.GetSourcePath("analytics"))
 
+	// AWS Secrets Manager source (region falls back to aws.region)
+	assert.Equal(t, "aws://333333333333/us-east-1/shared/", cfg.GetSourcePath("shared"))
+
 	// Inherited target
 	assert.Equal(t, "merged-secrets/Stg", cfg.GetSourcePath("Stg"))
+
+	// Ordered source paths for a target
+	assert.Equal(t, []string{"analytics", "aws://333333333333/us-east-1/shared/"}, cfg.GetTargetSourcePaths("Stg"))
+	assert.Nil(t, cfg.GetTargetSourcePaths("missing"))
 }
 
 func TestIsValidAWSAccountID(t *testing.T) {
@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/pkg/client/aws"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Destination is where the sync phase writes a target's merged bundle.
+//
+// Each bundle secret becomes one or more entries: a Secrets Manager secret, an
+// SSM parameter per secret or one per key. Sync, the sync diff and orphan
+// deletion all work on entries, so every destination gets the same diff and
+// dry-run behavior.
+type Destination interface {
+	// Entries converts a bundle secret and its rendered name into entries keyed by entry name
+	Entries(name string, data map[string]interface{}) (map[string]interface{}, error)
+	// Read returns the current value of each named entry that exists
+	Read(ctx context.Context, names []string) (map[string]interface{}, error)
+	// Write creates or updates an entry. Created entries carry the target's ownership tags.
+	Write(ctx context.Context, name string, value interface{}) error
+	// Managed returns the entries SecretSync created for the target
+	Managed(ctx context.Context) ([]string, error)
+	// Delete removes an entry
+	Delete(ctx context.Context, name string) error
+	// Location returns a URI for the destination (for logging and results)
+	Location() string
+}
+
+// Compile-time interface checks
+var (
+	_ Destination = (*secretsManagerDestination)(nil)
+	_ Destination = (*ssmDestination)(nil)
+	_ Destination = (*vaultDestination)(nil)
+	_ Destination = (*kubernetesDestination)(nil)
+)
+
+// validate checks the destination settings of a target
+func (d *DestinationConfig) validate() error {
+	configured := 0
+	for _, set := range []bool{d.SSM != nil, d.Vault != nil, d.Kubernetes != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return fmt.Errorf("destination: only one of ssm, vault or kubernetes may be configured")
+	}
+	if d.Kubernetes != nil {
+		if d.Kubernetes.Namespace == "" {
+			return fmt.Errorf("destination.kubernetes.namespace is required")
+		}
+		switch d.Kubernetes.Mode {
+		case "", KubernetesModeSecretPerPath, KubernetesModeSingleSecret:
+		default:
+			return fmt.Errorf("destination.kubernetes.mode must be %q or %q, got %q", KubernetesModeSecretPerPath, KubernetesModeSingleSecret, d.Kubernetes.Mode)
+		}
+	}
+	if d.Vault != nil {
+		if strings.Trim(d.Vault.Mount, "/") == "" {
+			return fmt.Errorf("destination.vault.mount is required")
+		}
+		if d.Vault.Auth != nil {
+			if err := d.Vault.Auth.validate(); err != nil {
+				return fmt.Errorf("destination.vault: %w", err)
+			}
+		}
+	}
+	if d.SSM != nil {
+		switch d.SSM.Mode {
+		case "", SSMModeJSON, SSMModeFlatten:
+		default:
+			return fmt.Errorf("destination.ssm.mode must be %q or %q, got %q", SSMModeJSON, SSMModeFlatten, d.SSM.Mode)
+		}
+		switch d.SSM.Tier {
+		case "", ssmTierStandard, ssmTierAdvanced, ssmTierIntelligent:
+		default:
+			return fmt.Errorf("destination.ssm.tier must be %s, %s or %s, got %q", ssmTierStandard, ssmTierAdvanced, ssmTierIntelligent, d.SSM.Tier)
+		}
+	}
+	return nil
+}
+
+// newDestination creates the sync destination configured for a target
+func (p *Pipeline) newDestination(ctx context.Context, targetName string, target Target) (Destination, error) {
+	switch {
+	case target.Destination.SSM != nil:
+		return p.newSSMDestination(ctx, targetName, target)
+	case target.Destination.Vault != nil:
+		return p.newVaultDestination(ctx, targetName, target)
+	case target.Destination.Kubernetes != nil:
+		return p.newKubernetesDestination(targetName, target)
+	}
+
+	client, err := p.getAWSClientForTarget(ctx, targetName, target)
+	if err != nil {
+		return nil, err
+	}
+	return &secretsManagerDestination{
+		pipeline:           p,
+		client:             client,
+		targetName:         targetName,
+		accountID:          target.AccountID,
+		recoveryWindowDays: int64(p.config.Pipeline.Sync.RecoveryWindowDays),
+	}, nil
+}
+
+// destinationEntries converts a bundle into destination entries.
+// secretNames maps bundle paths to rendered names (see secretNames). Two secrets
+// producing the same entry is an error rather than a silent overwrite.
+func destinationEntries(dest Destination, bundle map[string]map[string]interface{}, secretNames map[string]string) (map[string]interface{}, error) {
+	paths := make([]string, 0, len(bundle))
+	for secretPath := range bundle {
+		paths = append(paths, secretPath)
+	}
+	sort.Strings(paths)
+
+	entries := make(map[string]interface{}, len(bundle))
+	owners := make(map[string]string, len(bundle))
+	for _, secretPath := range paths {
+		secretEntries, err := dest.Entries(secretNames[secretPath], bundle[secretPath])
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secretPath, err)
+		}
+		for name, value := range secretEntries {
+			if other, dup := owners[name]; dup {
+				return nil, fmt.Errorf("destination entry collision: %q and %q both write %q", other, secretPath, name)
+			}
+			owners[name] = secretPath
+			entries[name] = value
+		}
+	}
+
+	return entries, nil
+}
+
+// flatValue encodes a secret value for destinations that store one string per key:
+// strings are kept unchanged and everything else is JSON-encoded
+func flatValue(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// secretsManagerDestination writes each secret as a Secrets Manager secret holding its JSON object
+type secretsManagerDestination struct {
+	pipeline           *Pipeline
+	client             *aws.AwsClient
+	targetName         string
+	accountID          string
+	recoveryWindowDays int64
+}
+
+// Entries maps a secret to a single Secrets Manager secret
+func (d *secretsManagerDestination) Entries(name string, data map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{name: data}, nil
+}
+
+// Read returns the parsed values of the named secrets that exist in the account
+func (d *secretsManagerDestination) Read(ctx context.Context, names []string) (map[string]interface{}, error) {
+	existing, err := d.client.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	var found []string
+	for _, name := range names {
+		if exists[name] {
+			found = append(found, name)
+		}
+	}
+	return d.pipeline.fetchAWSSecretsByName(ctx, d.client, found), nil
+}
+
+// Write creates or updates a secret with the JSON encoding of value
+func (d *secretsManagerDestination) Write(ctx context.Context, name string, value interface{}) error {
+	secretBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secret data: %w", err)
+	}
+
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: d.targetName,
+	}
+	_, err = d.client.WriteSecret(ctx, meta, name, secretBytes)
+	return err
+}
+
+// Managed returns the secrets tagged as created for the target
+func (d *secretsManagerDestination) Managed(ctx context.Context) ([]string, error) {
+	return d.client.ListSecretsByFilter(ctx, "", ownershipTags(d.targetName))
+}
+
+// Delete schedules a secret for deletion with the configured recovery window
+func (d *secretsManagerDestination) Delete(ctx context.Context, name string) error {
+	return d.client.DeleteSecretWithRecoveryWindow(ctx, name, d.recoveryWindowDays)
+}
+
+// Location returns aws://{account_id}
+func (d *secretsManagerDestination) Location() string {
+	return fmt.Sprintf("aws://%s", d.accountID)
+}
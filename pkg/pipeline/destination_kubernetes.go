@@ -0,0 +1,370 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Labels and annotations on Secrets written by the Kubernetes destination.
+// Pruning only considers Secrets carrying both labels for the target.
+const (
+	KubernetesManagedByLabel     = "app.kubernetes.io/managed-by"
+	KubernetesTargetLabel        = "secretsync.extendeddata.dev/target"
+	KubernetesBundleIDAnnotation = "secretsync.extendeddata.dev/bundle-id"
+)
+
+// invalidKubernetesNameChars matches characters not allowed in a Secret name
+var invalidKubernetesNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// invalidKubernetesKeyChars matches characters not allowed in a Secret data key
+var invalidKubernetesKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]+`)
+
+// kubernetesDestination writes secrets as Opaque v1.Secrets.
+//
+// In secret-per-path mode each bundle secret is a Secret with one data key per
+// secret key; entries are Secret names. In single-secret mode every bundle secret is
+// one key of a single Secret holding its JSON; entries are "{secret}/{key}".
+type kubernetesDestination struct {
+	namespace  string
+	mode       string
+	secretName string
+	targetName string
+	bundleID   string
+	client     kubernetes.Interface
+}
+
+// newKubernetesDestination connects to the cluster configured for a target
+func (p *Pipeline) newKubernetesDestination(targetName string, target Target) (*kubernetesDestination, error) {
+	cfg := target.Destination.Kubernetes
+
+	client, err := newKubernetesClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newKubernetesDestinationForClient(client, cfg, targetName, BundleID(p.config.GetTargetSourcePaths(targetName)))
+}
+
+// newKubernetesClient uses the in-cluster config unless a kubeconfig or context is
+// set, and otherwise the default kubeconfig loading rules ($KUBECONFIG, ~/.kube/config)
+func newKubernetesClient(cfg *KubernetesDestination) (kubernetes.Interface, error) {
+	var restCfg *rest.Config
+	if cfg.Kubeconfig == "" && cfg.Context == "" {
+		if inCluster, err := rest.InClusterConfig(); err == nil {
+			restCfg = inCluster
+		}
+	}
+	if restCfg == nil {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		rules.ExplicitPath = cfg.Kubeconfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: cfg.Context}
+
+		var err error
+		restCfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+	}
+
+	client, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// newKubernetesDestinationForClient creates the destination with an existing client
+func newKubernetesDestinationForClient(client kubernetes.Interface, cfg *KubernetesDestination, targetName, bundleID string) (*kubernetesDestination, error) {
+	if errs := validation.IsValidLabelValue(targetName); len(errs) > 0 {
+		return nil, fmt.Errorf("target name %q cannot be used as a label value: %s", targetName, strings.Join(errs, "; "))
+	}
+
+	d := &kubernetesDestination{
+		namespace:  cfg.Namespace,
+		mode:       cfg.Mode,
+		targetName: targetName,
+		bundleID:   bundleID,
+		client:     client,
+	}
+	if d.mode == "" {
+		d.mode = KubernetesModeSecretPerPath
+	}
+	if d.mode == KubernetesModeSingleSecret {
+		d.secretName = cfg.SecretName
+		if d.secretName == "" {
+			d.secretName = kubernetesName(targetName)
+		}
+		if errs := validation.IsDNS1123Subdomain(d.secretName); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret name %q: %s", d.secretName, strings.Join(errs, "; "))
+		}
+	}
+	return d, nil
+}
+
+// kubernetesName converts a secret name into a valid Secret name:
+// lowercase, with slashes, underscores and other characters replaced by dashes
+func kubernetesName(name string) string {
+	name = invalidKubernetesNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-.")
+}
+
+// kubernetesKey converts a secret name into a valid Secret data key, using dots for slashes
+func kubernetesKey(name string) string {
+	name = strings.ReplaceAll(strings.Trim(name, "/"), "/", ".")
+	return invalidKubernetesKeyChars.ReplaceAllString(name, "_")
+}
+
+// single reports whether all secrets share one Secret
+func (d *kubernetesDestination) single() bool {
+	return d.mode == KubernetesModeSingleSecret
+}
+
+// labels returns the ownership labels for the target
+func (d *kubernetesDestination) labels() map[string]string {
+	return map[string]string{
+		KubernetesManagedByLabel: ManagedByTagValue,
+		KubernetesTargetLabel:    d.targetName,
+	}
+}
+
+// owns reports whether a Secret carries the target's ownership labels
+func (d *kubernetesDestination) owns(secret *corev1.Secret) bool {
+	for k, v := range d.labels() {
+		if secret.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Entries maps a secret to its Secret (secret-per-path) or to its key in the shared Secret
+func (d *kubernetesDestination) Entries(name string, data map[string]interface{}) (map[string]interface{}, error) {
+	if d.single() {
+		key := kubernetesKey(name)
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret key %q: %s", key, strings.Join(errs, "; "))
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal secret data: %w", err)
+		}
+		return map[string]interface{}{d.secretName + "/" + key: string(b)}, nil
+	}
+
+	secretName := kubernetesName(name)
+	if errs := validation.IsDNS1123Subdomain(secretName); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid secret name %q: %s", secretName, strings.Join(errs, "; "))
+	}
+	values := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid secret key %q: %s", key, strings.Join(errs, "; "))
+		}
+		encoded, err := flatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key %s: %w", key, err)
+		}
+		values[key] = encoded
+	}
+	return map[string]interface{}{secretName: values}, nil
+}
+
+// Read returns the current value of each named entry that exists
+func (d *kubernetesDestination) Read(ctx context.Context, names []string) (map[string]interface{}, error) {
+	secrets := make(map[string]*corev1.Secret)
+	get := func(name string) (*corev1.Secret, error) {
+		if secret, ok := secrets[name]; ok {
+			return secret, nil
+		}
+		secret, err := d.client.CoreV1().Secrets(d.namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			secret, err = nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret %s/%s: %w", d.namespace, name, err)
+		}
+		secrets[name] = secret
+		return secret, nil
+	}
+
+	values := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		secretName, key := name, ""
+		if d.single() {
+			secretName, key, _ = strings.Cut(name, "/")
+		}
+		secret, err := get(secretName)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			continue
+		}
+
+		if d.single() {
+			if v, ok := secret.Data[key]; ok {
+				values[name] = string(v)
+			}
+			continue
+		}
+		data := make(map[string]interface{}, len(secret.Data))
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		values[name] = data
+	}
+	return values, nil
+}
+
+// Write creates or updates an entry's Secret. Updates carry the resourceVersion
+// read just before, so a concurrent change fails with a conflict.
+func (d *kubernetesDestination) Write(ctx context.Context, name string, value interface{}) error {
+	if d.single() {
+		secretName, key, _ := strings.Cut(name, "/")
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("secret key %s must be a string", name)
+		}
+		return d.upsertSecret(ctx, secretName, func(secret *corev1.Secret) {
+			if secret.Data == nil {
+				secret.Data = make(map[string][]byte)
+			}
+			secret.Data[key] = []byte(s)
+		})
+	}
+
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("secret %s data must be an object, got %T", name, value)
+	}
+	data := make(map[string][]byte, len(values))
+	for k, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("secret %s key %s must be a string", name, k)
+		}
+		data[k] = []byte(s)
+	}
+	return d.upsertSecret(ctx, name, func(secret *corev1.Secret) {
+		secret.Data = data
+	})
+}
+
+// upsertSecret creates a Secret with the ownership labels and bundle annotation, or
+// applies mutate to an existing one. Secrets not owned by the target are left alone.
+func (d *kubernetesDestination) upsertSecret(ctx context.Context, name string, mutate func(*corev1.Secret)) error {
+	secrets := d.client.CoreV1().Secrets(d.namespace)
+
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   d.namespace,
+				Labels:      d.labels(),
+				Annotations: map[string]string{KubernetesBundleIDAnnotation: d.bundleID},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+		mutate(secret)
+		if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create secret %s/%s: %w", d.namespace, name, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s/%s: %w", d.namespace, name, err)
+	}
+
+	if !d.owns(existing) {
+		return fmt.Errorf("secret %s/%s exists and is not managed by secretsync for target %s", d.namespace, name, d.targetName)
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = make(map[string]string)
+	}
+	existing.Annotations[KubernetesBundleIDAnnotation] = d.bundleID
+	mutate(existing)
+
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update secret %s/%s: %w", d.namespace, name, err)
+	}
+	return nil
+}
+
+// Managed returns the entries of Secrets labeled as owned by the target
+func (d *kubernetesDestination) Managed(ctx context.Context) ([]string, error) {
+	list, err := d.client.CoreV1().Secrets(d.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(d.labels()).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in %s: %w", d.namespace, err)
+	}
+
+	var names []string
+	for _, secret := range list.Items {
+		if !d.single() {
+			names = append(names, secret.Name)
+			continue
+		}
+		for key := range secret.Data {
+			names = append(names, secret.Name+"/"+key)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Delete removes an entry. In single-secret mode the key is removed and the
+// Secret is deleted once it has no keys left.
+func (d *kubernetesDestination) Delete(ctx context.Context, name string) error {
+	secrets := d.client.CoreV1().Secrets(d.namespace)
+
+	if !d.single() {
+		if err := secrets.Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete secret %s/%s: %w", d.namespace, name, err)
+		}
+		return nil
+	}
+
+	secretName, key, _ := strings.Cut(name, "/")
+	secret, err := secrets.Get(ctx, secretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s/%s: %w", d.namespace, secretName, err)
+	}
+
+	delete(secret.Data, key)
+	if len(secret.Data) == 0 {
+		if err := secrets.Delete(ctx, secretName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete secret %s/%s: %w", d.namespace, secretName, err)
+		}
+		return nil
+	}
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update secret %s/%s: %w", d.namespace, secretName, err)
+	}
+	return nil
+}
+
+// Location returns kubernetes://{namespace}, plus the Secret name in single-secret mode
+func (d *kubernetesDestination) Location() string {
+	if d.single() {
+		return fmt.Sprintf("kubernetes://%s/%s", d.namespace, d.secretName)
+	}
+	return fmt.Sprintf("kubernetes://%s", d.namespace)
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"analytics/db", "analytics-db"},
+		{"/Serverless_Prod/API_KEY", "serverless-prod-api-key"},
+		{"app.config", "app.config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, kubernetesName(tt.input))
+		})
+	}
+}
+
+func TestKubernetesDestination_SecretPerPath(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "apps"},
+	})
+	d, err := newKubernetesDestinationForClient(client, &KubernetesDestination{Namespace: "apps"}, "Serverless_Prod", "bundle-1")
+	require.NoError(t, err)
+
+	entries, err := destinationEntries(d, map[string]map[string]interface{}{
+		"analytics/db": {"password": "hunter2", "port": float64(5432)},
+	}, map[string]string{"analytics/db": "analytics/db"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"analytics-db": map[string]interface{}{"password": "hunter2", "port": "5432"},
+	}, entries)
+
+	require.NoError(t, d.Write(ctx, "analytics-db", entries["analytics-db"]))
+
+	secret, err := client.CoreV1().Secrets("apps").Get(ctx, "analytics-db", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1.SecretTypeOpaque, secret.Type)
+	assert.Equal(t, map[string][]byte{"password": []byte("hunter2"), "port": []byte("5432")}, secret.Data)
+	assert.Equal(t, "secretsync", secret.Labels[KubernetesManagedByLabel])
+	assert.Equal(t, "Serverless_Prod", secret.Labels[KubernetesTargetLabel])
+	assert.Equal(t, "bundle-1", secret.Annotations[KubernetesBundleIDAnnotation])
+
+	values, err := d.Read(ctx, []string{"analytics-db", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"analytics-db": entries["analytics-db"]}, values)
+
+	// Secrets the target does not own are never overwritten or pruned
+	err = d.Write(ctx, "unmanaged", map[string]interface{}{"k": "v"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not managed by secretsync")
+
+	managed, err := d.Managed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics-db"}, managed)
+
+	orphans, err := (&Pipeline{}).findOrphans(ctx, d, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics-db"}, orphans)
+
+	require.NoError(t, d.Delete(ctx, "analytics-db"))
+	_, err = client.CoreV1().Secrets("apps").Get(ctx, "analytics-db", metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestKubernetesDestination_SingleSecret(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	d, err := newKubernetesDestinationForClient(client, &KubernetesDestination{
+		Namespace: "apps",
+		Mode:      KubernetesModeSingleSecret,
+	}, "Serverless_Prod", "bundle-1")
+	require.NoError(t, err)
+	assert.Equal(t, "kubernetes://apps/serverless-prod", d.Location())
+
+	entries, err := destinationEntries(d, map[string]map[string]interface{}{
+		"analytics/db":  {"password": "hunter2"},
+		"analytics/api": {"key": "abc"},
+	}, map[string]string{"analytics/db": "analytics/db", "analytics/api": "analytics/api"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"serverless-prod/analytics.db":  `{"password":"hunter2"}`,
+		"serverless-prod/analytics.api": `{"key":"abc"}`,
+	}, entries)
+
+	for name, value := range entries {
+		require.NoError(t, d.Write(ctx, name, value))
+	}
+
+	secret, err := client.CoreV1().Secrets("apps").Get(ctx, "serverless-prod", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Len(t, secret.Data, 2)
+
+	managed, err := d.Managed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"serverless-prod/analytics.api", "serverless-prod/analytics.db"}, managed)
+
+	// Removing one key keeps the Secret; removing the last deletes it
+	require.NoError(t, d.Delete(ctx, "serverless-prod/analytics.api"))
+	secret, err = client.CoreV1().Secrets("apps").Get(ctx, "serverless-prod", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"analytics.db": []byte(`{"password":"hunter2"}`)}, secret.Data)
+
+	require.NoError(t, d.Delete(ctx, "serverless-prod/analytics.db"))
+	_, err = client.CoreV1().Secrets("apps").Get(ctx, "serverless-prod", metav1.GetOptions{})
+	assert.Error(t, err)
+}
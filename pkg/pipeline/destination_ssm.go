@@ -0,0 +1,278 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// SSM parameter tiers
+const (
+	ssmTierStandard    = string(ssmtypes.ParameterTierStandard)
+	ssmTierAdvanced    = string(ssmtypes.ParameterTierAdvanced)
+	ssmTierIntelligent = string(ssmtypes.ParameterTierIntelligentTiering)
+)
+
+// ssmStandardMaxBytes is the largest value a Standard tier parameter can hold.
+// Without an explicit tier, larger values are written as Advanced parameters.
+const ssmStandardMaxBytes = 4096
+
+// ssmGetParametersBatch is the maximum number of names per GetParameters call
+const ssmGetParametersBatch = 10
+
+// ssmAPI is the subset of the SSM client used by the destination
+type ssmAPI interface {
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	GetParameters(ctx context.Context, params *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
+	DescribeParameters(ctx context.Context, params *ssm.DescribeParametersInput, optFns ...func(*ssm.Options)) (*ssm.DescribeParametersOutput, error)
+	DeleteParameter(ctx context.Context, params *ssm.DeleteParameterInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error)
+}
+
+// ssmDestination writes secrets as SecureString parameters.
+// In json mode each secret is one parameter holding its JSON object; in flatten
+// mode each key is its own parameter at {name}/{key}, with non-string values
+// JSON-encoded.
+type ssmDestination struct {
+	cfg        SSMDestination
+	targetName string
+	accountID  string
+	region     string
+	client     ssmAPI
+}
+
+// newSSMDestination creates an SSM client for the target account and region
+func (p *Pipeline) newSSMDestination(ctx context.Context, targetName string, target Target) (*ssmDestination, error) {
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if roleARN := p.getRoleARNForTarget(target); roleARN != "" {
+		awsCfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), roleARN))
+	}
+
+	return &ssmDestination{
+		cfg:        *target.Destination.SSM,
+		targetName: targetName,
+		accountID:  target.AccountID,
+		region:     region,
+		client:     ssm.NewFromConfig(awsCfg),
+	}, nil
+}
+
+// flatten reports whether secrets are written one parameter per key
+func (d *ssmDestination) flatten() bool {
+	return d.cfg.Mode == SSMModeFlatten
+}
+
+// parameterName returns a fully qualified parameter name. Hierarchical SSM
+// names must start with a slash, so one is added when missing.
+func parameterName(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	return "/" + name
+}
+
+// Entries maps a secret to one parameter, or one parameter per key when flattening
+func (d *ssmDestination) Entries(name string, data map[string]interface{}) (map[string]interface{}, error) {
+	name = parameterName(name)
+	if !d.flatten() {
+		return map[string]interface{}{name: data}, nil
+	}
+
+	entries := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		encoded, err := flatValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key %s: %w", key, err)
+		}
+		entries[name+"/"+key] = encoded
+	}
+	return entries, nil
+}
+
+// Read returns the decrypted values of the named parameters that exist.
+// In json mode values are decoded, so they compare equal to bundle data.
+func (d *ssmDestination) Read(ctx context.Context, names []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(names))
+	for start := 0; start < len(names); start += ssmGetParametersBatch {
+		end := min(start+ssmGetParametersBatch, len(names))
+
+		out, err := d.client.GetParameters(ctx, &ssm.GetParametersInput{
+			Names:          names[start:end],
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parameters: %w", err)
+		}
+
+		for _, param := range out.Parameters {
+			name, raw := aws.ToString(param.Name), aws.ToString(param.Value)
+			if d.flatten() {
+				values[name] = raw
+				continue
+			}
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+				values[name] = raw
+				continue
+			}
+			values[name] = decoded
+		}
+	}
+	return values, nil
+}
+
+// Write creates or updates a SecureString parameter.
+//
+// New parameters are created with the ownership and configured tags. SSM does not
+// accept tags on overwrite, so existing parameters are updated in place and keep
+// their tags; a parameter created by someone else is never adopted.
+func (d *ssmDestination) Write(ctx context.Context, name string, value interface{}) error {
+	l := log.WithFields(log.Fields{
+		"action":    "ssmDestination.Write",
+		"target":    d.targetName,
+		"parameter": name,
+	})
+
+	var raw string
+	if d.flatten() {
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("flattened parameter %s must be a string", name)
+		}
+		raw = s
+	} else {
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal secret data: %w", err)
+		}
+		raw = string(b)
+	}
+
+	input := &ssm.PutParameterInput{
+		Name:  aws.String(name),
+		Value: aws.String(raw),
+		Type:  ssmtypes.ParameterTypeSecureString,
+		Tier:  d.tier(raw),
+		Tags:  d.tags(),
+	}
+	if d.cfg.KMSKeyID != "" {
+		input.KeyId = aws.String(d.cfg.KMSKeyID)
+	}
+
+	_, err := d.client.PutParameter(ctx, input)
+	var exists *ssmtypes.ParameterAlreadyExists
+	if errors.As(err, &exists) {
+		l.Debug("Parameter exists, overwriting")
+		input.Tags = nil
+		input.Overwrite = aws.Bool(true)
+		// An existing Advanced parameter cannot be moved back to Standard.
+		// Intelligent-Tiering keeps its tier and upgrades only when needed.
+		if d.cfg.Tier == "" {
+			input.Tier = ssmtypes.ParameterTierIntelligentTiering
+		}
+		_, err = d.client.PutParameter(ctx, input)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to put parameter %s: %w", name, err)
+	}
+	return nil
+}
+
+// tier returns the tier a new parameter is created with: the configured tier,
+// or Advanced for values too large for Standard
+func (d *ssmDestination) tier(value string) ssmtypes.ParameterTier {
+	if d.cfg.Tier != "" {
+		return ssmtypes.ParameterTier(d.cfg.Tier)
+	}
+	if len(value) > ssmStandardMaxBytes {
+		return ssmtypes.ParameterTierAdvanced
+	}
+	return ssmtypes.ParameterTierStandard
+}
+
+// tags returns the configured tags plus the ownership tags, sorted by key.
+// Ownership tags win over configured tags with the same key.
+func (d *ssmDestination) tags() []ssmtypes.Tag {
+	all := make(map[string]string, len(d.cfg.Tags)+2)
+	for k, v := range d.cfg.Tags {
+		all[k] = v
+	}
+	for k, v := range ownershipTags(d.targetName) {
+		all[k] = v
+	}
+
+	keys := make([]string, 0, len(all))
+	for k := range all {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]ssmtypes.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, ssmtypes.Tag{Key: aws.String(k), Value: aws.String(all[k])})
+	}
+	return tags
+}
+
+// Managed returns the parameters tagged as created for the target
+func (d *ssmDestination) Managed(ctx context.Context) ([]string, error) {
+	var filters []ssmtypes.ParameterStringFilter
+	for k, v := range ownershipTags(d.targetName) {
+		filters = append(filters, ssmtypes.ParameterStringFilter{
+			Key:    aws.String("tag:" + k),
+			Values: []string{v},
+		})
+	}
+
+	var names []string
+	input := &ssm.DescribeParametersInput{ParameterFilters: filters}
+	for {
+		out, err := d.client.DescribeParameters(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe parameters: %w", err)
+		}
+		for _, param := range out.Parameters {
+			names = append(names, aws.ToString(param.Name))
+		}
+		if out.NextToken == nil {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Delete removes a parameter. Parameters that are already gone are not an error.
+func (d *ssmDestination) Delete(ctx context.Context, name string) error {
+	_, err := d.client.DeleteParameter(ctx, &ssm.DeleteParameterInput{Name: aws.String(name)})
+	var notFound *ssmtypes.ParameterNotFound
+	if err != nil && !errors.As(err, &notFound) {
+		return fmt.Errorf("failed to delete parameter %s: %w", name, err)
+	}
+	return nil
+}
+
+// Location returns ssm://{account_id}/{region}
+func (d *ssmDestination) Location() string {
+	return fmt.Sprintf("ssm://%s/%s", d.accountID, d.region)
+}
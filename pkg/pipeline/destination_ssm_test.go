@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSM is an in-memory Parameter Store
+type fakeSSM struct {
+	params map[string]*ssm.PutParameterInput
+	tags   map[string]map[string]string
+}
+
+func newFakeSSM() *fakeSSM {
+	return &fakeSSM{
+		params: map[string]*ssm.PutParameterInput{},
+		tags:   map[string]map[string]string{},
+	}
+}
+
+func (f *fakeSSM) PutParameter(ctx context.Context, in *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	name := aws.ToString(in.Name)
+	if _, ok := f.params[name]; ok && !aws.ToBool(in.Overwrite) {
+		return nil, &ssmtypes.ParameterAlreadyExists{}
+	}
+	if len(in.Tags) > 0 && aws.ToBool(in.Overwrite) {
+		return nil, errors.New("tags and overwrite cannot be combined")
+	}
+	// Advanced parameters cannot be downgraded; Intelligent-Tiering picks the
+	// lowest tier that fits without downgrading
+	tier := in.Tier
+	if tier == "" {
+		tier = ssmtypes.ParameterTierStandard
+	}
+	existing, exists := f.params[name]
+	if tier == ssmtypes.ParameterTierIntelligentTiering {
+		tier = ssmtypes.ParameterTierStandard
+		if len(aws.ToString(in.Value)) > ssmStandardMaxBytes || (exists && existing.Tier == ssmtypes.ParameterTierAdvanced) {
+			tier = ssmtypes.ParameterTierAdvanced
+		}
+	}
+	if exists && existing.Tier == ssmtypes.ParameterTierAdvanced && tier == ssmtypes.ParameterTierStandard {
+		return nil, errors.New("advanced parameters cannot be downgraded to standard")
+	}
+	if tier == ssmtypes.ParameterTierStandard && len(aws.ToString(in.Value)) > ssmStandardMaxBytes {
+		return nil, errors.New("value too large for the standard tier")
+	}
+	stored := *in
+	stored.Tier = tier
+	in = &stored
+	if len(in.Tags) > 0 {
+		f.tags[name] = map[string]string{}
+		for _, t := range in.Tags {
+			f.tags[name][aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+	}
+	f.params[name] = in
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeSSM) GetParameters(ctx context.Context, in *ssm.GetParametersInput, _ ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	out := &ssm.GetParametersOutput{}
+	for _, name := range in.Names {
+		if p, ok := f.params[name]; ok {
+			out.Parameters = append(out.Parameters, ssmtypes.Parameter{Name: p.Name, Value: p.Value})
+		} else {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSSM) DescribeParameters(ctx context.Context, in *ssm.DescribeParametersInput, _ ...func(*ssm.Options)) (*ssm.DescribeParametersOutput, error) {
+	out := &ssm.DescribeParametersOutput{}
+	for name := range f.params {
+		match := true
+		for _, filter := range in.ParameterFilters {
+			key := strings.TrimPrefix(aws.ToString(filter.Key), "tag:")
+			if f.tags[name][key] != filter.Values[0] {
+				match = false
+			}
+		}
+		if match {
+			out.Parameters = append(out.Parameters, ssmtypes.ParameterMetadata{Name: aws.String(name)})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSSM) DeleteParameter(ctx context.Context, in *ssm.DeleteParameterInput, _ ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error) {
+	name := aws.ToString(in.Name)
+	if _, ok := f.params[name]; !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	delete(f.params, name)
+	delete(f.tags, name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func TestSSMDestination_Entries(t *testing.T) {
+	data := map[string]interface{}{
+		"password": "hunter2",
+		"port":     float64(5432),
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		secret   string
+		expected map[string]interface{}
+	}{
+		{
+			name:     "json mode",
+			mode:     SSMModeJSON,
+			secret:   "app/db",
+			expected: map[string]interface{}{"/app/db": data},
+		},
+		{
+			name:   "flatten mode",
+			mode:   SSMModeFlatten,
+			secret: "/app/db",
+			expected: map[string]interface{}{
+				"/app/db/password": "hunter2",
+				"/app/db/port":     "5432",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &ssmDestination{cfg: SSMDestination{Mode: tt.mode}}
+			entries, err := d.Entries(tt.secret, data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, entries)
+		})
+	}
+}
+
+func TestSSMDestination_WriteReadManaged(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeSSM()
+	d := &ssmDestination{
+		cfg: SSMDestination{
+			KMSKeyID: "alias/secrets",
+			Tags:     map[string]string{"team": "platform"},
+		},
+		targetName: "Serverless_Prod",
+		client:     fake,
+	}
+
+	// A parameter created outside SecretSync
+	fake.params["/app/legacy"] = &ssm.PutParameterInput{Name: aws.String("/app/legacy"), Value: aws.String("{}")}
+
+	data := map[string]interface{}{"password": "hunter2"}
+	require.NoError(t, d.Write(ctx, "/app/db", data))
+	require.NoError(t, d.Write(ctx, "/app/legacy", data))
+
+	created := fake.params["/app/db"]
+	assert.Equal(t, ssmtypes.ParameterTypeSecureString, created.Type)
+	assert.Equal(t, "alias/secrets", aws.ToString(created.KeyId))
+	assert.Equal(t, ssmtypes.ParameterTierStandard, created.Tier)
+	assert.Equal(t, map[string]string{
+		"team":                  "platform",
+		"secretsync:managed-by": "secretsync",
+		"secretsync:target":     "Serverless_Prod",
+	}, fake.tags["/app/db"])
+
+	// Overwriting keeps the existing parameter's (absent) tags
+	assert.Equal(t, `{"password":"hunter2"}`, aws.ToString(fake.params["/app/legacy"].Value))
+	assert.Empty(t, fake.tags["/app/legacy"])
+
+	// Updating a managed parameter succeeds without re-tagging
+	require.NoError(t, d.Write(ctx, "/app/db", map[string]interface{}{"password": "changed"}))
+
+	values, err := d.Read(ctx, []string{"/app/db", "/app/missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"/app/db": map[string]interface{}{"password": "changed"},
+	}, values)
+
+	managed, err := d.Managed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/app/db"}, managed)
+
+	require.NoError(t, d.Delete(ctx, "/app/db"))
+	require.NoError(t, d.Delete(ctx, "/app/db"), "deleting a missing parameter is not an error")
+}
+
+func TestSSMDestination_Tier(t *testing.T) {
+	large := strings.Repeat("x", ssmStandardMaxBytes+1)
+
+	tests := []struct {
+		name     string
+		tier     string
+		value    string
+		expected ssmtypes.ParameterTier
+	}{
+		{name: "small value", value: "v", expected: ssmtypes.ParameterTierStandard},
+		{name: "large value", value: large, expected: ssmtypes.ParameterTierAdvanced},
+		{name: "explicit tier", tier: ssmTierIntelligent, value: "v", expected: ssmtypes.ParameterTierIntelligentTiering},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &ssmDestination{cfg: SSMDestination{Tier: tt.tier}}
+			assert.Equal(t, tt.expected, d.tier(tt.value))
+		})
+	}
+}
+
+func TestSSMDestination_TierOnUpdate(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeSSM()
+	d := &ssmDestination{targetName: "Serverless_Prod", client: fake}
+
+	large := map[string]interface{}{"cert": strings.Repeat("x", ssmStandardMaxBytes)}
+	require.NoError(t, d.Write(ctx, "/app/cert", large))
+	assert.Equal(t, ssmtypes.ParameterTierAdvanced, fake.params["/app/cert"].Tier)
+
+	// A value that fits in Standard again keeps the parameter Advanced
+	require.NoError(t, d.Write(ctx, "/app/cert", map[string]interface{}{"cert": "short"}))
+	assert.Equal(t, ssmtypes.ParameterTierAdvanced, fake.params["/app/cert"].Tier)
+	assert.Equal(t, `{"cert":"short"}`, aws.ToString(fake.params["/app/cert"].Value))
+
+	// Updating a small parameter leaves it Standard and upgrades it when it grows
+	require.NoError(t, d.Write(ctx, "/app/db", map[string]interface{}{"password": "v1"}))
+	require.NoError(t, d.Write(ctx, "/app/db", map[string]interface{}{"password": "v2"}))
+	assert.Equal(t, ssmtypes.ParameterTierStandard, fake.params["/app/db"].Tier)
+	require.NoError(t, d.Write(ctx, "/app/db", large))
+	assert.Equal(t, ssmtypes.ParameterTierAdvanced, fake.params["/app/db"].Tier)
+}
+
+func TestDestinationEntries_Collision(t *testing.T) {
+	d := &ssmDestination{cfg: SSMDestination{Mode: SSMModeFlatten}}
+
+	// Flattening "app" yields /app/db/password, which "app/db" also writes
+	bundle := map[string]map[string]interface{}{
+		"app":    {"db/password": "x"},
+		"app/db": {"password": "y"},
+	}
+	names := map[string]string{"app": "app", "app/db": "app/db"}
+
+	_, err := destinationEntries(d, bundle, names)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "destination entry collision")
+}
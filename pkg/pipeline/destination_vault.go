@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	"github.com/hashicorp/vault/api"
+)
+
+// vaultDestination writes each secret to a KV v2 mount, possibly on another cluster.
+//
+// Writes use WriteSecretWithLatestCAS, so a secret changed or created by another
+// writer between the version check and the write fails instead of being silently
+// overwritten. Secrets created by SecretSync carry the ownership tags as KV
+// custom_metadata, which is what orphan deletion matches on.
+type vaultDestination struct {
+	mount      string
+	address    string
+	targetName string
+	client     *vault.VaultClient
+}
+
+// newVaultDestination logs in to the destination cluster
+func (p *Pipeline) newVaultDestination(ctx context.Context, targetName string, target Target) (*vaultDestination, error) {
+	cfg := target.Destination.Vault
+
+	vaultCfg := p.config.Vault.withOverrides(cfg.Address, cfg.Namespace, cfg.Auth)
+	client := newVaultClient(&vaultCfg)
+	if err := client.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init destination vault client: %w", err)
+	}
+
+	return &vaultDestination{
+		mount:      strings.Trim(cfg.Mount, "/"),
+		address:    vaultCfg.Address,
+		targetName: targetName,
+		client:     client,
+	}, nil
+}
+
+// Entries maps a secret to {mount}/{name}
+func (d *vaultDestination) Entries(name string, data map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{d.mount + "/" + strings.Trim(name, "/"): data}, nil
+}
+
+// Read returns the current data of the named secrets that exist. Missing and
+// deleted secrets are left out; any other failure is returned, so a destination
+// that cannot be read is never taken for an empty one.
+func (d *vaultDestination) Read(ctx context.Context, names []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		data, _, err := d.client.GetKVSecretVersion(ctx, name)
+		var respErr *api.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+		}
+		if data == nil {
+			continue
+		}
+		values[name] = data
+	}
+	return values, nil
+}
+
+// Write creates or updates a secret with check-and-set. Newly created secrets are
+// tagged with the ownership custom_metadata; existing secrets keep theirs.
+func (d *vaultDestination) Write(ctx context.Context, name string, value interface{}) error {
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("secret %s data must be an object, got %T", name, value)
+	}
+
+	_, exists, err := d.client.GetSecretCustomMetadata(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata for %s: %w", name, err)
+	}
+
+	if _, err := d.client.WriteSecretWithLatestCAS(ctx, name, data); err != nil {
+		return fmt.Errorf("failed to write secret %s: %w", name, err)
+	}
+
+	if !exists {
+		if err := d.client.SetSecretCustomMetadata(ctx, name, ownershipTags(d.targetName)); err != nil {
+			return fmt.Errorf("failed to tag secret %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Managed returns the secrets on the mount whose custom_metadata marks them as
+// created for the target
+func (d *vaultDestination) Managed(ctx context.Context) ([]string, error) {
+	paths, err := d.listMount(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	want := ownershipTags(d.targetName)
+	var managed []string
+	for _, secretPath := range paths {
+		custom, exists, err := d.client.GetSecretCustomMetadata(ctx, secretPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata for %s: %w", secretPath, err)
+		}
+		if exists && hasOwnership(custom, want) {
+			managed = append(managed, secretPath)
+		}
+	}
+	sort.Strings(managed)
+
+	return managed, nil
+}
+
+// listMount returns every secret path on the mount
+func (d *vaultDestination) listMount(ctx context.Context) ([]string, error) {
+	return listVaultMount(ctx, d.client, d.mount)
+}
+
+// listVaultMount returns every secret path on a KV v2 mount. The client's recursive
+// listing needs a path below the mount, so top-level keys are listed here first.
+func listVaultMount(ctx context.Context, client *vault.VaultClient, mount string) ([]string, error) {
+	top, err := client.Client.Logical().ListWithContext(ctx, mount+"/metadata")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", mount, err)
+	}
+	if top == nil || top.Data == nil {
+		return nil, nil
+	}
+	keys, _ := top.Data["keys"].([]interface{})
+
+	var paths []string
+	for _, k := range keys {
+		key, ok := k.(string)
+		if !ok {
+			continue
+		}
+		if !strings.HasSuffix(key, "/") {
+			paths = append(paths, mount+"/"+key)
+			continue
+		}
+		nested, err := client.ListSecretsOnce(ctx, mount+"/"+strings.TrimSuffix(key, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", mount, key, err)
+		}
+		paths = append(paths, nested...)
+	}
+	return paths, nil
+}
+
+// hasOwnership reports whether metadata carries every ownership tag
+func hasOwnership(metadata, want map[string]string) bool {
+	for k, v := range want {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Delete removes a secret with all of its versions and metadata
+func (d *vaultDestination) Delete(ctx context.Context, name string) error {
+	return d.client.DeleteSecret(ctx, name)
+}
+
+// Location returns {address}/{mount}
+func (d *vaultDestination) Location() string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(d.address, "/"), d.mount)
+}
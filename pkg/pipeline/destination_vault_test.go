@@ -0,0 +1,265 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKV is an in-memory KV v2 mount served over the Vault HTTP API
+type fakeKV struct {
+	mu       sync.Mutex
+	mount    string
+	data     map[string]map[string]interface{}
+	versions map[string]int
+	custom   map[string]map[string]string
+
+	// beforeWrite runs (under the lock) before a data write is checked, to simulate
+	// another writer landing between the version check and the write
+	beforeWrite func(path string)
+	// denied paths answer every request with 403
+	denied map[string]bool
+}
+
+func newFakeKV(t *testing.T, mount string) (*fakeKV, *httptest.Server) {
+	t.Helper()
+	kv := &fakeKV{
+		mount:    mount,
+		data:     map[string]map[string]interface{}{},
+		versions: map[string]int{},
+		custom:   map[string]map[string]string{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(kv.serve))
+	t.Cleanup(srv.Close)
+	return kv, srv
+}
+
+// put stores a secret as if another writer had created it
+func (kv *fakeKV) put(path string, data map[string]interface{}) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.data[path] = data
+	kv.versions[path]++
+}
+
+func (kv *fakeKV) serve(w http.ResponseWriter, r *http.Request) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	rest := strings.TrimPrefix(r.URL.Path, "/v1/"+kv.mount+"/")
+	kind, path, _ := strings.Cut(rest, "/")
+
+	reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+	fail := func(code int, msg string) {
+		w.WriteHeader(code)
+		reply(map[string]interface{}{"errors": []string{msg}})
+	}
+
+	if kv.denied[path] {
+		fail(http.StatusForbidden, "permission denied")
+		return
+	}
+
+	switch {
+	case kind == "metadata" && r.URL.Query().Get("list") == "true":
+		prefix := strings.TrimSuffix(path, "/")
+		if prefix != "" {
+			prefix += "/"
+		}
+		seen := map[string]bool{}
+		var keys []string
+		for p := range kv.versions {
+			if !strings.HasPrefix(p, prefix) {
+				continue
+			}
+			key := strings.TrimPrefix(p, prefix)
+			if head, _, nested := strings.Cut(key, "/"); nested {
+				key = head + "/"
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			fail(http.StatusNotFound, "not found")
+			return
+		}
+		sort.Strings(keys)
+		reply(map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+
+	case kind == "metadata" && r.Method == http.MethodGet:
+		version, ok := kv.versions[path]
+		if !ok {
+			fail(http.StatusNotFound, "not found")
+			return
+		}
+		reply(map[string]interface{}{"data": map[string]interface{}{
+			"current_version": version,
+			"custom_metadata": kv.custom[path],
+		}})
+
+	case kind == "metadata" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		var body struct {
+			CustomMetadata map[string]string `json:"custom_metadata"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		kv.custom[path] = body.CustomMetadata
+		w.WriteHeader(http.StatusNoContent)
+
+	case kind == "metadata" && r.Method == http.MethodDelete:
+		delete(kv.data, path)
+		delete(kv.versions, path)
+		delete(kv.custom, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	case kind == "data" && r.Method == http.MethodGet:
+		data, ok := kv.data[path]
+		if !ok {
+			fail(http.StatusNotFound, "not found")
+			return
+		}
+		reply(map[string]interface{}{"data": map[string]interface{}{
+			"data":     data,
+			"metadata": map[string]interface{}{"version": kv.versions[path]},
+		}})
+
+	case kind == "data" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		var body struct {
+			Data    map[string]interface{} `json:"data"`
+			Options struct {
+				CAS *int `json:"cas"`
+			} `json:"options"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if kv.beforeWrite != nil {
+			kv.beforeWrite(path)
+		}
+		if body.Options.CAS != nil && *body.Options.CAS != kv.versions[path] {
+			fail(http.StatusBadRequest, "check-and-set parameter did not match the current version")
+			return
+		}
+		kv.data[path] = body.Data
+		kv.versions[path]++
+		reply(map[string]interface{}{"data": map[string]interface{}{"version": kv.versions[path]}})
+
+	default:
+		fail(http.StatusNotFound, "not found")
+	}
+}
+
+func newTestVaultDestination(t *testing.T, srv *httptest.Server) *vaultDestination {
+	t.Helper()
+	t.Setenv("VAULT_TOKEN", "root")
+
+	p := &Pipeline{config: &Config{
+		Vault: VaultConfig{Address: "http://unused.invalid"},
+	}}
+	target := Target{Destination: DestinationConfig{Vault: &VaultDestination{
+		Address: srv.URL,
+		Mount:   "replica/",
+	}}}
+
+	d, err := p.newVaultDestination(context.Background(), "Team_EU", target)
+	require.NoError(t, err)
+	return d
+}
+
+func TestVaultDestination(t *testing.T) {
+	ctx := context.Background()
+	kv, srv := newFakeKV(t, "replica")
+	d := newTestVaultDestination(t, srv)
+
+	assert.Equal(t, srv.URL+"/replica", d.Location())
+
+	entries, err := d.Entries("/analytics/db", map[string]interface{}{"user": "a"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"replica/analytics/db": map[string]interface{}{"user": "a"}}, entries)
+
+	// A secret written by someone else is updated but never adopted
+	kv.put("legacy/api", map[string]interface{}{"key": "old"})
+
+	require.NoError(t, d.Write(ctx, "replica/analytics/db", map[string]interface{}{"user": "a"}))
+	require.NoError(t, d.Write(ctx, "replica/analytics/db", map[string]interface{}{"user": "b"}))
+	require.NoError(t, d.Write(ctx, "replica/legacy/api", map[string]interface{}{"key": "new"}))
+
+	assert.Equal(t, 2, kv.versions["analytics/db"])
+	assert.Equal(t, ownershipTags("Team_EU"), kv.custom["analytics/db"])
+	assert.Empty(t, kv.custom["legacy/api"])
+
+	values, err := d.Read(ctx, []string{"replica/analytics/db", "replica/missing/secret"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"replica/analytics/db": map[string]interface{}{"user": "b"},
+	}, values)
+
+	managed, err := d.Managed(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"replica/analytics/db"}, managed)
+
+	require.NoError(t, d.Delete(ctx, "replica/analytics/db"))
+	_, ok := kv.versions["analytics/db"]
+	assert.False(t, ok)
+}
+
+func TestVaultDestination_ReadErrors(t *testing.T) {
+	ctx := context.Background()
+	kv, srv := newFakeKV(t, "replica")
+	d := newTestVaultDestination(t, srv)
+	kv.put("app/db", map[string]interface{}{"user": "a"})
+	kv.put("app/gone", map[string]interface{}{"user": "b"})
+	kv.mu.Lock()
+	delete(kv.data, "app/gone")
+	kv.mu.Unlock()
+
+	// Missing secrets are absent
+	values, err := d.Read(ctx, []string{"replica/app/db", "replica/app/gone", "replica/app/missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"replica/app/db": map[string]interface{}{"user": "a"}}, values)
+
+	// An unreadable secret fails the read instead of looking absent
+	kv.denied = map[string]bool{"app/db": true}
+	_, err = d.Read(ctx, []string{"replica/app/db"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read secret replica/app/db")
+	assert.Contains(t, err.Error(), "permission denied")
+}
+
+func TestVaultDestination_ConcurrentWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool
+	}{
+		{name: "create"},
+		{name: "update", existing: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv, srv := newFakeKV(t, "replica")
+			d := newTestVaultDestination(t, srv)
+			if tt.existing {
+				kv.put("app/db", map[string]interface{}{"user": "a"})
+			}
+
+			// Another writer lands after the version was read
+			kv.beforeWrite = func(path string) {
+				kv.data[path] = map[string]interface{}{"user": "theirs"}
+				kv.versions[path]++
+			}
+
+			err := d.Write(context.Background(), "replica/app/db", map[string]interface{}{"user": "ours"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "check-and-set")
+			assert.Equal(t, map[string]interface{}{"user": "theirs"}, kv.data["app/db"])
+		})
+	}
+}
@@ -2,7 +2,6 @@ package pipeline
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/extended-data-library/secretssync/pkg/diff"
 	log "github.com/sirupsen/logrus"
@@ -18,6 +17,14 @@ func (p *Pipeline) initDiff(dryRun bool, configPath string) {
 	}
 }
 
+// resetDiff stops diff tracking for a run. The previous run's diff stays
+// with whoever took it and is no longer added to.
+func (p *Pipeline) resetDiff() {
+	p.diffMu.Lock()
+	defer p.diffMu.Unlock()
+	p.pipelineDiff = nil
+}
+
 // addTargetDiff adds a target diff to the pipeline diff
 func (p *Pipeline) addTargetDiff(td diff.TargetDiff) {
 	p.diffMu.Lock()
@@ -27,96 +34,64 @@ func (p *Pipeline) addTargetDiff(td diff.TargetDiff) {
 	}
 }
 
-// computeMergeDiff computes the diff for a merge operation
-func (p *Pipeline) computeMergeDiff(ctx context.Context, targetName string, sourcePaths []string) (*diff.TargetDiff, error) {
-	l := log.WithFields(log.Fields{
-		"action": "computeMergeDiff",
-		"target": targetName,
-	})
-
-	// Fetch current state from merge store
-	var currentSecrets map[string]interface{}
-	var err error
-
-	if p.config.MergeStore.Vault != nil {
-		mergePath := fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-		currentSecrets, err = p.fetchVaultSecrets(ctx, mergePath)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch current merge store state")
-			currentSecrets = make(map[string]interface{})
-		}
-	} else if p.s3Store != nil {
-		currentSecrets, err = p.fetchS3MergeSecrets(ctx, targetName)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch current S3 merge store state")
-			currentSecrets = make(map[string]interface{})
-		}
-	}
-
-	// Fetch desired state from source paths
-	desiredSecrets := make(map[string]interface{})
-	for _, sourcePath := range sourcePaths {
-		sourceSecrets, err := p.fetchVaultSecrets(ctx, sourcePath)
-		if err != nil {
-			l.WithError(err).WithField("sourcePath", sourcePath).Debug("Failed to fetch source secrets")
-			continue
-		}
-		for k, v := range sourceSecrets {
-			desiredSecrets[k] = v
+// computeMergeDiff computes the diff between the bundle currently in the
+// merge store and the newly merged secrets. Must run before the bundle is written.
+func (p *Pipeline) computeMergeDiff(ctx context.Context, targetName, bundleID string, mergedSecrets map[string]interface{}, prov *Provenance) *diff.TargetDiff {
+	changes := diff.DiffSecrets(p.storedSecrets(ctx, targetName, bundleID), mergedSecrets)
+	for i := range changes {
+		if changes[i].ChangeType == diff.ChangeTypeAdded || changes[i].ChangeType == diff.ChangeTypeModified {
+			changes[i].MergeStrategies = prov.nonDefaultStrategies(changes[i].Path)
 		}
+		changes[i].Tombstoned = prov.Removed[changes[i].Path]
 	}
-
-	changes := diff.DiffSecrets(currentSecrets, desiredSecrets)
-	summary := diff.ComputeSummary(changes)
-
-	targetDiff := &diff.TargetDiff{
+	return &diff.TargetDiff{
 		Target:  targetName,
 		Changes: changes,
-		Summary: summary,
+		Summary: diff.ComputeSummary(changes),
 	}
+}
 
-	return targetDiff, nil
+// storedSecrets returns the secrets of the bundle currently in the merge
+// store, or none when it cannot be read
+func (p *Pipeline) storedSecrets(ctx context.Context, targetName, bundleID string) map[string]interface{} {
+	secrets := make(map[string]interface{})
+	if p.mergeStore == nil {
+		return secrets
+	}
+	existing, err := p.mergeStore.ReadMergedBundle(ctx, targetName, bundleID)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"action": "storedSecrets",
+			"target": targetName,
+		}).WithError(err).Debug("Failed to fetch current merge store state")
+	}
+	for k, v := range existing {
+		secrets[k] = v
+	}
+	return secrets
 }
 
-// computeSyncDiff computes the diff for a sync operation
-func (p *Pipeline) computeSyncDiff(ctx context.Context, targetName string, roleARN, region string) (*diff.TargetDiff, error) {
+// computeSyncDiff computes the diff between the destination and the entries being synced.
+// Current state covers only the entries the bundle writes plus orphans scheduled for
+// deletion, so unrelated secrets at the destination never show up as removed.
+func (p *Pipeline) computeSyncDiff(ctx context.Context, targetName string, dest Destination, entries map[string]interface{}, orphans []string) *diff.TargetDiff {
 	l := log.WithFields(log.Fields{
 		"action": "computeSyncDiff",
 		"target": targetName,
 	})
 
-	currentSecrets, err := p.fetchAWSSecrets(ctx, roleARN, region)
+	currentSecrets, err := dest.Read(ctx, entryNames(entries, orphans))
 	if err != nil {
-		l.WithError(err).Debug("Failed to fetch current AWS state")
-		currentSecrets = make(map[string]interface{})
+		l.WithError(err).Warn("Failed to read current destination state, diffing against an empty destination")
+		currentSecrets = map[string]interface{}{}
 	}
 
-	var desiredSecrets map[string]interface{}
-	if p.config.MergeStore.Vault != nil {
-		mergePath := fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-		desiredSecrets, err = p.fetchVaultSecrets(ctx, mergePath)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch desired state from merge store")
-			desiredSecrets = make(map[string]interface{})
-		}
-	} else if p.s3Store != nil {
-		desiredSecrets, err = p.fetchS3MergeSecrets(ctx, targetName)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch desired state from S3")
-			desiredSecrets = make(map[string]interface{})
-		}
-	}
-
-	changes := diff.DiffSecrets(currentSecrets, desiredSecrets)
-	summary := diff.ComputeSummary(changes)
-
-	targetDiff := &diff.TargetDiff{
+	changes := diff.DiffSecrets(currentSecrets, entries)
+	return &diff.TargetDiff{
 		Target:  targetName,
 		Changes: changes,
-		Summary: summary,
+		Summary: diff.ComputeSummary(changes),
 	}
-
-	return targetDiff, nil
 }
 
 // FormatDiff returns the formatted diff output
@@ -130,7 +105,8 @@ func (p *Pipeline) FormatDiff(format diff.OutputFormat) string {
 }
 
 // ExitCode returns the appropriate exit code based on diff results
-// 0 = no changes (zero-sum), 1 = changes detected, 2 = errors
+// 0 = no changes (zero-sum), 1 = changes detected, 2 = errors,
+// 3 = a guardrail aborted a target (ExitCodeGuardrail)
 func (p *Pipeline) ExitCode() int {
 	p.diffMu.Lock()
 	defer p.diffMu.Unlock()
@@ -143,8 +119,12 @@ func (p *Pipeline) ExitCode() int {
 			break
 		}
 	}
+	tripped := len(TrippedGuardrails(p.results)) > 0
 	p.resultsMu.Unlock()
 
+	if tripped {
+		return ExitCodeGuardrail
+	}
 	if hasErrors {
 		return 2
 	}
@@ -128,11 +128,18 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 			}
 
 			discoveredTargets[targetName] = Target{
-				AccountID:    acct.ID,
-				Imports:      imports,
-				Region:       region,
-				SecretPrefix: dynamicTarget.SecretPrefix,
-				RoleARN:      roleARN,
+				AccountID:          acct.ID,
+				Imports:            imports,
+				Region:             region,
+				SecretPrefix:       dynamicTarget.SecretPrefix,
+				RoleARN:            roleARN,
+				SecretNameTemplate: dynamicTarget.SecretNameTemplate,
+				Destination:        dynamicTarget.Destination,
+				Filters:            dynamicTarget.Filters,
+				Transforms:         dynamicTarget.Transforms,
+				Schedule:           dynamicTarget.Schedule,
+				Merge:              dynamicTarget.Merge,
+				Guardrails:         dynamicTarget.Guardrails,
 			}
 
 			dtLog.WithFields(log.Fields{
@@ -126,7 +126,7 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 
 		// Execute level in parallel
 		levelResults := p.executeParallel(ctx, levelTargets, opts.Parallelism, func(target string) Result {
-			return p.mergeTarget(ctx, target, opts.DryRun)
+			return p.mergeTarget(ctx, target, opts.DryRun, opts.AllowLargeChanges)
 		})
 
 		results = append(results, levelResults...)
@@ -152,7 +152,7 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 // executeSyncPhase runs sync operations (can be fully parallel)
 func (p *Pipeline) executeSyncPhase(ctx context.Context, targets []string, opts Options) ([]Result, error) {
 	results := p.executeParallel(ctx, targets, opts.Parallelism, func(target string) Result {
-		return p.syncTarget(ctx, target, opts.DryRun)
+		return p.syncTarget(ctx, target, opts.DryRun, opts.AllowLargeChanges)
 	})
 
 	var lastErr error
@@ -6,7 +6,6 @@ import (
 	"fmt"
 
 	"github.com/extended-data-library/secretssync/pkg/client/aws"
-	"github.com/extended-data-library/secretssync/pkg/client/vault"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -17,14 +16,8 @@ func (p *Pipeline) fetchVaultSecrets(ctx context.Context, path string) (map[stri
 		"path":   path,
 	})
 
-	vaultClient := &vault.VaultClient{
-		Address:                  p.config.Vault.Address,
-		Namespace:                p.config.Vault.Namespace,
-		Path:                     path,
-		MaxTraversalDepth:        p.config.Vault.MaxTraversalDepth,
-		MaxSecretsPerMount:       p.config.Vault.MaxSecretsPerMount,
-		QueueCompactionThreshold: p.config.Vault.QueueCompactionThreshold,
-	}
+	vaultClient := newVaultClient(&p.config.Vault)
+	vaultClient.Path = path
 
 	if err := vaultClient.Init(ctx); err != nil {
 		l.WithError(err).Debug("Failed to initialize Vault client")
@@ -83,8 +76,18 @@ func (p *Pipeline) fetchAWSSecrets(ctx context.Context, roleARN, region string)
 		return map[string]interface{}{}, nil
 	}
 
-	secrets := make(map[string]interface{})
-	for _, secretName := range secretsList {
+	return p.fetchAWSSecretsByName(ctx, awsClient, secretsList), nil
+}
+
+// fetchAWSSecretsByName reads and parses the named secrets with an initialized client.
+// Secrets that cannot be read or parsed are skipped.
+func (p *Pipeline) fetchAWSSecretsByName(ctx context.Context, awsClient *aws.AwsClient, names []string) map[string]interface{} {
+	l := log.WithFields(log.Fields{
+		"action": "fetchAWSSecretsByName",
+	})
+
+	secrets := make(map[string]interface{}, len(names))
+	for _, secretName := range names {
 		secretData, err := awsClient.GetSecret(ctx, secretName)
 		if err != nil {
 			l.WithError(err).WithField("secretName", secretName).Debug("Failed to get secret")
@@ -99,35 +102,5 @@ func (p *Pipeline) fetchAWSSecrets(ctx context.Context, roleARN, region string)
 		secrets[secretName] = data
 	}
 
-	return secrets, nil
-}
-
-// fetchS3MergeSecrets fetches all secrets from S3 merge store for a target
-func (p *Pipeline) fetchS3MergeSecrets(ctx context.Context, targetName string) (map[string]interface{}, error) {
-	l := log.WithFields(log.Fields{
-		"action": "fetchS3MergeSecrets",
-		"target": targetName,
-	})
-
-	if p.s3Store == nil {
-		return map[string]interface{}{}, nil
-	}
-
-	secrets, err := p.s3Store.ListSecrets(ctx, targetName)
-	if err != nil {
-		l.WithError(err).Debug("Failed to list S3 secrets")
-		return map[string]interface{}{}, nil
-	}
-
-	secretsMap := make(map[string]interface{})
-	for _, secretName := range secrets {
-		secretData, err := p.s3Store.ReadSecret(ctx, targetName, secretName)
-		if err != nil {
-			l.WithError(err).WithField("secretName", secretName).Debug("Failed to read secret")
-			continue
-		}
-		secretsMap[secretName] = secretData
-	}
-
-	return secretsMap, nil
+	return secrets
 }
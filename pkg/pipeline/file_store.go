@@ -0,0 +1,304 @@
+// Package pipeline provides a local filesystem merge store implementation.
+package pipeline
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fileBundleSuffix is the extension used for encrypted bundle files
+const fileBundleSuffix = ".json.enc"
+
+// FileMergeStore implements a merge store on the local filesystem.
+// Each bundle is a single AES-256-GCM encrypted JSON file at
+// {path}/{target}/{bundle_id}.json.enc. This lets the merge phase run in CI
+// or on a laptop without an S3 bucket or a Vault merge mount.
+type FileMergeStore struct {
+	Path string
+
+	aead cipher.AEAD
+}
+
+// NewFileMergeStore creates a filesystem merge store and loads its encryption key
+func NewFileMergeStore(cfg *MergeStoreFile) (*FileMergeStore, error) {
+	l := log.WithFields(log.Fields{
+		"action": "NewFileMergeStore",
+		"path":   cfg.Path,
+	})
+	l.Debug("Creating file merge store")
+
+	key, err := loadFileStoreKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &FileMergeStore{
+		Path: cfg.Path,
+		aead: aead,
+	}, nil
+}
+
+// loadFileStoreKey decodes the base64 AES-256 key from config or key file
+func loadFileStoreKey(cfg *MergeStoreFile) ([]byte, error) {
+	encoded := cfg.Key
+	if encoded == "" && cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read merge_store.file.key_file: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded == "" {
+		return nil, errors.New("merge_store.file requires key or key_file")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("merge_store.file key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("merge_store.file key must be 32 bytes (AES-256), got %d", len(key))
+	}
+	return key, nil
+}
+
+// targetDir returns the directory holding all bundles for a target
+func (s *FileMergeStore) targetDir(targetName string) string {
+	return filepath.Join(s.Path, targetName)
+}
+
+// bundleFile returns the file path for a bundle
+func (s *FileMergeStore) bundleFile(targetName, bundleID string) string {
+	return filepath.Join(s.targetDir(targetName), bundleID+fileBundleSuffix)
+}
+
+// GetBundlePath returns the file:// location of a bundle
+func (s *FileMergeStore) GetBundlePath(targetName, bundleID string) string {
+	return "file://" + s.bundleFile(targetName, bundleID)
+}
+
+// WriteMergedBundle encrypts and writes a complete merged bundle
+func (s *FileMergeStore) WriteMergedBundle(ctx context.Context, targetName, bundleID string, secrets map[string]interface{}) error {
+	l := log.WithFields(log.Fields{
+		"action":   "FileMergeStore.WriteMergedBundle",
+		"target":   targetName,
+		"bundleID": bundleID,
+	})
+	l.Debug("Writing merged bundle to file")
+
+	jsonData, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	// Bind the ciphertext to its location so bundles cannot be swapped between targets
+	if err := s.writeSealed(targetName, bundleID, "bundle", s.bundleFile(targetName, bundleID), targetName+"/"+bundleID, jsonData); err != nil {
+		return err
+	}
+
+	l.WithField("secretsCount", len(secrets)).Debug("Successfully wrote bundle to file")
+	return nil
+}
+
+// ReadMergedBundle decrypts and reads a complete merged bundle
+func (s *FileMergeStore) ReadMergedBundle(ctx context.Context, targetName, bundleID string) (map[string]map[string]interface{}, error) {
+	l := log.WithFields(log.Fields{
+		"action":   "FileMergeStore.ReadMergedBundle",
+		"target":   targetName,
+		"bundleID": bundleID,
+	})
+	l.Debug("Reading merged bundle from file")
+
+	plaintext, err := s.readSealed("bundle", s.bundleFile(targetName, bundleID), targetName+"/"+bundleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawData map[string]interface{}
+	if err := json.Unmarshal(plaintext, &rawData); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bundle: %w", err)
+	}
+
+	result := make(map[string]map[string]interface{})
+	for k, v := range rawData {
+		if m, ok := v.(map[string]interface{}); ok {
+			result[k] = m
+		}
+	}
+
+	return result, nil
+}
+
+// ListBundles returns the IDs of all bundles stored for a target
+func (s *FileMergeStore) ListBundles(ctx context.Context, targetName string) ([]string, error) {
+	entries, err := os.ReadDir(s.targetDir(targetName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list bundles: %w", err)
+	}
+
+	var bundles []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileBundleSuffix) || strings.HasSuffix(name, provenanceSuffix+fileBundleSuffix) || strings.HasSuffix(name, manifestSuffix+fileBundleSuffix) {
+			continue
+		}
+		bundles = append(bundles, strings.TrimSuffix(name, fileBundleSuffix))
+	}
+	sort.Strings(bundles)
+
+	return bundles, nil
+}
+
+// DeleteBundle removes a bundle file
+func (s *FileMergeStore) DeleteBundle(ctx context.Context, targetName, bundleID string) error {
+	if err := os.Remove(s.bundleFile(targetName, bundleID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete bundle: %w", err)
+	}
+	if err := os.Remove(s.provenanceFile(targetName, bundleID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete bundle provenance: %w", err)
+	}
+	if err := os.Remove(s.manifestFile(targetName, bundleID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete bundle manifest: %w", err)
+	}
+	return nil
+}
+
+// provenanceFile returns the file path for a bundle's provenance
+func (s *FileMergeStore) provenanceFile(targetName, bundleID string) string {
+	return filepath.Join(s.targetDir(targetName), bundleID+provenanceSuffix+fileBundleSuffix)
+}
+
+// WriteProvenance encrypts and writes a bundle's provenance next to the bundle
+func (s *FileMergeStore) WriteProvenance(ctx context.Context, targetName, bundleID string, prov *Provenance) error {
+	jsonData, err := json.Marshal(prov)
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	aad := targetName + "/" + bundleID + provenanceSuffix
+	return s.writeSealed(targetName, bundleID, "provenance", s.provenanceFile(targetName, bundleID), aad, jsonData)
+}
+
+// ReadProvenance decrypts and reads a bundle's provenance
+func (s *FileMergeStore) ReadProvenance(ctx context.Context, targetName, bundleID string) (*Provenance, error) {
+	plaintext, err := s.readSealed("provenance", s.provenanceFile(targetName, bundleID), targetName+"/"+bundleID+provenanceSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoProvenance
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var prov Provenance
+	if err := json.Unmarshal(plaintext, &prov); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provenance: %w", err)
+	}
+	return &prov, nil
+}
+
+// manifestFile returns the file path for a bundle's manifest
+func (s *FileMergeStore) manifestFile(targetName, bundleID string) string {
+	return filepath.Join(s.targetDir(targetName), bundleID+manifestSuffix+fileBundleSuffix)
+}
+
+// WriteManifest encrypts and writes a bundle's manifest next to the bundle
+func (s *FileMergeStore) WriteManifest(ctx context.Context, targetName, bundleID string, manifest *BundleManifest) error {
+	jsonData, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	aad := targetName + "/" + bundleID + manifestSuffix
+	return s.writeSealed(targetName, bundleID, "manifest", s.manifestFile(targetName, bundleID), aad, jsonData)
+}
+
+// ReadManifest decrypts and reads a bundle's manifest
+func (s *FileMergeStore) ReadManifest(ctx context.Context, targetName, bundleID string) (*BundleManifest, error) {
+	plaintext, err := s.readSealed("manifest", s.manifestFile(targetName, bundleID), targetName+"/"+bundleID+manifestSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(plaintext, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// writeSealed encrypts data bound to aad and writes it to file; what names
+// the content in errors. The file is written to a temporary name and renamed
+// into place so readers never observe a partially written file.
+func (s *FileMergeStore) writeSealed(targetName, bundleID, what, file, aad string, data []byte) error {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := s.aead.Seal(nonce, nonce, data, []byte(aad))
+
+	dir := s.targetDir(targetName)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, bundleID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(sealed); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", what, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", what, err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", what, err)
+	}
+	return nil
+}
+
+// readSealed reads and decrypts a file written by writeSealed
+func (s *FileMergeStore) readSealed(what, file, aad string) ([]byte, error) {
+	sealed, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", what, err)
+	}
+
+	nonceSize := s.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("%s file is truncated", what)
+	}
+	plaintext, err := s.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(aad))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", what, err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFileStoreKey() string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x42}, 32))
+}
+
+func newTestFileStore(t *testing.T) *FileMergeStore {
+	t.Helper()
+	store, err := NewFileMergeStore(&MergeStoreFile{
+		Path: t.TempDir(),
+		Key:  testFileStoreKey(),
+	})
+	require.NoError(t, err)
+	return store
+}
+
+func TestFileMergeStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileStore(t)
+
+	secrets := map[string]interface{}{
+		"api/key": map[string]interface{}{"token": "super-secret-value"},
+		"db":      map[string]interface{}{"user": "admin", "port": float64(5432)},
+	}
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc123", secrets))
+
+	got, err := store.ReadMergedBundle(ctx, "Stg", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"api/key": {"token": "super-secret-value"},
+		"db":      {"user": "admin", "port": float64(5432)},
+	}, got)
+
+	// Bundle must be encrypted at rest
+	raw, err := os.ReadFile(filepath.Join(store.Path, "Stg", "abc123"+fileBundleSuffix))
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "super-secret-value")
+
+	info, err := os.Stat(filepath.Join(store.Path, "Stg"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+}
+
+func TestFileMergeStoreRejectsMovedBundle(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileStore(t)
+
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc123", map[string]interface{}{
+		"api": map[string]interface{}{"key": "v"},
+	}))
+
+	// Copy the ciphertext under another target; authentication must fail
+	raw, err := os.ReadFile(filepath.Join(store.Path, "Stg", "abc123"+fileBundleSuffix))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(store.Path, "Prod"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(store.Path, "Prod", "abc123"+fileBundleSuffix), raw, 0600))
+
+	_, err = store.ReadMergedBundle(ctx, "Prod", "abc123")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decrypt bundle")
+}
+
+func TestFileMergeStoreListAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileStore(t)
+
+	bundles, err := store.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Empty(t, bundles)
+
+	for _, id := range []string{"bbb", "aaa"} {
+		require.NoError(t, store.WriteMergedBundle(ctx, "Stg", id, map[string]interface{}{}))
+	}
+
+	bundles, err = store.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aaa", "bbb"}, bundles)
+
+	require.NoError(t, store.DeleteBundle(ctx, "Stg", "aaa"))
+	require.NoError(t, store.DeleteBundle(ctx, "Stg", "missing"))
+
+	bundles, err = store.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bbb"}, bundles)
+
+	_, err = store.ReadMergedBundle(ctx, "Stg", "aaa")
+	assert.Error(t, err)
+}
+
+func TestFileMergeStoreGetBundlePath(t *testing.T) {
+	store := &FileMergeStore{Path: "/var/lib/secretsync"}
+	assert.Equal(t, "file:///var/lib/secretsync/Stg/abc123.json.enc", store.GetBundlePath("Stg", "abc123"))
+}
+
+func TestNewFileMergeStoreKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(testFileStoreKey()+"\n"), 0600))
+
+	tests := []struct {
+		name    string
+		cfg     MergeStoreFile
+		wantErr string
+	}{
+		{
+			name: "inline key",
+			cfg:  MergeStoreFile{Path: "/tmp", Key: testFileStoreKey()},
+		},
+		{
+			name: "key file",
+			cfg:  MergeStoreFile{Path: "/tmp", KeyFile: keyFile},
+		},
+		{
+			name:    "missing key",
+			cfg:     MergeStoreFile{Path: "/tmp"},
+			wantErr: "requires key or key_file",
+		},
+		{
+			name:    "not base64",
+			cfg:     MergeStoreFile{Path: "/tmp", Key: "not base64!"},
+			wantErr: "not valid base64",
+		},
+		{
+			name:    "wrong length",
+			cfg:     MergeStoreFile{Path: "/tmp", Key: base64.StdEncoding.EncodeToString([]byte("short"))},
+			wantErr: "must be 32 bytes",
+		},
+		{
+			name:    "missing key file",
+			cfg:     MergeStoreFile{Path: "/tmp", KeyFile: filepath.Join(t.TempDir(), "nope")},
+			wantErr: "failed to read merge_store.file.key_file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewFileMergeStore(&tt.cfg)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.True(t, strings.Contains(err.Error(), tt.wantErr), err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, store)
+		})
+	}
+}
+
+func TestNewMergeStore(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := NewMergeStore(ctx, &Config{})
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	store, err = NewMergeStore(ctx, &Config{
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &VaultMergeStore{}, store)
+	assert.Equal(t, "merged/targets/Stg/abc", store.GetBundlePath("Stg", "abc"))
+
+	store, err = NewMergeStore(ctx, &Config{
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &FileMergeStore{}, store)
+}
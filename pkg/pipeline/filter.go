@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+)
+
+// secretFilter is a compiled FilterConfig, matched against secret paths relative
+// to the source or bundle. A secret is kept when it matches every include list
+// that is set and no exclude pattern. It counts the secrets it filters out.
+type secretFilter struct {
+	regexInclude []*regexp.Regexp
+	regexExclude []*regexp.Regexp
+	pathInclude  []string
+	pathExclude  []string
+
+	filtered int
+}
+
+// newSecretFilter compiles a FilterConfig. A nil config keeps every secret.
+func newSecretFilter(cfg *v1alpha1.FilterConfig) (*secretFilter, error) {
+	f := &secretFilter{}
+	if cfg == nil {
+		return f, nil
+	}
+
+	compile := func(field string, patterns []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, 0, len(patterns))
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("filters.regex.%s[%d] is invalid regex: %w", field, i, err)
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+	globs := func(field string, patterns []string) ([]string, error) {
+		for i, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("filters.path.%s[%d] is an invalid pattern: %w", field, i, err)
+			}
+		}
+		return patterns, nil
+	}
+
+	var err error
+	if cfg.Regex != nil {
+		if f.regexInclude, err = compile("include", cfg.Regex.Include); err != nil {
+			return nil, err
+		}
+		if f.regexExclude, err = compile("exclude", cfg.Regex.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Path != nil {
+		if f.pathInclude, err = globs("include", cfg.Path.Include); err != nil {
+			return nil, err
+		}
+		if f.pathExclude, err = globs("exclude", cfg.Path.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// matchPath reports whether a glob matches the secret path or one of its parent
+// directories, so "*/admin" also covers everything below an admin directory
+func matchPath(patterns []string, secretPath string) bool {
+	secretPath = strings.Trim(secretPath, "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		for p := secretPath; p != "." && p != ""; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keep reports whether a secret passes the filter, counting it when it does not.
+// A nil filter keeps everything.
+func (f *secretFilter) keep(secretPath string) bool {
+	if f == nil {
+		return true
+	}
+
+	ok := (len(f.regexInclude) == 0 || matchAny(f.regexInclude, secretPath)) &&
+		!matchAny(f.regexExclude, secretPath) &&
+		(len(f.pathInclude) == 0 || matchPath(f.pathInclude, secretPath)) &&
+		!matchPath(f.pathExclude, secretPath)
+	if !ok {
+		f.filtered++
+	}
+	return ok
+}
+
+// apply returns the secrets that pass the filter
+func (f *secretFilter) apply(secrets map[string]map[string]interface{}) map[string]map[string]interface{} {
+	if f == nil {
+		return secrets
+	}
+	result := make(map[string]map[string]interface{}, len(secrets))
+	for secretPath, data := range secrets {
+		if f.keep(secretPath) {
+			result[secretPath] = data
+		}
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretFilter(t *testing.T) {
+	paths := []string{"app/db", "app/admin/root", "team/admin/keys/ssh", "shared/datadog", "legacy_token"}
+
+	tests := []struct {
+		name     string
+		cfg      *v1alpha1.FilterConfig
+		expected []string
+	}{
+		{
+			name:     "no filters",
+			expected: paths,
+		},
+		{
+			name:     "path exclude covers nested secrets",
+			cfg:      &v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Exclude: []string{"*/admin/*"}}},
+			expected: []string{"app/db", "shared/datadog", "legacy_token"},
+		},
+		{
+			name:     "path include by directory",
+			cfg:      &v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Include: []string{"app", "shared/*"}}},
+			expected: []string{"app/db", "app/admin/root", "shared/datadog"},
+		},
+		{
+			name: "regex and path combined",
+			cfg: &v1alpha1.FilterConfig{
+				Regex: &v1alpha1.RegexpFilterConfig{Include: []string{"^(app|team)/"}, Exclude: []string{"ssh$"}},
+				Path:  &v1alpha1.PathFilterConfig{Exclude: []string{"app/admin"}},
+			},
+			expected: []string{"app/db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newSecretFilter(tt.cfg)
+			require.NoError(t, err)
+
+			var kept []string
+			for _, p := range paths {
+				if f.keep(p) {
+					kept = append(kept, p)
+				}
+			}
+			assert.Equal(t, tt.expected, kept)
+			assert.Equal(t, len(paths)-len(tt.expected), f.filtered)
+		})
+	}
+}
+
+func TestNewSecretFilter_Invalid(t *testing.T) {
+	_, err := newSecretFilter(&v1alpha1.FilterConfig{Regex: &v1alpha1.RegexpFilterConfig{Exclude: []string{"("}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "filters.regex.exclude[0]")
+
+	_, err = newSecretFilter(&v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Include: []string{"[a-"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "filters.path.include[0]")
+}
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/diff"
+)
+
+// Guardrails, as named in GuardrailError and ResultDetails.Guardrail
+const (
+	GuardrailMaxRemoved        = "max_removed"
+	GuardrailMaxChangedPercent = "max_changed_percent"
+	GuardrailRefuseEmpty       = "refuse_empty"
+)
+
+// ExitCodeGuardrail is the exit code of a run in which a guardrail aborted a target
+const ExitCodeGuardrail = 3
+
+// GuardrailError is returned for a target whose merge or sync tripped a
+// guardrail (pipeline.guardrails)
+type GuardrailError struct {
+	Target    string
+	Phase     string
+	Guardrail string
+	Reason    string
+}
+
+func (e *GuardrailError) Error() string {
+	return fmt.Sprintf("guardrail %s tripped for target %s (%s): %s; rerun with --allow-large-changes to proceed", e.Guardrail, e.Target, e.Phase, e.Reason)
+}
+
+// TrippedGuardrails returns the guardrail errors of the results
+func TrippedGuardrails(results []Result) []*GuardrailError {
+	var tripped []*GuardrailError
+	for _, r := range results {
+		var guardErr *GuardrailError
+		if errors.As(r.Error, &guardErr) {
+			tripped = append(tripped, guardErr)
+		}
+	}
+	return tripped
+}
+
+// validate checks that the limits are in range
+func (g GuardrailSettings) validate() error {
+	if g.MaxRemoved != nil && *g.MaxRemoved < 0 {
+		return fmt.Errorf("max_removed must not be negative, got %d", *g.MaxRemoved)
+	}
+	if g.MaxChangedPercent != nil && (*g.MaxChangedPercent < 0 || *g.MaxChangedPercent > 100) {
+		return fmt.Errorf("max_changed_percent must be between 0 and 100, got %g", *g.MaxChangedPercent)
+	}
+	return nil
+}
+
+// enabled reports whether any limit is set
+func (g GuardrailSettings) enabled() bool {
+	return g.MaxRemoved != nil || g.MaxChangedPercent != nil || (g.RefuseEmpty != nil && *g.RefuseEmpty)
+}
+
+// guardrailsFor returns the global guardrails with the target's limits applied
+func (p *Pipeline) guardrailsFor(target Target) GuardrailSettings {
+	g := p.config.Pipeline.Guardrails
+	if o := target.Guardrails; o != nil {
+		if o.MaxRemoved != nil {
+			g.MaxRemoved = o.MaxRemoved
+		}
+		if o.MaxChangedPercent != nil {
+			g.MaxChangedPercent = o.MaxChangedPercent
+		}
+		if o.RefuseEmpty != nil {
+			g.RefuseEmpty = o.RefuseEmpty
+		}
+	}
+	return g
+}
+
+// check compares replacing current with desired against the limits and
+// returns the first guardrail tripped, or nil
+func (g GuardrailSettings) check(targetName, phase string, current, desired map[string]interface{}) *GuardrailError {
+	trip := func(guardrail, format string, args ...interface{}) *GuardrailError {
+		return &GuardrailError{Target: targetName, Phase: phase, Guardrail: guardrail, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if g.RefuseEmpty != nil && *g.RefuseEmpty && len(desired) == 0 {
+		return trip(GuardrailRefuseEmpty, "no secrets to write (%d existing)", len(current))
+	}
+
+	summary := diff.ComputeSummary(diff.DiffSecrets(current, desired))
+	if g.MaxRemoved != nil && summary.Removed > *g.MaxRemoved {
+		return trip(GuardrailMaxRemoved, "%d secrets would be removed (limit %d)", summary.Removed, *g.MaxRemoved)
+	}
+	if g.MaxChangedPercent != nil && len(current) > 0 {
+		changed := summary.Added + summary.Modified + summary.Removed
+		percent := float64(changed) * 100 / float64(len(current))
+		if percent > *g.MaxChangedPercent {
+			return trip(GuardrailMaxChangedPercent, "%d of %d secrets would change (%.0f%%, limit %g%%)", changed, len(current), percent, *g.MaxChangedPercent)
+		}
+	}
+	return nil
+}
+
+// guardrailResult returns the failed result of a target aborted by a guardrail
+func guardrailResult(guardErr *GuardrailError, start time.Time, targetDiff *diff.TargetDiff) Result {
+	return Result{
+		Target:   guardErr.Target,
+		Phase:    guardErr.Phase,
+		Success:  false,
+		Error:    guardErr,
+		Duration: time.Since(start),
+		Details:  ResultDetails{Guardrail: guardErr.Guardrail},
+		Diff:     targetDiff,
+	}
+}
@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestGuardrailSettings_Check(t *testing.T) {
+	current := map[string]interface{}{
+		"a": map[string]interface{}{"k": "1"},
+		"b": map[string]interface{}{"k": "2"},
+		"c": map[string]interface{}{"k": "3"},
+		"d": map[string]interface{}{"k": "4"},
+	}
+
+	tests := []struct {
+		name          string
+		guardrails    GuardrailSettings
+		current       map[string]interface{}
+		desired       map[string]interface{}
+		wantGuardrail string
+		wantReason    string
+	}{
+		{
+			name:       "no limits",
+			guardrails: GuardrailSettings{},
+			current:    current,
+			desired:    map[string]interface{}{},
+		},
+		{
+			name:          "empty refused",
+			guardrails:    GuardrailSettings{RefuseEmpty: ptr(true)},
+			current:       current,
+			desired:       map[string]interface{}{},
+			wantGuardrail: GuardrailRefuseEmpty,
+			wantReason:    "no secrets to write (4 existing)",
+		},
+		{
+			name:       "removals within limit",
+			guardrails: GuardrailSettings{MaxRemoved: ptr(2)},
+			current:    current,
+			desired:    map[string]interface{}{"a": current["a"], "b": current["b"]},
+		},
+		{
+			name:          "too many removals",
+			guardrails:    GuardrailSettings{MaxRemoved: ptr(1)},
+			current:       current,
+			desired:       map[string]interface{}{"a": current["a"], "b": current["b"]},
+			wantGuardrail: GuardrailMaxRemoved,
+			wantReason:    "2 secrets would be removed (limit 1)",
+		},
+		{
+			name:       "changes within percentage",
+			guardrails: GuardrailSettings{MaxChangedPercent: ptr(25.0)},
+			current:    current,
+			desired:    map[string]interface{}{"a": map[string]interface{}{"k": "x"}, "b": current["b"], "c": current["c"], "d": current["d"]},
+		},
+		{
+			name:          "too many changes",
+			guardrails:    GuardrailSettings{MaxChangedPercent: ptr(25.0)},
+			current:       current,
+			desired:       map[string]interface{}{"a": map[string]interface{}{"k": "x"}, "b": current["b"], "c": current["c"], "e": current["d"]},
+			wantGuardrail: GuardrailMaxChangedPercent,
+			wantReason:    "3 of 4 secrets would change (75%, limit 25%)",
+		},
+		{
+			name:       "first population",
+			guardrails: GuardrailSettings{MaxChangedPercent: ptr(10.0), MaxRemoved: ptr(0)},
+			current:    map[string]interface{}{},
+			desired:    current,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guardErr := tt.guardrails.check("Stg", "merge", tt.current, tt.desired)
+			if tt.wantGuardrail == "" {
+				assert.Nil(t, guardErr)
+				return
+			}
+			require.NotNil(t, guardErr)
+			assert.Equal(t, tt.wantGuardrail, guardErr.Guardrail)
+			assert.Equal(t, tt.wantReason, guardErr.Reason)
+		})
+	}
+}
+
+func TestPipeline_GuardrailsFor(t *testing.T) {
+	p := &Pipeline{config: &Config{Pipeline: PipelineSettings{
+		Guardrails: GuardrailSettings{MaxRemoved: ptr(5), RefuseEmpty: ptr(true)},
+	}}}
+
+	g := p.guardrailsFor(Target{Guardrails: &GuardrailSettings{MaxRemoved: ptr(50), RefuseEmpty: ptr(false)}})
+	assert.Equal(t, 50, *g.MaxRemoved)
+	assert.False(t, *g.RefuseEmpty)
+	assert.Nil(t, g.MaxChangedPercent)
+
+	g = p.guardrailsFor(Target{})
+	assert.Equal(t, 5, *g.MaxRemoved)
+	assert.True(t, g.enabled())
+}
+
+func TestPipeline_Guardrails(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	src, srcSrv := newFakeKV(t, "analytics")
+	dst, dstSrv := newFakeKV(t, "replica")
+	for _, name := range []string{"db", "cache", "queue"} {
+		src.put(name, map[string]interface{}{"password": name})
+	}
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+			},
+		},
+		Pipeline: PipelineSettings{Guardrails: GuardrailSettings{MaxRemoved: ptr(1)}},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	// Populating an empty target removes nothing
+	_, err = p.Run(ctx, Options{Operation: OperationPipeline})
+	require.NoError(t, err)
+	require.Len(t, dst.data, 3)
+
+	// An emptied source mount would remove every secret of the bundle
+	src.mu.Lock()
+	delete(src.data, "cache")
+	delete(src.data, "queue")
+	src.mu.Unlock()
+
+	results, err := p.Run(ctx, Options{Operation: OperationPipeline})
+	require.Error(t, err)
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Success)
+	assert.Equal(t, "merge", results[0].Phase)
+	assert.Equal(t, GuardrailMaxRemoved, results[0].Details.Guardrail)
+	assert.EqualError(t, results[0].Error, "guardrail max_removed tripped for target Stg (merge): 2 secrets would be removed (limit 1); rerun with --allow-large-changes to proceed")
+	assert.Len(t, TrippedGuardrails(results), 1)
+	assert.Equal(t, ExitCodeGuardrail, p.ExitCode())
+
+	// Nothing was written
+	bundle, err := p.readTargetBundle(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Len(t, bundle, 3)
+
+	_, err = p.Plan(ctx, Options{})
+	var guardErr *GuardrailError
+	require.ErrorAs(t, err, &guardErr)
+	assert.Equal(t, "merge", guardErr.Phase)
+
+	results, err = p.Run(ctx, Options{Operation: OperationPipeline, AllowLargeChanges: true})
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.True(t, r.Success, r.Phase)
+	}
+	bundle, err = p.readTargetBundle(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Len(t, bundle, 1)
+}
+
+func TestPipeline_GuardrailsRefuseEmptySync(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	src, srcSrv := newFakeKV(t, "analytics")
+	_, dstSrv := newFakeKV(t, "replica")
+	src.put("db", map[string]interface{}{"password": "hunter2"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+				// Target filters drop every secret of the bundle
+				Filters:    &v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Exclude: []string{"*"}}},
+				Guardrails: &GuardrailSettings{RefuseEmpty: ptr(true)},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	results, err := p.Run(ctx, Options{Operation: OperationPipeline, ContinueOnError: true})
+	require.Error(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, "sync", results[1].Phase)
+	assert.Equal(t, GuardrailRefuseEmpty, results[1].Details.Guardrail)
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	log "github.com/sirupsen/logrus"
+)
+
+// errNoBundleHistory is returned when the merge store does not record bundle versions
+var errNoBundleHistory = errors.New("merge store does not record bundle versions: use merge_store.s3 with versioning.enabled")
+
+// bundleVersionPath returns the version store path of a target's bundle history
+func bundleVersionPath(targetName string) string {
+	return targetName + "/bundle"
+}
+
+// bundleHash returns the SHA-256 of a bundle's JSON encoding. Map keys are
+// encoded in sorted order, so equal bundles have equal hashes. Numbers are
+// hashed as the float64 the file and S3 stores decode them to, so a merged
+// bundle with json.Number values hashes the same once read back from any store.
+func bundleHash(secrets map[string]interface{}) (string, error) {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash bundle: %w", err)
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return "", fmt.Errorf("failed to hash bundle: %w", err)
+	}
+	if data, err = json.Marshal(normalized); err != nil {
+		return "", fmt.Errorf("failed to hash bundle: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// bundleHistory returns the merge store's bundle history for a configured target
+func (p *Pipeline) bundleHistory(targetName string) (BundleHistory, error) {
+	if _, ok := p.config.Targets[targetName]; !ok {
+		return nil, fmt.Errorf("target not found: %s", targetName)
+	}
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+	history, ok := p.mergeStore.(BundleHistory)
+	if s3Store, isS3 := p.mergeStore.(*S3MergeStore); !ok || (isS3 && !s3Store.VersioningEnabled) {
+		return nil, errNoBundleHistory
+	}
+	return history, nil
+}
+
+// History returns the recorded bundles of a target, newest first
+func (p *Pipeline) History(ctx context.Context, targetName string) ([]SecretVersion, error) {
+	history, err := p.bundleHistory(targetName)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := history.ListBundleVersions(ctx, targetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundle versions: %w", err)
+	}
+	return versions, nil
+}
+
+// Rollback syncs a recorded version of a target's bundle to its destination,
+// the way the sync phase syncs the current bundle. The diff against the
+// destination is always computed and available from Diff. The merge store is
+// not changed, so the next merge and sync restore the bundle of the sources.
+// Only opts.DryRun, opts.LockTimeout and opts.AllowLargeChanges are used.
+func (p *Pipeline) Rollback(ctx context.Context, targetName string, version int, opts Options) (Result, error) {
+	dryRun := opts.DryRun
+	reqCtx := reqctx.NewRequestContext()
+	ctx = reqctx.WithRequestContext(ctx, reqCtx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history, err := p.bundleHistory(targetName)
+	if err != nil {
+		return Result{}, err
+	}
+	bundleVersion, err := history.GetBundleVersion(ctx, targetName, version)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get bundle version %d: %w", version, err)
+	}
+
+	secretsData := make(map[string]map[string]interface{}, len(bundleVersion.Data))
+	for relPath, data := range bundleVersion.Data {
+		if m, ok := data.(map[string]interface{}); ok {
+			secretsData[relPath] = m
+		}
+	}
+
+	p.resultsMu.Lock()
+	p.results = nil
+	p.resultsMu.Unlock()
+	p.initDiff(dryRun, "")
+
+	bundlePath := fmt.Sprintf("%s@v%d", p.mergeStore.GetBundlePath(targetName, bundleVersion.BundleID), version)
+	l := log.WithFields(log.Fields{
+		"action":     "Pipeline.Rollback",
+		"target":     targetName,
+		"version":    version,
+		"hash":       bundleVersion.Hash,
+		"dryRun":     dryRun,
+		"request_id": reqCtx.RequestID,
+	})
+	runCtx, unlock, err := p.lockRun(ctx, []string{targetName}, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	l.WithField("recorded", bundleVersion.Timestamp).Info("Rolling back target to recorded bundle")
+
+	result := p.syncBundle(runCtx, l, time.Now(), targetName, p.config.Targets[targetName], bundlePath, secretsData, dryRun, opts.AllowLargeChanges)
+	if lockErr := unlock(); lockErr != nil && result.Success {
+		result.Success = false
+		result.Error = lockErr
+	}
+	p.resultsMu.Lock()
+	p.results = []Result{result}
+	p.resultsMu.Unlock()
+	return result, nil
+}
@@ -0,0 +1,131 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_Rollback(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	src, srcSrv := newFakeKV(t, "analytics")
+	dst, dstSrv := newFakeKV(t, "replica")
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Team_EU": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	p.mergeStore, _ = newFakeS3Store(t, 10)
+
+	src.put("db", map[string]interface{}{"password": "hunter2"})
+	_, err = p.Run(ctx, Options{Operation: OperationPipeline})
+	require.NoError(t, err)
+	src.put("db", map[string]interface{}{"password": "leaked"})
+	src.put("cache", map[string]interface{}{"url": "redis://"})
+	_, err = p.Run(ctx, Options{Operation: OperationPipeline})
+	require.NoError(t, err)
+
+	versions, err := p.History(ctx, "Team_EU")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version)
+	assert.Equal(t, BundleID(cfg.GetTargetSourcePaths("Team_EU")), versions[0].BundleID)
+
+	// A dry run reports the diff without writing
+	result, err := p.Rollback(ctx, "Team_EU", 1, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	require.NotNil(t, p.Diff())
+	assert.Equal(t, 1, p.Diff().Summary.Modified)
+	assert.Equal(t, map[string]interface{}{"password": "leaked"}, dst.data["db"])
+	assert.Contains(t, result.Details.SourcePaths[0], "@v1")
+	assert.Equal(t, []Result{result}, p.Results())
+
+	result, err = p.Rollback(ctx, "Team_EU", 1, Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, map[string]interface{}{"password": "hunter2"}, dst.data["db"])
+	// Without delete_orphans, secrets added since stay
+	assert.Contains(t, dst.data, "cache")
+
+	// The merge store keeps the latest bundle
+	versions, err = p.History(ctx, "Team_EU")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+}
+
+func TestPipeline_HistoryErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		mergeStore func(t *testing.T) MergeStore
+		target     string
+		wantErr    string
+	}{
+		{
+			name:       "unknown target",
+			mergeStore: func(t *testing.T) MergeStore { s, _ := newFakeS3Store(t, 10); return s },
+			target:     "Nope",
+			wantErr:    "target not found: Nope",
+		},
+		{
+			name:       "no merge store",
+			mergeStore: func(t *testing.T) MergeStore { return nil },
+			target:     "Stg",
+			wantErr:    "no merge store configured",
+		},
+		{
+			name: "versioning disabled",
+			mergeStore: func(t *testing.T) MergeStore {
+				s, _ := newFakeS3Store(t, 10)
+				s.VersioningEnabled = false
+				return s
+			},
+			target:  "Stg",
+			wantErr: "merge store does not record bundle versions",
+		},
+		{
+			name: "store without versions",
+			mergeStore: func(t *testing.T) MergeStore {
+				s, err := NewFileMergeStore(&MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()})
+				require.NoError(t, err)
+				return s
+			},
+			target:  "Stg",
+			wantErr: "merge store does not record bundle versions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pipeline{
+				config:     &Config{Targets: map[string]Target{"Stg": {}}},
+				mergeStore: tt.mergeStore(t),
+			}
+			_, err := p.History(context.Background(), tt.target)
+			assert.ErrorContains(t, err, tt.wantErr)
+			_, err = p.Rollback(context.Background(), tt.target, 1, Options{DryRun: true})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("missing version", func(t *testing.T) {
+		s, _ := newFakeS3Store(t, 10)
+		p := &Pipeline{config: &Config{Targets: map[string]Target{"Stg": {}}}, mergeStore: s}
+		_, err := p.Rollback(context.Background(), "Stg", 3, Options{DryRun: true})
+		assert.ErrorContains(t, err, "failed to get bundle version 3")
+	})
+}
@@ -101,12 +101,21 @@ func (c *Config) GetSourcePath(importName string) string {
 		if src.Vault != nil {
 			return src.Vault.Mount
 		}
+		if src.AWS != nil {
+			region := src.AWS.Region
+			if region == "" {
+				region = c.AWS.Region
+			}
+			return fmt.Sprintf("aws://%s/%s/%s", src.AWS.AccountID, region, src.AWS.Prefix)
+		}
 	}
 
 	if _, ok := c.Targets[importName]; ok {
 		if c.MergeStore.Vault != nil {
 			return fmt.Sprintf("%s/%s", c.MergeStore.Vault.Mount, importName)
 		}
+		// Non-Vault merge stores address inherited bundles by target name
+		return importName
 	}
 
 	// Rate-limit warnings to prevent log spam on repeated calls
@@ -118,6 +127,20 @@ func (c *Config) GetSourcePath(importName string) string {
 	return importName
 }
 
+// GetTargetSourcePaths returns the source paths for a target's imports in order.
+// The sequence determines deepmerge priority and the target's bundle ID.
+func (c *Config) GetTargetSourcePaths(targetName string) []string {
+	target, ok := c.Targets[targetName]
+	if !ok {
+		return nil
+	}
+	sourcePaths := make([]string, 0, len(target.Imports))
+	for _, importName := range target.Imports {
+		sourcePaths = append(sourcePaths, c.GetSourcePath(importName))
+	}
+	return sourcePaths
+}
+
 // GetRoleARN returns the role ARN for a target account
 func (c *Config) GetRoleARN(accountID string) string {
 	for _, target := range c.Targets {
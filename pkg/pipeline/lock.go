@@ -0,0 +1,468 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	log "github.com/sirupsen/logrus"
+)
+
+// Run lock defaults
+const (
+	defaultLockName = "pipeline"
+	defaultLockTTL  = 5 * time.Minute
+)
+
+// lockPollInterval is how often a lock held by another run is checked while waiting
+var lockPollInterval = 2 * time.Second
+
+// errLockConflict is returned by a lock backend when the lock changed since it was read
+var errLockConflict = errors.New("lock was changed by another run")
+
+// LockHeldError is returned when a run lock is still held by another run
+// after the lock timeout
+type LockHeldError struct {
+	Lock string
+	// Holder is the request ID of the run holding the lock
+	Holder    string
+	Host      string
+	ExpiresAt time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	return fmt.Sprintf("run lock %q is held by request %s on host %q until %s (unless refreshed); wait for it or raise --lock-timeout",
+		e.Lock, e.Holder, e.Host, e.ExpiresAt.Format(time.RFC3339))
+}
+
+// lockRecord is the stored state of a run lock
+type lockRecord struct {
+	Holder     string    `json:"holder"`
+	Host       string    `json:"host,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// heldAt reports whether the lock is held at now
+func (r *lockRecord) heldAt(now time.Time) bool {
+	return r != nil && r.Holder != "" && now.Before(r.ExpiresAt)
+}
+
+// lockBackend stores lock records with compare-and-swap writes
+type lockBackend interface {
+	// read returns the lock's record and revision, or nil and "" if it does not exist
+	read(ctx context.Context, name string) (*lockRecord, string, error)
+	// write replaces the record if its revision is still rev ("" if it must not
+	// exist yet) and returns the new revision, or errLockConflict
+	write(ctx context.Context, name string, rec *lockRecord, rev string) (string, error)
+}
+
+func (c *Config) validateLock() error {
+	lock := c.Pipeline.Lock
+	switch lock.Scope {
+	case "", "config", "target":
+	default:
+		return fmt.Errorf("scope must be config or target, got %q", lock.Scope)
+	}
+	if lock.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+
+	switch lock.Backend {
+	case "":
+	case "vault":
+		if lock.Mount == "" && c.MergeStore.Vault == nil {
+			return fmt.Errorf("mount is required unless merge_store.vault is configured")
+		}
+	case "s3":
+		if lock.Bucket == "" && c.MergeStore.S3 == nil {
+			return fmt.Errorf("bucket is required unless merge_store.s3 is configured")
+		}
+	default:
+		return fmt.Errorf("backend must be vault or s3, got %q", lock.Backend)
+	}
+	return nil
+}
+
+// newLockBackend creates the backend configured in pipeline.lock
+func (p *Pipeline) newLockBackend(ctx context.Context) (lockBackend, error) {
+	cfg := p.config.Pipeline.Lock
+	switch cfg.Backend {
+	case "vault":
+		mount := cfg.Mount
+		if mount == "" {
+			mount = p.config.MergeStore.Vault.Mount
+		}
+		client := newVaultClient(&p.config.Vault)
+		if err := client.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to init lock vault client: %w", err)
+		}
+		return &vaultLockBackend{client: client, mount: mount}, nil
+
+	case "s3":
+		backend := &s3LockBackend{bucket: cfg.Bucket, prefix: cfg.Prefix}
+		if backend.bucket == "" {
+			backend.bucket = p.config.MergeStore.S3.Bucket
+			if backend.prefix == "" {
+				backend.prefix = p.config.MergeStore.S3.Prefix
+			}
+		}
+		if store, ok := p.mergeStore.(*S3MergeStore); ok && store.client != nil {
+			backend.client = store.client
+		} else {
+			awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(p.config.AWS.Region))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load AWS config: %w", err)
+			}
+			backend.client = s3.NewFromConfig(awsCfg)
+		}
+		return backend, nil
+	}
+	return nil, fmt.Errorf("unknown lock backend %q", cfg.Backend)
+}
+
+// lockNames returns the locks a run of targets takes, in acquisition order
+func (p *Pipeline) lockNames(targets []string) []string {
+	cfg := p.config.Pipeline.Lock
+	if cfg.Scope != "target" {
+		if cfg.Name != "" {
+			return []string{cfg.Name}
+		}
+		return []string{defaultLockName}
+	}
+
+	// A fixed order keeps runs of overlapping targets from deadlocking
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, "targets/"+target)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lockRun takes the run locks for targets when pipeline.lock is configured.
+// Locks held by other runs are waited for up to opts.LockTimeout; dry runs do
+// not lock. The returned context is canceled if a lock is lost to another
+// run, and unlock releases the locks, returning the error that lost one.
+func (p *Pipeline) lockRun(ctx context.Context, targets []string, opts Options) (context.Context, func() error, error) {
+	if p.config.Pipeline.Lock.Backend == "" || opts.DryRun {
+		return ctx, func() error { return nil }, nil
+	}
+	if p.locks == nil {
+		backend, err := p.newLockBackend(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.locks = backend
+	}
+
+	ttl := p.config.Pipeline.Lock.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+	host, _ := os.Hostname()
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	var held []*runLock
+	unlock := func() error {
+		lost := context.Cause(runCtx)
+		if errors.Is(lost, context.Canceled) {
+			// Canceled by the caller, not by a lost lock
+			lost = nil
+		}
+		cancel(nil)
+		for i := len(held) - 1; i >= 0; i-- {
+			held[i].release(ctx)
+		}
+		return lost
+	}
+
+	deadline := time.Now().Add(opts.LockTimeout)
+	for _, name := range p.lockNames(targets) {
+		lock := &runLock{
+			backend: p.locks,
+			name:    name,
+			holder:  reqctx.GetRequestID(ctx),
+			host:    host,
+			ttl:     ttl,
+		}
+		if err := lock.acquire(ctx, deadline); err != nil {
+			_ = unlock()
+			return nil, nil, err
+		}
+		lock.heartbeat(ctx, cancel)
+		held = append(held, lock)
+	}
+	return runCtx, unlock, nil
+}
+
+// runLock is one lock held by a run
+type runLock struct {
+	backend lockBackend
+	name    string
+	holder  string
+	host    string
+	ttl     time.Duration
+
+	acquiredAt time.Time
+	// rev is owned by the heartbeat goroutine until it is stopped
+	rev  string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// record returns the lock's record as of a heartbeat at now
+func (l *runLock) record(now time.Time) *lockRecord {
+	return &lockRecord{
+		Holder:     l.holder,
+		Host:       l.host,
+		AcquiredAt: l.acquiredAt,
+		ExpiresAt:  now.Add(l.ttl),
+	}
+}
+
+// acquire takes the lock, polling while another run holds it until deadline
+func (l *runLock) acquire(ctx context.Context, deadline time.Time) error {
+	logger := log.WithFields(log.Fields{
+		"action": "runLock.acquire",
+		"lock":   l.name,
+	})
+	waiting := false
+	for {
+		rec, rev, err := l.backend.read(ctx, l.name)
+		if err != nil {
+			return fmt.Errorf("failed to read run lock %q: %w", l.name, err)
+		}
+
+		now := time.Now()
+		if rec.heldAt(now) && rec.Holder != l.holder {
+			if !now.Before(deadline) {
+				return &LockHeldError{Lock: l.name, Holder: rec.Holder, Host: rec.Host, ExpiresAt: rec.ExpiresAt}
+			}
+			if !waiting {
+				waiting = true
+				logger.WithFields(log.Fields{
+					"holder": rec.Holder,
+					"host":   rec.Host,
+				}).Info("Waiting for run lock held by another run")
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(min(lockPollInterval, deadline.Sub(now))):
+			}
+			continue
+		}
+
+		if rec.heldAt(now) {
+			l.acquiredAt = rec.AcquiredAt
+		} else {
+			l.acquiredAt = now
+		}
+		newRev, err := l.backend.write(ctx, l.name, l.record(now), rev)
+		if errors.Is(err, errLockConflict) {
+			// Another run wrote the lock since it was read
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write run lock %q: %w", l.name, err)
+		}
+		l.rev = newRev
+		logger.WithField("ttl", l.ttl).Debug("Acquired run lock")
+		return nil
+	}
+}
+
+// heartbeat refreshes the lock every TTL/3 until release. If another run took
+// the lock over, lost is called with the reason.
+func (l *runLock) heartbeat(ctx context.Context, lost context.CancelCauseFunc) {
+	l.stop, l.done = make(chan struct{}), make(chan struct{})
+	// Heartbeats continue while a canceled run winds down
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+			}
+
+			rev, err := l.backend.write(ctx, l.name, l.record(time.Now()), l.rev)
+			if errors.Is(err, errLockConflict) {
+				err = fmt.Errorf("run lock %q was taken over by another run", l.name)
+				log.WithError(err).WithField("action", "runLock.heartbeat").Error("Lost run lock, canceling run")
+				lost(err)
+				return
+			}
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"action": "runLock.heartbeat",
+					"lock":   l.name,
+				}).Warn("Failed to refresh run lock")
+				continue
+			}
+			l.rev = rev
+		}
+	}()
+}
+
+// release stops the heartbeat and marks the lock free, unless another run
+// has taken it over
+func (l *runLock) release(ctx context.Context) {
+	close(l.stop)
+	<-l.done
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	released := &lockRecord{AcquiredAt: l.acquiredAt, ExpiresAt: time.Now()}
+	if _, err := l.backend.write(ctx, l.name, released, l.rev); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"action": "runLock.release",
+			"lock":   l.name,
+		}).Warn("Failed to release run lock")
+	}
+}
+
+// vaultLockBackend keeps locks as KV v2 entries under {mount}/locks/ and
+// writes them with check-and-set; the revision is the KV version
+type vaultLockBackend struct {
+	client *vault.VaultClient
+	mount  string
+}
+
+func (b *vaultLockBackend) path(name string) string {
+	return fmt.Sprintf("%s/locks/%s", b.mount, name)
+}
+
+func (b *vaultLockBackend) read(ctx context.Context, name string) (*lockRecord, string, error) {
+	data, version, err := b.client.GetKVSecretVersion(ctx, b.path(name))
+	if err != nil || version == 0 {
+		return nil, "", err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+	var rec lockRecord
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return nil, "", fmt.Errorf("invalid lock record: %w", err)
+	}
+	return &rec, strconv.Itoa(version), nil
+}
+
+func (b *vaultLockBackend) write(ctx context.Context, name string, rec *lockRecord, rev string) (string, error) {
+	cas := 0
+	if rev != "" {
+		var err error
+		if cas, err = strconv.Atoi(rev); err != nil {
+			return "", fmt.Errorf("invalid lock revision %q: %w", rev, err)
+		}
+	}
+
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return "", err
+	}
+
+	if _, err := b.client.WriteSecretOnce(ctx, b.path(name), data, &cas); err != nil {
+		if vault.IsCASConflict(err) {
+			return "", errLockConflict
+		}
+		return "", err
+	}
+	// A successful check-and-set write creates the next version
+	return strconv.Itoa(cas + 1), nil
+}
+
+// s3LockBackend keeps locks as objects under {prefix}locks/ and writes them
+// with If-Match / If-None-Match; the revision is the object's ETag
+type s3LockBackend struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func (b *s3LockBackend) key(name string) string {
+	prefix := b.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return fmt.Sprintf("%slocks/%s.json", prefix, name)
+}
+
+func (b *s3LockBackend) read(ctx context.Context, name string) (*lockRecord, string, error) {
+	output, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get object: %w", err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read body: %w", err)
+	}
+	var rec lockRecord
+	if err := json.Unmarshal(body, &rec); err != nil {
+		return nil, "", fmt.Errorf("invalid lock record: %w", err)
+	}
+	return &rec, aws.ToString(output.ETag), nil
+}
+
+func (b *s3LockBackend) write(ctx context.Context, name string, rec *lockRecord, rev string) (string, error) {
+	body, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(b.key(name)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	}
+	if rev == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(rev)
+	}
+
+	output, err := b.client.PutObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return "", errLockConflict
+		}
+		return "", fmt.Errorf("failed to put object: %w", err)
+	}
+	return aws.ToString(output.ETag), nil
+}
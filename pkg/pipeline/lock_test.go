@@ -0,0 +1,218 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockBackends returns a fresh lock backend of each kind, backed by fakes
+func lockBackends() map[string]func(t *testing.T) lockBackend {
+	return map[string]func(t *testing.T) lockBackend{
+		"vault": func(t *testing.T) lockBackend {
+			_, srv := newFakeKV(t, "merged")
+			t.Setenv("VAULT_TOKEN", "root")
+			client := newVaultClient(&VaultConfig{Address: srv.URL})
+			require.NoError(t, client.Init(context.Background()))
+			return &vaultLockBackend{client: client, mount: "merged"}
+		},
+		"s3": func(t *testing.T) lockBackend {
+			store, _ := newFakeS3Store(t, 0)
+			return &s3LockBackend{client: store.client, bucket: store.Bucket, prefix: store.Prefix}
+		},
+	}
+}
+
+// newLockedPipeline returns a pipeline using backend for pipeline.lock
+func newLockedPipeline(backend lockBackend, lock LockSettings) *Pipeline {
+	lock.Backend = "test"
+	return &Pipeline{config: &Config{Pipeline: PipelineSettings{Lock: lock}}, locks: backend}
+}
+
+// runContext returns a context for a run with its own request ID
+func runContext() (context.Context, string) {
+	reqCtx := reqctx.NewRequestContext()
+	return reqctx.WithRequestContext(context.Background(), reqCtx), reqCtx.RequestID
+}
+
+func TestRunLock(t *testing.T) {
+	for name, newBackend := range lockBackends() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("held by another run", func(t *testing.T) {
+				p := newLockedPipeline(newBackend(t), LockSettings{})
+				ctxA, idA := runContext()
+				ctxB, idB := runContext()
+
+				_, unlockA, err := p.lockRun(ctxA, []string{"Stg"}, Options{})
+				require.NoError(t, err)
+
+				_, _, err = p.lockRun(ctxB, []string{"Stg"}, Options{})
+				var held *LockHeldError
+				require.ErrorAs(t, err, &held)
+				assert.Equal(t, "pipeline", held.Lock)
+				assert.Equal(t, idA, held.Holder)
+				assert.Contains(t, err.Error(), idA)
+
+				require.NoError(t, unlockA())
+				_, unlockB, err := p.lockRun(ctxB, []string{"Stg"}, Options{})
+				require.NoError(t, err)
+				rec, _, err := p.locks.read(ctxB, "pipeline")
+				require.NoError(t, err)
+				assert.Equal(t, idB, rec.Holder)
+				require.NoError(t, unlockB())
+			})
+
+			t.Run("waits for release", func(t *testing.T) {
+				setLockPollInterval(t, 10*time.Millisecond)
+				p := newLockedPipeline(newBackend(t), LockSettings{})
+				ctxA, _ := runContext()
+				ctxB, _ := runContext()
+
+				_, unlockA, err := p.lockRun(ctxA, nil, Options{})
+				require.NoError(t, err)
+				time.AfterFunc(50*time.Millisecond, func() { _ = unlockA() })
+
+				_, unlockB, err := p.lockRun(ctxB, nil, Options{LockTimeout: 5 * time.Second})
+				require.NoError(t, err)
+				require.NoError(t, unlockB())
+			})
+
+			t.Run("expired lock is taken over", func(t *testing.T) {
+				p := newLockedPipeline(newBackend(t), LockSettings{})
+				ctx, _ := runContext()
+				stale := &lockRecord{Holder: "crashed-run", ExpiresAt: time.Now().Add(-time.Minute)}
+				_, err := p.locks.write(ctx, "pipeline", stale, "")
+				require.NoError(t, err)
+
+				_, unlock, err := p.lockRun(ctx, nil, Options{})
+				require.NoError(t, err)
+				require.NoError(t, unlock())
+			})
+
+			t.Run("heartbeat", func(t *testing.T) {
+				p := newLockedPipeline(newBackend(t), LockSettings{TTL: 90 * time.Millisecond})
+				ctx, id := runContext()
+
+				runCtx, unlock, err := p.lockRun(ctx, nil, Options{})
+				require.NoError(t, err)
+
+				// Refreshed beyond the initial TTL
+				time.Sleep(200 * time.Millisecond)
+				rec, rev, err := p.locks.read(ctx, "pipeline")
+				require.NoError(t, err)
+				assert.True(t, rec.heldAt(time.Now()))
+				assert.Equal(t, id, rec.Holder)
+
+				// Another run taking the lock over cancels this one
+				_, err = p.locks.write(ctx, "pipeline", &lockRecord{Holder: "other", ExpiresAt: time.Now().Add(time.Hour)}, rev)
+				require.NoError(t, err)
+				select {
+				case <-runCtx.Done():
+				case <-time.After(5 * time.Second):
+					t.Fatal("run was not canceled")
+				}
+				assert.ErrorContains(t, unlock(), `run lock "pipeline" was taken over`)
+
+				// Release leaves the new holder's lock alone
+				rec, _, err = p.locks.read(ctx, "pipeline")
+				require.NoError(t, err)
+				assert.Equal(t, "other", rec.Holder)
+			})
+
+			t.Run("target scope", func(t *testing.T) {
+				p := newLockedPipeline(newBackend(t), LockSettings{Scope: "target"})
+				ctxA, _ := runContext()
+				ctxB, _ := runContext()
+
+				_, unlockA, err := p.lockRun(ctxA, []string{"Stg"}, Options{})
+				require.NoError(t, err)
+				_, unlockB, err := p.lockRun(ctxB, []string{"Prod"}, Options{})
+				require.NoError(t, err)
+
+				_, _, err = p.lockRun(ctxB, []string{"Stg", "Dev"}, Options{})
+				var held *LockHeldError
+				require.ErrorAs(t, err, &held)
+				assert.Equal(t, "targets/Stg", held.Lock)
+				// Locks taken before the failure are released
+				rec, _, err := p.locks.read(ctxB, "targets/Dev")
+				require.NoError(t, err)
+				assert.False(t, rec.heldAt(time.Now()))
+
+				require.NoError(t, unlockA())
+				require.NoError(t, unlockB())
+			})
+		})
+	}
+}
+
+func TestRunLock_Disabled(t *testing.T) {
+	p := &Pipeline{config: &Config{}}
+	ctx := context.Background()
+	runCtx, unlock, err := p.lockRun(ctx, []string{"Stg"}, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ctx, runCtx)
+	assert.NoError(t, unlock())
+	assert.Nil(t, p.locks)
+
+	// Dry runs do not lock
+	p = newLockedPipeline(nil, LockSettings{})
+	_, unlock, err = p.lockRun(ctx, []string{"Stg"}, Options{DryRun: true})
+	require.NoError(t, err)
+	assert.NoError(t, unlock())
+}
+
+func TestPipeline_RunLockHeld(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	src, srcSrv := newFakeKV(t, "analytics")
+	dst, dstSrv := newFakeKV(t, "replica")
+	src.put("db", map[string]interface{}{"password": "hunter2"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Pipeline:   PipelineSettings{Lock: LockSettings{Backend: "s3", Bucket: "merge"}},
+		Targets: map[string]Target{
+			"Team_EU": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	store, _ := newFakeS3Store(t, 0)
+	p.locks = &s3LockBackend{client: store.client, bucket: "merge"}
+
+	ctx, id := runContext()
+	_, unlock, err := p.lockRun(ctx, nil, Options{})
+	require.NoError(t, err)
+
+	_, err = p.Run(context.Background(), Options{Operation: OperationPipeline})
+	var held *LockHeldError
+	require.True(t, errors.As(err, &held))
+	assert.Equal(t, id, held.Holder)
+	assert.Empty(t, dst.data)
+
+	// A dry run does not need the lock
+	_, err = p.Run(context.Background(), Options{Operation: OperationMerge, DryRun: true})
+	require.NoError(t, err)
+
+	require.NoError(t, unlock())
+	_, err = p.Run(context.Background(), Options{Operation: OperationPipeline})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"password": "hunter2"}, dst.data["db"])
+}
+
+func setLockPollInterval(t *testing.T, d time.Duration) {
+	old := lockPollInterval
+	lockPollInterval = d
+	t.Cleanup(func() { lockPollInterval = old })
+}
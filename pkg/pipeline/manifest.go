@@ -0,0 +1,261 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+)
+
+// manifestSuffix is appended to a bundle's name for the manifest stored next to it
+const manifestSuffix = ".manifest"
+
+// errNoManifest is returned by ReadManifest when no manifest is stored for a bundle
+var errNoManifest = errors.New("no manifest recorded")
+
+// ManifestStore is implemented by merge stores that keep the manifest of each
+// bundle next to it
+type ManifestStore interface {
+	// WriteManifest replaces the manifest of a bundle
+	WriteManifest(ctx context.Context, targetName, bundleID string, manifest *BundleManifest) error
+	// ReadManifest returns the manifest of a bundle, or errNoManifest
+	ReadManifest(ctx context.Context, targetName, bundleID string) (*BundleManifest, error)
+}
+
+// Compile-time interface checks
+var (
+	_ ManifestStore = (*S3MergeStore)(nil)
+	_ ManifestStore = (*VaultMergeStore)(nil)
+	_ ManifestStore = (*FileMergeStore)(nil)
+)
+
+// BundleManifest records what a merge read to produce a bundle. The bundle ID
+// only identifies a target's imports, so the manifest is what tells a fresh
+// bundle from a stale one.
+type BundleManifest struct {
+	Target   string `json:"target"`
+	BundleID string `json:"bundle_id"`
+	// ContentHash is the SHA-256 of the bundle's JSON encoding
+	ContentHash string `json:"content_hash"`
+	// SourceVersions maps Vault source names to the KV version of every
+	// secret read from them, by secret path. Inherited targets contribute the
+	// versions of their own manifest.
+	SourceVersions map[string]map[string]int `json:"source_versions,omitempty"`
+	RequestID      string                    `json:"request_id,omitempty"`
+	CreatedAt      time.Time                 `json:"created_at"`
+	// ConfigDigest is the SHA-256 of the configuration the merge ran with
+	ConfigDigest string `json:"config_digest"`
+}
+
+// StaleBundleError is returned for a target whose bundle fails the freshness
+// requirements of pipeline.sync.freshness
+type StaleBundleError struct {
+	Target string
+	Reason string
+}
+
+func (e *StaleBundleError) Error() string {
+	return fmt.Sprintf("bundle of target %s is stale: %s; run merge again", e.Target, e.Reason)
+}
+
+// validate checks that the freshness requirements are in range
+func (f FreshnessSettings) validate() error {
+	if f.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative, got %s", f.MaxAge)
+	}
+	return nil
+}
+
+// enabled reports whether sync requires anything of a bundle's manifest
+func (f FreshnessSettings) enabled() bool {
+	return f.MaxAge > 0 || f.MatchSources
+}
+
+// newManifest returns the manifest of a bundle about to be written. Versions
+// of inherited targets are taken from their manifests, which are written
+// before those of the targets inheriting them; secrets the target read itself
+// keep the version it read.
+func (p *Pipeline) newManifest(ctx context.Context, targetName string, bundle *mergedBundle) (*BundleManifest, error) {
+	contentHash, err := bundleHash(bundle.secrets)
+	if err != nil {
+		return nil, err
+	}
+	configDigest, err := p.configDigest()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &BundleManifest{
+		Target:         targetName,
+		BundleID:       bundle.bundleID,
+		ContentHash:    contentHash,
+		SourceVersions: make(map[string]map[string]int),
+		RequestID:      reqctx.GetRequestID(ctx),
+		CreatedAt:      time.Now().UTC(),
+		ConfigDigest:   configDigest,
+	}
+	for importName, versions := range bundle.sourceVersions {
+		manifest.SourceVersions[importName] = maps.Clone(versions)
+	}
+
+	store, ok := p.mergeStore.(ManifestStore)
+	if !ok {
+		return manifest, nil
+	}
+	for _, importName := range p.config.Targets[targetName].Imports {
+		if _, isTarget := p.config.Targets[importName]; !isTarget {
+			continue
+		}
+		inherited, err := store.ReadManifest(ctx, importName, BundleID(p.config.GetTargetSourcePaths(importName)))
+		if errors.Is(err, errNoManifest) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest of inherited target %s: %w", importName, err)
+		}
+		for sourceName, versions := range inherited.SourceVersions {
+			if manifest.SourceVersions[sourceName] == nil {
+				manifest.SourceVersions[sourceName] = make(map[string]int, len(versions))
+			}
+			own := bundle.sourceVersions[sourceName]
+			for path, version := range versions {
+				if _, ok := own[path]; !ok {
+					manifest.SourceVersions[sourceName][path] = version
+				}
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// checkFreshness returns a StaleBundleError when the bundle read for a target
+// fails the pipeline.sync.freshness requirements, or nil
+func (p *Pipeline) checkFreshness(ctx context.Context, targetName string, secretsData map[string]map[string]interface{}) error {
+	freshness := p.config.Pipeline.Sync.Freshness
+	if !freshness.enabled() {
+		return nil
+	}
+	stale := func(format string, args ...interface{}) error {
+		return &StaleBundleError{Target: targetName, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	store, ok := p.mergeStore.(ManifestStore)
+	if !ok {
+		return stale("the merge store does not record manifests")
+	}
+	manifest, err := store.ReadManifest(ctx, targetName, BundleID(p.config.GetTargetSourcePaths(targetName)))
+	if errors.Is(err, errNoManifest) {
+		return stale("no manifest recorded")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+
+	secrets := make(map[string]interface{}, len(secretsData))
+	for path, data := range secretsData {
+		secrets[path] = data
+	}
+	contentHash, err := bundleHash(secrets)
+	if err != nil {
+		return err
+	}
+	if contentHash != manifest.ContentHash {
+		return stale("bundle content does not match its manifest")
+	}
+
+	if freshness.MaxAge > 0 {
+		if age := time.Since(manifest.CreatedAt); age > freshness.MaxAge {
+			return stale("merged %s ago (max_age %s)", age.Round(time.Second), freshness.MaxAge)
+		}
+	}
+
+	if freshness.MatchSources {
+		for _, sourceName := range slices.Sorted(maps.Keys(p.vaultSourcesOf(targetName))) {
+			current, err := p.currentSourceVersions(ctx, sourceName)
+			if err != nil {
+				return fmt.Errorf("failed to read versions of source %s: %w", sourceName, err)
+			}
+			if changed := changedVersions(manifest.SourceVersions[sourceName], current); len(changed) > 0 {
+				return stale("source %s changed since the merge for secrets %v", sourceName, changed)
+			}
+		}
+	}
+	return nil
+}
+
+// vaultSourcesOf returns the Vault sources a target reads, directly or through
+// the targets it inherits
+func (p *Pipeline) vaultSourcesOf(targetName string) map[string]bool {
+	sources := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		for _, importName := range p.config.Targets[name].Imports {
+			if _, isTarget := p.config.Targets[importName]; isTarget {
+				walk(importName)
+			} else if src, ok := p.config.Sources[importName]; !ok || src.AWS == nil {
+				sources[importName] = true
+			}
+		}
+	}
+	walk(targetName)
+	return sources
+}
+
+// currentSourceVersions lists a Vault source as a merge would and returns
+// the current KV version of each secret, by secret path
+func (p *Pipeline) currentSourceVersions(ctx context.Context, sourceName string) (map[string]int, error) {
+	src := p.config.Sources[sourceName]
+	cfg := p.sourceVaultConfig(src.Vault)
+	client := newVaultClient(&cfg)
+	if err := client.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	var filter *secretFilter
+	if src.Filters != nil {
+		filter, _ = newSecretFilter(src.Filters)
+	}
+	var paths []string
+	if src.Vault != nil {
+		paths = src.Vault.Paths
+	}
+	secretPaths, err := listVaultSource(ctx, client, p.config.GetSourcePath(sourceName), paths, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]int, len(secretPaths))
+	for _, secretPath := range secretPaths {
+		version, err := client.GetKVSecretCurrentVersion(ctx, secretPath)
+		if err != nil {
+			return nil, err
+		}
+		if version > 0 {
+			versions[secretPath] = version
+		}
+	}
+	return versions, nil
+}
+
+// changedVersions returns the sorted secret paths whose version differs
+// between recorded and current, including paths only in one of them
+func changedVersions(recorded, current map[string]int) []string {
+	var changed []string
+	for path, version := range current {
+		if recorded[path] != version {
+			changed = append(changed, path)
+		}
+	}
+	for path := range recorded {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
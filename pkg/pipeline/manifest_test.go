@@ -0,0 +1,280 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) MergeStore{
+		"file": func(t *testing.T) MergeStore {
+			s, err := NewFileMergeStore(&MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()})
+			require.NoError(t, err)
+			return s
+		},
+		"s3": func(t *testing.T) MergeStore {
+			s, _ := newFakeS3Store(t, 0)
+			return s
+		},
+		"vault": func(t *testing.T) MergeStore {
+			_, srv := newFakeKV(t, "merged")
+			t.Setenv("VAULT_TOKEN", "root")
+			return NewVaultMergeStore(&MergeStoreVault{Mount: "merged"}, &VaultConfig{Address: srv.URL})
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			manifestStore := store.(ManifestStore)
+
+			_, err := manifestStore.ReadManifest(ctx, "Stg", "b1")
+			assert.ErrorIs(t, err, errNoManifest)
+
+			manifest := &BundleManifest{
+				Target:         "Stg",
+				BundleID:       "b1",
+				ContentHash:    "abc",
+				SourceVersions: map[string]map[string]int{"analytics": {"analytics/db": 3}},
+				RequestID:      "req-1",
+				CreatedAt:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				ConfigDigest:   "def",
+			}
+			require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", map[string]interface{}{"db": map[string]interface{}{"password": "x"}}))
+			require.NoError(t, manifestStore.WriteManifest(ctx, "Stg", "b1", manifest))
+
+			got, err := manifestStore.ReadManifest(ctx, "Stg", "b1")
+			require.NoError(t, err)
+			assert.Equal(t, manifest, got)
+
+			// The manifest is not listed or read as part of the bundle
+			bundles, err := store.ListBundles(ctx, "Stg")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b1"}, bundles)
+			secrets, err := store.ReadMergedBundle(ctx, "Stg", "b1")
+			require.NoError(t, err)
+			assert.Len(t, secrets, 1)
+
+			require.NoError(t, store.DeleteBundle(ctx, "Stg", "b1"))
+			_, err = manifestStore.ReadManifest(ctx, "Stg", "b1")
+			assert.ErrorIs(t, err, errNoManifest)
+		})
+	}
+}
+
+func TestPipeline_Manifest(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	analytics, analyticsSrv := newFakeKV(t, "analytics")
+	shared, sharedSrv := newFakeKV(t, "shared")
+	analytics.put("db", map[string]interface{}{"password": "v1"})
+	analytics.put("db", map[string]interface{}{"password": "v2"})
+	shared.put("region", map[string]interface{}{"name": "eu"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: analyticsSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: analyticsSrv.URL, Mount: "analytics"}},
+			"shared":    {Vault: &VaultSource{Address: sharedSrv.URL, Mount: "shared"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"analytics"}},
+			"Prod": {Imports: []string{"Stg", "shared"}},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	results, err := p.Run(ctx, Options{Operation: OperationMerge})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	digest, err := p.configDigest()
+	require.NoError(t, err)
+	store := p.mergeStore.(ManifestStore)
+
+	stg, err := store.ReadManifest(ctx, "Stg", BundleID(cfg.GetTargetSourcePaths("Stg")))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]int{"analytics": {"analytics/db": 2}}, stg.SourceVersions)
+	assert.NotEmpty(t, stg.RequestID)
+	assert.Equal(t, digest, stg.ConfigDigest)
+	assert.WithinDuration(t, time.Now(), stg.CreatedAt, time.Minute)
+
+	bundle, err := p.readTargetBundle(ctx, "Stg")
+	require.NoError(t, err)
+	hash, err := bundleHash(map[string]interface{}{"db": bundle["db"]})
+	require.NoError(t, err)
+	assert.Equal(t, hash, stg.ContentHash)
+
+	// An inheriting target records the versions its parent was merged from
+	prod, err := store.ReadManifest(ctx, "Prod", BundleID(cfg.GetTargetSourcePaths("Prod")))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]int{
+		"analytics": {"analytics/db": 2},
+		"shared":    {"shared/region": 1},
+	}, prod.SourceVersions)
+	assert.Equal(t, stg.RequestID, prod.RequestID)
+}
+
+func TestNewManifest_OwnReadsWin(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"analytics"}},
+			"Prod": {Imports: []string{"Stg", "analytics"}},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	// Stg was merged before analytics/db was rotated
+	store := p.mergeStore.(ManifestStore)
+	require.NoError(t, store.WriteManifest(ctx, "Stg", BundleID(cfg.GetTargetSourcePaths("Stg")), &BundleManifest{
+		Target:         "Stg",
+		SourceVersions: map[string]map[string]int{"analytics": {"analytics/db": 1, "analytics/queue": 4}},
+	}))
+
+	manifest, err := p.newManifest(ctx, "Prod", &mergedBundle{
+		bundleID:       BundleID(cfg.GetTargetSourcePaths("Prod")),
+		secrets:        map[string]interface{}{},
+		sourceVersions: map[string]map[string]int{"analytics": {"analytics/db": 2}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]int{
+		"analytics": {"analytics/db": 2, "analytics/queue": 4},
+	}, manifest.SourceVersions)
+}
+
+func TestPipeline_SyncFreshness(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		freshness FreshnessSettings
+		change    func(t *testing.T, p *Pipeline, src *fakeKV)
+		wantErr   string
+	}{
+		{
+			name:      "fresh",
+			freshness: FreshnessSettings{MaxAge: time.Hour, MatchSources: true},
+			change:    func(t *testing.T, p *Pipeline, src *fakeKV) {},
+		},
+		{
+			name:      "too old",
+			freshness: FreshnessSettings{MaxAge: time.Hour},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				store := p.mergeStore.(ManifestStore)
+				bundleID := BundleID(p.config.GetTargetSourcePaths("Stg"))
+				manifest, err := store.ReadManifest(ctx, "Stg", bundleID)
+				require.NoError(t, err)
+				manifest.CreatedAt = time.Now().Add(-2 * time.Hour)
+				require.NoError(t, store.WriteManifest(ctx, "Stg", bundleID, manifest))
+			},
+			wantErr: "merged 2h0m0s ago (max_age 1h0m0s)",
+		},
+		{
+			name:      "source secret written",
+			freshness: FreshnessSettings{MatchSources: true},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				src.put("db", map[string]interface{}{"password": "rotated"})
+			},
+			wantErr: "source analytics changed since the merge for secrets [analytics/db]",
+		},
+		{
+			name:      "source secret added",
+			freshness: FreshnessSettings{MatchSources: true},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				src.put("queue", map[string]interface{}{"url": "amqp://"})
+			},
+			wantErr: "source analytics changed since the merge for secrets [analytics/queue]",
+		},
+		{
+			name:      "source changes ignored without match_sources",
+			freshness: FreshnessSettings{MaxAge: time.Hour},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				src.put("db", map[string]interface{}{"password": "rotated"})
+			},
+		},
+		{
+			name:      "bundle replaced",
+			freshness: FreshnessSettings{MaxAge: time.Hour},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				bundleID := BundleID(p.config.GetTargetSourcePaths("Stg"))
+				require.NoError(t, p.mergeStore.WriteMergedBundle(ctx, "Stg", bundleID, map[string]interface{}{
+					"db": map[string]interface{}{"password": "tampered"},
+				}))
+			},
+			wantErr: "bundle content does not match its manifest",
+		},
+		{
+			name:      "no manifest",
+			freshness: FreshnessSettings{MaxAge: time.Hour},
+			change: func(t *testing.T, p *Pipeline, src *fakeKV) {
+				store := p.mergeStore.(*FileMergeStore)
+				require.NoError(t, os.Remove(store.manifestFile("Stg", BundleID(p.config.GetTargetSourcePaths("Stg")))))
+			},
+			wantErr: "no manifest recorded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, srcSrv := newFakeKV(t, "analytics")
+			dst, dstSrv := newFakeKV(t, "replica")
+			// Numbers come back from Vault as json.Number and from the file store
+			// as float64; both must hash the same
+			src.put("db", map[string]interface{}{
+				"password": "hunter2",
+				"ratio":    json.Number("1.0"),
+				"id":       json.Number("9007199254740993"),
+			})
+
+			cfg := &Config{
+				Vault: VaultConfig{Address: srcSrv.URL},
+				Sources: map[string]Source{
+					"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+				},
+				MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+				Targets: map[string]Target{
+					"Stg": {
+						Imports:     []string{"analytics"},
+						Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+					},
+				},
+				Pipeline: PipelineSettings{Sync: SyncSettings{Freshness: tt.freshness}},
+			}
+			p, err := New(cfg)
+			require.NoError(t, err)
+
+			_, err = p.Run(ctx, Options{Operation: OperationMerge})
+			require.NoError(t, err)
+			tt.change(t, p, src)
+
+			results, _ := p.Run(ctx, Options{Operation: OperationSync})
+			require.Len(t, results, 1)
+			if tt.wantErr == "" {
+				require.NoError(t, results[0].Error)
+				assert.Len(t, dst.data, 1)
+				return
+			}
+			var staleErr *StaleBundleError
+			require.ErrorAs(t, results[0].Error, &staleErr)
+			assert.EqualError(t, staleErr, "bundle of target Stg is stale: "+tt.wantErr+"; run merge again")
+			assert.Empty(t, dst.data)
+		})
+	}
+}
@@ -2,12 +2,15 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/extended-data-library/secretssync/pkg/client/aws"
 	"github.com/extended-data-library/secretssync/pkg/client/vault"
 	reqctx "github.com/extended-data-library/secretssync/pkg/context"
-	"github.com/extended-data-library/secretssync/pkg/utils"
+	"github.com/extended-data-library/secretssync/pkg/diff"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,8 +22,9 @@ import (
 //
 // The merge store path is deterministic based on source sequence checksum,
 // so the same sources in the same order always produce the same path.
-// Existing data at that path is wiped before writing.
-func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bool) Result {
+// Existing data at that path is wiped before writing, so a bundle that trips
+// the target's guardrails is not written unless allowLargeChanges is set.
+func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun, allowLargeChanges bool) Result {
 	start := time.Now()
 	requestID := reqctx.GetRequestID(ctx)
 	l := log.WithFields(log.Fields{
@@ -41,107 +45,48 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 		}
 	}
 
-	// Build source paths in order (order determines merge priority)
-	var sourcePaths []string
-	for _, importName := range target.Imports {
-		sourcePath := p.config.GetSourcePath(importName)
-		sourcePaths = append(sourcePaths, sourcePath)
-	}
-
-	// Calculate deterministic bundle path based on source sequence
-	var bundlePath string
-	var bundleID string
-	if p.config.MergeStore.Vault != nil {
-		bundleID = BundleID(sourcePaths)
-		bundlePath = TargetBundlePath(p.config.MergeStore.Vault.Mount, targetName, sourcePaths)
-	} else if p.s3Store != nil {
-		bundleID = BundleID(sourcePaths)
-		bundlePath = p.s3Store.GetBundlePath(targetName, bundleID)
-	} else {
+	if p.mergeStore == nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "merge",
 			Success:  false,
-			Error:    fmt.Errorf("no merge store configured"),
+			Error:    p.errNoMergeStore(),
 			Duration: time.Since(start),
 		}
 	}
 
-	l.WithFields(log.Fields{
-		"bundlePath": bundlePath,
-		"bundleID":   bundleID,
-		"sources":    sourcePaths,
-	}).Info("Starting merge")
-
-	// Initialize Vault client for reading sources
-	sourceClient := &vault.VaultClient{
-		Address:   p.config.Vault.Address,
-		Namespace: p.config.Vault.Namespace,
-	}
-	if err := sourceClient.Init(ctx); err != nil {
+	bundle, err := p.buildBundle(ctx, l, targetName, target, nil)
+	if err != nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "merge",
 			Success:  false,
-			Error:    fmt.Errorf("failed to init source vault client: %w", err),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
+	bundlePath := p.mergeStore.GetBundlePath(targetName, bundle.bundleID)
 
-	// Merge all sources in sequence (later sources override earlier)
-	mergedSecrets := make(map[string]interface{})
-	var failedSources []string
-
-	for i, sourcePath := range sourcePaths {
-		l.WithFields(log.Fields{
-			"source":   sourcePath,
-			"priority": i,
-		}).Debug("Processing source")
-
-		// List all secrets in this source
-		secrets, err := sourceClient.ListSecrets(ctx, sourcePath)
-		if err != nil {
-			l.WithError(err).WithField("source", sourcePath).Warn("Failed to list secrets from source")
-			failedSources = append(failedSources, sourcePath)
-			continue
-		}
-
-		// Read and merge each secret
-		for _, secretPath := range secrets {
-			secretData, err := sourceClient.GetKVSecretOnce(ctx, secretPath)
-			if err != nil {
-				l.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret")
-				continue
-			}
+	l.WithField("secretsCount", len(bundle.secrets)).Debug("Merge complete, writing to store")
 
-			// Relative path within this source
-			relPath := secretPath
-			if len(secretPath) > len(sourcePath) {
-				relPath = secretPath[len(sourcePath):]
-				if len(relPath) > 0 && relPath[0] == '/' {
-					relPath = relPath[1:]
-				}
-			}
+	// Compute diff against the bundle currently in the merge store (before overwriting it)
+	var targetDiff *diff.TargetDiff
+	if p.pipelineDiff != nil {
+		targetDiff = p.computeMergeDiff(ctx, targetName, bundle.bundleID, bundle.secrets, bundle.provenance)
+		p.addTargetDiff(*targetDiff)
+	}
 
-			// Deep merge into accumulated result (later sources win on conflict)
-			if existing, ok := mergedSecrets[relPath]; ok {
-				if existingMap, ok := existing.(map[string]interface{}); ok {
-					mergedSecrets[relPath] = utils.DeepMerge(existingMap, secretData)
-				} else {
-					// Not a map, just override
-					mergedSecrets[relPath] = secretData
-				}
-			} else {
-				mergedSecrets[relPath] = secretData
-			}
+	if guardrails := p.guardrailsFor(target); guardrails.enabled() && !allowLargeChanges {
+		current := p.storedSecrets(ctx, targetName, bundle.bundleID)
+		if guardErr := guardrails.check(targetName, "merge", current, bundle.secrets); guardErr != nil {
+			l.WithError(guardErr).Error("Guardrail tripped, bundle not written")
+			return guardrailResult(guardErr, start, targetDiff)
 		}
 	}
 
-	l.WithField("secretsCount", len(mergedSecrets)).Debug("Merge complete, writing to store")
-
 	if dryRun {
 		l.WithFields(log.Fields{
-			"secretsCount": len(mergedSecrets),
+			"secretsCount": len(bundle.secrets),
 			"bundlePath":   bundlePath,
 		}).Info("[DRY-RUN] Would write merged bundle")
 		return Result{
@@ -151,46 +96,40 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 			Success:   true,
 			Duration:  time.Since(start),
 			Details: ResultDetails{
-				SecretsProcessed: len(mergedSecrets),
-				SourcePaths:      sourcePaths,
+				SecretsProcessed: len(bundle.secrets),
+				SecretsFiltered:  bundle.filtered,
+				SourcePaths:      bundle.sourcePaths,
 				DestinationPath:  bundlePath,
 			},
+			Diff: targetDiff,
 		}
 	}
 
-	// Write to merge store
-	var writeErr error
-	if p.config.MergeStore.Vault != nil {
-		writeErr = p.writeMergedBundleToVault(ctx, bundlePath, mergedSecrets)
-	} else if p.s3Store != nil {
-		writeErr = p.s3Store.WriteMergedBundle(ctx, targetName, bundleID, mergedSecrets)
-	}
-
-	if writeErr != nil {
+	if err := p.writeBundle(ctx, targetName, bundle); err != nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "merge",
 			Success:  false,
-			Error:    fmt.Errorf("failed to write merged bundle: %w", writeErr),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
 
-	success := len(failedSources) == 0
+	success := len(bundle.failedSources) == 0
 	var lastErr error
 	if !success {
-		lastErr = fmt.Errorf("failed to read from %d sources: %v", len(failedSources), failedSources)
+		lastErr = fmt.Errorf("failed to read from %d sources: %v", len(bundle.failedSources), bundle.failedSources)
 	}
 
 	l.WithFields(log.Fields{
 		"duration":      time.Since(start),
 		"success":       success,
 		"bundlePath":    bundlePath,
-		"secretsCount":  len(mergedSecrets),
-		"failedSources": failedSources,
+		"secretsCount":  len(bundle.secrets),
+		"failedSources": bundle.failedSources,
 	}).Info("Merge completed")
 
-	result := Result{
+	return Result{
 		Target:    targetName,
 		Phase:     "merge",
 		Operation: string(OperationMerge),
@@ -198,91 +137,354 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 		Error:     lastErr,
 		Duration:  time.Since(start),
 		Details: ResultDetails{
-			SecretsProcessed: len(mergedSecrets),
-			SourcePaths:      sourcePaths,
+			SecretsProcessed: len(bundle.secrets),
+			SecretsFiltered:  bundle.filtered,
+			SourcePaths:      bundle.sourcePaths,
 			DestinationPath:  bundlePath,
-			FailedImports:    failedSources,
+			FailedImports:    bundle.failedSources,
 		},
+		Diff: targetDiff,
 	}
+}
 
-	// Compute diff if tracking is enabled
-	if p.pipelineDiff != nil {
-		targetDiff, err := p.computeMergeDiff(ctx, targetName, sourcePaths)
+// mergedBundle is a target's bundle as merged from its imports
+type mergedBundle struct {
+	bundleID      string
+	sourcePaths   []string
+	secrets       map[string]interface{}
+	provenance    *Provenance
+	failedSources []string
+	// sourceVersions maps Vault imports to the KV versions of the secrets read
+	sourceVersions map[string]map[string]int
+	filtered       int
+}
+
+// buildBundle merges a target's imports in order, then applies its overrides
+// and tombstones. Inherited targets are read from pending when present there,
+// otherwise from the merge store. Imports that cannot be read are skipped and
+// listed in failedSources.
+func (p *Pipeline) buildBundle(ctx context.Context, l *log.Entry, targetName string, target Target, pending map[string]map[string]map[string]interface{}) (*mergedBundle, error) {
+	// Build source paths in order (order determines merge priority)
+	sourcePaths := p.config.GetTargetSourcePaths(targetName)
+
+	// Calculate deterministic bundle path based on source sequence
+	bundleID := BundleID(sourcePaths)
+
+	l.WithFields(log.Fields{
+		"bundlePath": p.mergeStore.GetBundlePath(targetName, bundleID),
+		"bundleID":   bundleID,
+		"sources":    sourcePaths,
+	}).Info("Starting merge")
+
+	// Vault clients for reading sources, created on first use of each connection config
+	sourceClients := make(map[VaultConfig]*vault.VaultClient)
+	vaultClientFor := func(cfg VaultConfig) (*vault.VaultClient, error) {
+		if client, ok := sourceClients[cfg]; ok {
+			return client, nil
+		}
+		client := newVaultClient(&cfg)
+		if err := client.Init(ctx); err != nil {
+			return nil, err
+		}
+		sourceClients[cfg] = client
+		return client, nil
+	}
+
+	// Merge all sources in sequence (later sources override earlier)
+	bundle := &mergedBundle{
+		bundleID:       bundleID,
+		sourcePaths:    sourcePaths,
+		secrets:        make(map[string]interface{}),
+		sourceVersions: make(map[string]map[string]int),
+	}
+	merger := newBundleMerger(target.Merge)
+
+	for i, importName := range target.Imports {
+		sourcePath := sourcePaths[i]
+		l.WithFields(log.Fields{
+			"source":   sourcePath,
+			"priority": i,
+		}).Debug("Processing source")
+
+		// Source filters are validated with the config, so compiling cannot fail here
+		var filter *secretFilter
+		if src, ok := p.config.Sources[importName]; ok && src.Filters != nil {
+			filter, _ = newSecretFilter(src.Filters)
+		}
+
+		var secrets map[string]map[string]interface{}
+		var err error
+		if _, isTarget := p.config.Targets[importName]; isTarget {
+			// Inherited target: use its pending bundle or read it back from the merge store
+			if planned, ok := pending[importName]; ok {
+				secrets = planned
+			} else {
+				secrets, err = p.readTargetBundle(ctx, importName)
+			}
+		} else if src, ok := p.config.Sources[importName]; ok && src.AWS != nil {
+			secrets, err = p.readAWSSource(ctx, src.AWS, filter)
+		} else {
+			sourceClient, clientErr := vaultClientFor(p.sourceVaultConfig(src.Vault))
+			if clientErr != nil {
+				return nil, fmt.Errorf("failed to init source vault client for %s: %w", importName, clientErr)
+			}
+			var paths []string
+			if src.Vault != nil {
+				paths = src.Vault.Paths
+			}
+			versions := make(map[string]int)
+			secrets, err = p.readVaultSource(ctx, sourceClient, sourcePath, paths, filter, versions)
+			if err == nil {
+				bundle.sourceVersions[importName] = versions
+			}
+		}
+		if err != nil {
+			l.WithError(err).WithField("source", sourcePath).Warn("Failed to list secrets from source")
+			bundle.failedSources = append(bundle.failedSources, sourcePath)
+			continue
+		}
+		if filter != nil && filter.filtered > 0 {
+			bundle.filtered += filter.filtered
+			l.WithFields(log.Fields{
+				"source":   sourcePath,
+				"filtered": filter.filtered,
+			}).Debug("Filtered source secrets")
+		}
+
+		// Deep merge into accumulated result with the target's merge strategy
+		for relPath, secretData := range secrets {
+			existing, _ := bundle.secrets[relPath].(map[string]interface{})
+			origin := ValueOrigin{Import: importName, Path: strings.TrimSuffix(sourcePath, "/") + "/" + relPath}
+			merged, err := merger.merge(relPath, existing, secretData, origin)
+			if err != nil {
+				return nil, fmt.Errorf("merge conflict: %w", err)
+			}
+			bundle.secrets[relPath] = merged
+		}
+	}
+
+	// Overrides and tombstones apply on top of every import
+	if len(target.Overrides) > 0 || len(target.Remove) > 0 {
+		var refValues map[string]interface{}
+		if hasVaultRefs(target.Overrides) {
+			client, err := vaultClientFor(p.config.Vault)
+			if err == nil {
+				refValues, err = resolveVaultRefs(ctx, client, target.Overrides)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve override vault_refs: %w", err)
+			}
+		}
+		merger.applyOverrides(bundle.secrets, target.Overrides, refValues)
+		merger.applyRemovals(bundle.secrets, target.Remove)
+	}
+
+	bundle.provenance = merger.provenance
+	return bundle, nil
+}
+
+// writeBundle writes a merged bundle, its provenance and its manifest to the
+// merge store
+func (p *Pipeline) writeBundle(ctx context.Context, targetName string, bundle *mergedBundle) error {
+	if err := p.mergeStore.WriteMergedBundle(ctx, targetName, bundle.bundleID, bundle.secrets); err != nil {
+		return fmt.Errorf("failed to write merged bundle: %w", err)
+	}
+	if store, ok := p.mergeStore.(ProvenanceStore); ok {
+		if err := store.WriteProvenance(ctx, targetName, bundle.bundleID, bundle.provenance); err != nil {
+			return fmt.Errorf("failed to write bundle provenance: %w", err)
+		}
+	}
+	if store, ok := p.mergeStore.(ManifestStore); ok {
+		manifest, err := p.newManifest(ctx, targetName, bundle)
+		if err != nil {
+			return fmt.Errorf("failed to build bundle manifest: %w", err)
+		}
+		if err := store.WriteManifest(ctx, targetName, bundle.bundleID, manifest); err != nil {
+			return fmt.Errorf("failed to write bundle manifest: %w", err)
+		}
+	}
+	return nil
+}
+
+// sourceVaultConfig returns the Vault connection settings for a source,
+// falling back to the top-level vault config for anything it leaves unset
+func (p *Pipeline) sourceVaultConfig(src *VaultSource) VaultConfig {
+	if src == nil {
+		return p.config.Vault
+	}
+
+	cfg := p.config.Vault.withOverrides(src.Address, src.Namespace, src.Auth)
+	if src.MaxTraversalDepth > 0 {
+		cfg.MaxTraversalDepth = src.MaxTraversalDepth
+	}
+	if src.MaxSecretsPerMount > 0 {
+		cfg.MaxSecretsPerMount = src.MaxSecretsPerMount
+	}
+	if src.QueueCompactionThreshold > 0 {
+		cfg.QueueCompactionThreshold = src.QueueCompactionThreshold
+	}
+	return cfg
+}
+
+// readVaultSource reads every secret under a Vault source path, keyed by
+// path relative to the source. With paths set only those sub-paths are read;
+// a trailing "/*" is accepted and a path that lists nothing is read as a single
+// secret. Keys stay relative to the source path, so secrets from different
+// sub-paths cannot collide. Secrets rejected by the filter are never read.
+// When versions is non-nil it receives the KV version of each secret read,
+// keyed by its full path.
+func (p *Pipeline) readVaultSource(ctx context.Context, client *vault.VaultClient, sourcePath string, paths []string, filter *secretFilter, versions map[string]int) (map[string]map[string]interface{}, error) {
+	secrets, err := listVaultSource(ctx, client, sourcePath, paths, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]interface{}, len(secrets))
+	for _, secretPath := range secrets {
+		var secretData map[string]interface{}
+		if versions == nil {
+			secretData, err = client.GetKVSecretOnce(ctx, secretPath)
+		} else {
+			var version int
+			secretData, version, err = client.GetKVSecretVersion(ctx, secretPath)
+			if err == nil && secretData == nil {
+				err = fmt.Errorf("secret not found: %s", secretPath)
+			}
+			if err == nil {
+				versions[secretPath] = version
+			}
+		}
 		if err != nil {
-			l.WithError(err).Debug("Failed to compute merge diff")
+			log.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret")
+			continue
+		}
+		result[relativeSecretPath(sourcePath, secretPath)] = secretData
+	}
+
+	return result, nil
+}
+
+// listVaultSource returns the paths of the secrets readVaultSource reads
+func listVaultSource(ctx context.Context, client *vault.VaultClient, sourcePath string, paths []string, filter *secretFilter) ([]string, error) {
+	roots := []string{sourcePath}
+	if len(paths) > 0 {
+		roots = make([]string, 0, len(paths))
+		for _, path := range paths {
+			roots = append(roots, strings.TrimSuffix(sourcePath, "/")+"/"+strings.Trim(strings.TrimSuffix(path, "*"), "/"))
+		}
+	}
+
+	var result []string
+	for _, root := range roots {
+		var secrets []string
+		var err error
+		if mount := strings.Trim(root, "/"); !strings.Contains(mount, "/") {
+			secrets, err = listVaultMount(ctx, client, mount)
 		} else {
-			result.Diff = targetDiff
-			p.addTargetDiff(*targetDiff)
+			secrets, err = client.ListSecrets(ctx, root)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(secrets) == 0 && len(paths) > 0 {
+			secrets = []string{root}
+		}
+
+		for _, secretPath := range secrets {
+			if filter.keep(relativeSecretPath(sourcePath, secretPath)) {
+				result = append(result, secretPath)
+			}
 		}
 	}
 
-	return result
+	return result, nil
 }
 
-// writeMergedBundleToVault writes the merged secrets to Vault, wiping existing data first
-func (p *Pipeline) writeMergedBundleToVault(ctx context.Context, bundlePath string, secrets map[string]interface{}) error {
+// readAWSSource reads every Secrets Manager secret matching the source's prefix
+// and tags, assuming into the source account when one is configured.
+// Secrets are keyed by name relative to the prefix; those rejected by the filter
+// are never read.
+func (p *Pipeline) readAWSSource(ctx context.Context, src *AWSSource, filter *secretFilter) (map[string]map[string]interface{}, error) {
 	l := log.WithFields(log.Fields{
-		"action":     "writeMergedBundleToVault",
-		"bundlePath": bundlePath,
+		"action":    "readAWSSource",
+		"accountId": src.AccountID,
+		"prefix":    src.Prefix,
 	})
 
-	mergeClient := &vault.VaultClient{
-		Address:   p.config.Vault.Address,
-		Namespace: p.config.Vault.Namespace,
+	region := src.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+
+	// Without a role to assume, the ambient credentials would read whichever
+	// account they belong to
+	roleArn := p.getRoleARNForAccount(src.AccountID)
+	if roleArn == "" && src.AccountID != "" && (p.awsCtx == nil || p.awsCtx.CallerIdentity == nil || p.awsCtx.CallerIdentity.AccountID != src.AccountID) {
+		return nil, fmt.Errorf("no role to assume into source account %s: set aws.execution_context.custom_role_pattern or enable aws.control_tower", src.AccountID)
+	}
+
+	client := &aws.AwsClient{
+		Region:  region,
+		RoleArn: roleArn,
 	}
-	if err := mergeClient.Init(ctx); err != nil {
-		return fmt.Errorf("failed to init merge vault client: %w", err)
+	if err := client.CreateClient(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create AWS client for source account: %w", err)
 	}
 
-	// Wipe existing bundle at this path
-	existingSecrets, err := mergeClient.ListSecrets(ctx, bundlePath)
-	if err == nil && len(existingSecrets) > 0 {
-		l.WithField("existingCount", len(existingSecrets)).Debug("Wiping existing bundle")
-		for _, secretPath := range existingSecrets {
-			if err := mergeClient.DeleteSecret(ctx, secretPath); err != nil {
-				l.WithError(err).WithField("secret", secretPath).Warn("Failed to delete existing secret")
-			}
-		}
+	names, err := client.ListSecretsByFilter(ctx, src.Prefix, src.Tags)
+	if err != nil {
+		return nil, err
 	}
 
-	// Write each merged secret
-	for relPath, data := range secrets {
-		fullPath := fmt.Sprintf("%s/%s", bundlePath, relPath)
+	result := make(map[string]map[string]interface{}, len(names))
+	for _, name := range names {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(name, src.Prefix), "/")
+		if relPath == "" {
+			relPath = name
+		}
+		if !filter.keep(relPath) {
+			continue
+		}
 
-		secretData, ok := data.(map[string]interface{})
-		if !ok {
-			l.WithField("path", relPath).Warn("Secret data is not a map, skipping")
+		raw, err := client.GetSecret(ctx, name)
+		if err != nil {
+			l.WithError(err).WithField("secret", name).Warn("Failed to read secret")
 			continue
 		}
 
-		if _, err := mergeClient.WriteSecretOnce(ctx, fullPath, secretData, nil); err != nil {
-			return fmt.Errorf("failed to write secret %s: %w", fullPath, err)
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			// Only JSON object secrets can be deep-merged with other sources
+			l.WithField("secret", name).Warn("Secret is not a JSON object, skipping")
+			continue
 		}
+		result[relPath] = data
 	}
 
-	l.WithField("secretsWritten", len(secrets)).Debug("Bundle written to Vault")
-	return nil
+	l.WithField("secretsCount", len(result)).Debug("Read AWS source")
+	return result, nil
+}
+
+// readTargetBundle reads the merged bundle of another target from the merge store.
+// Used when a target inherits from another target via imports.
+func (p *Pipeline) readTargetBundle(ctx context.Context, targetName string) (map[string]map[string]interface{}, error) {
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+	bundleID := BundleID(p.config.GetTargetSourcePaths(targetName))
+	return p.mergeStore.ReadMergedBundle(ctx, targetName, bundleID)
 }
 
 // GetBundlePath returns the current bundle path for a target (for sync phase to use)
 func (p *Pipeline) GetBundlePath(targetName string) (string, error) {
-	target, ok := p.config.Targets[targetName]
-	if !ok {
+	if _, ok := p.config.Targets[targetName]; !ok {
 		return "", fmt.Errorf("target not found: %s", targetName)
 	}
 
-	var sourcePaths []string
-	for _, importName := range target.Imports {
-		sourcePath := p.config.GetSourcePath(importName)
-		sourcePaths = append(sourcePaths, sourcePath)
-	}
-
-	if p.config.MergeStore.Vault != nil {
-		return TargetBundlePath(p.config.MergeStore.Vault.Mount, targetName, sourcePaths), nil
-	} else if p.s3Store != nil {
-		bundleID := BundleID(sourcePaths)
-		return p.s3Store.GetBundlePath(targetName, bundleID), nil
+	if p.mergeStore == nil {
+		return "", p.errNoMergeStore()
 	}
 
-	return "", fmt.Errorf("no merge store configured")
+	bundleID := BundleID(p.config.GetTargetSourcePaths(targetName))
+	return p.mergeStore.GetBundlePath(targetName, bundleID), nil
 }
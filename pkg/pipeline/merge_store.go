@@ -0,0 +1,518 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// MergeStore is the intermediate storage for merged bundles.
+//
+// The merge phase writes one bundle per target (keyed by the deterministic
+// BundleID of its source sequence) and the sync phase reads it back. Every
+// backend stores the same shape: a map of bundle-relative secret paths to
+// secret data.
+type MergeStore interface {
+	// WriteMergedBundle replaces the bundle with the given secrets
+	WriteMergedBundle(ctx context.Context, targetName, bundleID string, secrets map[string]interface{}) error
+	// ReadMergedBundle returns all secrets in the bundle keyed by relative path
+	ReadMergedBundle(ctx context.Context, targetName, bundleID string) (map[string]map[string]interface{}, error)
+	// ListBundles returns the IDs of all bundles stored for a target
+	ListBundles(ctx context.Context, targetName string) ([]string, error)
+	// DeleteBundle removes a bundle and all of its secrets
+	DeleteBundle(ctx context.Context, targetName, bundleID string) error
+	// GetBundlePath returns a human-readable location for a bundle (for logging and results)
+	GetBundlePath(targetName, bundleID string) string
+}
+
+// BundleHistory is implemented by merge stores that record a version of
+// each target's bundle whenever the merge writes it
+type BundleHistory interface {
+	// ListBundleVersions returns the recorded bundles of a target, newest first
+	ListBundleVersions(ctx context.Context, targetName string) ([]SecretVersion, error)
+	// GetBundleVersion returns one recorded bundle of a target
+	GetBundleVersion(ctx context.Context, targetName string, version int) (*SecretVersion, error)
+}
+
+// Compile-time interface checks
+var (
+	_ MergeStore    = (*S3MergeStore)(nil)
+	_ MergeStore    = (*VaultMergeStore)(nil)
+	_ MergeStore    = (*FileMergeStore)(nil)
+	_ BundleHistory = (*S3MergeStore)(nil)
+)
+
+// NewMergeStore creates the merge store selected in the configuration.
+// Returns nil without error when no merge store is configured.
+func NewMergeStore(ctx context.Context, cfg *Config) (MergeStore, error) {
+	switch {
+	case cfg.MergeStore.Vault != nil:
+		return NewVaultMergeStore(cfg.MergeStore.Vault, &cfg.Vault), nil
+	case cfg.MergeStore.S3 != nil:
+		store, err := NewS3MergeStore(ctx, cfg.MergeStore.S3, cfg.AWS.Region)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case cfg.MergeStore.File != nil:
+		store, err := NewFileMergeStore(cfg.MergeStore.File)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, nil
+}
+
+// VaultMergeStore implements a merge store on a Vault KV2 mount.
+//
+// Each write stages the bundle as a new generation, one KV entry per secret
+// under {mount}/staging/{target}/{bundle_id}/{generation}/{path}, then
+// publishes it by writing the pointer {mount}/targets/{target}/{bundle_id}
+// with check-and-set. Reads only follow the pointer, so they never see a
+// bundle that is partly written.
+type VaultMergeStore struct {
+	Mount string
+
+	vaultCfg VaultConfig
+}
+
+// bundlePointer is the published pointer of a Vault bundle
+type bundlePointer struct {
+	// Generation names the staged generation holding the bundle
+	Generation string `json:"generation"`
+	// Secrets is the number of secrets in the generation
+	Secrets     int       `json:"secrets"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// generationFormat names generations so they sort by creation time
+const generationFormat = "20060102T150405.000000000Z"
+
+// NewVaultMergeStore creates a Vault-backed merge store
+func NewVaultMergeStore(cfg *MergeStoreVault, vaultCfg *VaultConfig) *VaultMergeStore {
+	return &VaultMergeStore{
+		Mount:    cfg.Mount,
+		vaultCfg: *vaultCfg,
+	}
+}
+
+// client returns an initialized Vault client for the merge mount
+func (s *VaultMergeStore) client(ctx context.Context) (*vault.VaultClient, error) {
+	c := newVaultClient(&s.vaultCfg)
+	if err := c.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init merge vault client: %w", err)
+	}
+	return c, nil
+}
+
+// targetPath returns the Vault path holding the bundle pointers of a target
+func (s *VaultMergeStore) targetPath(targetName string) string {
+	return fmt.Sprintf("%s/targets/%s", s.Mount, targetName)
+}
+
+// GetBundlePath returns the Vault path of a bundle's pointer.
+// Format: {mount}/targets/{target_name}/{bundle_id}
+func (s *VaultMergeStore) GetBundlePath(targetName, bundleID string) string {
+	return fmt.Sprintf("%s/%s", s.targetPath(targetName), bundleID)
+}
+
+// stagingPath returns the Vault path holding the generations of a bundle.
+// Format: {mount}/staging/{target_name}/{bundle_id}
+func (s *VaultMergeStore) stagingPath(targetName, bundleID string) string {
+	return fmt.Sprintf("%s/staging/%s/%s", s.Mount, targetName, bundleID)
+}
+
+// readPointer returns a bundle's pointer and its KV version; a bundle never
+// published returns a nil pointer and version 0
+func (s *VaultMergeStore) readPointer(ctx context.Context, mergeClient *vault.VaultClient, targetName, bundleID string) (*bundlePointer, int, error) {
+	path := s.GetBundlePath(targetName, bundleID)
+	data, version, err := mergeClient.GetKVSecretVersion(ctx, path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read bundle pointer %s: %w", path, err)
+	}
+	if version == 0 || data == nil {
+		return nil, version, nil
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal bundle pointer %s: %w", path, err)
+	}
+	var pointer bundlePointer
+	if err := json.Unmarshal(jsonData, &pointer); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal bundle pointer %s: %w", path, err)
+	}
+	if pointer.Generation == "" {
+		return nil, 0, fmt.Errorf("bundle pointer %s names no generation", path)
+	}
+	return &pointer, version, nil
+}
+
+// WriteMergedBundle stages the merged secrets as a new generation and
+// publishes it. Publishing fails if another write published the bundle
+// since this one read the pointer; the staged generation is then deleted.
+// Generations older than the one replaced are garbage-collected.
+//
+// Provenance and manifest are written after the bundle is published, so a
+// reader can briefly see the new generation with the previous manifest. With
+// freshness requirements set, sync then fails on the content hash rather than
+// trusting a manifest that does not describe the bundle.
+func (s *VaultMergeStore) WriteMergedBundle(ctx context.Context, targetName, bundleID string, secrets map[string]interface{}) error {
+	bundlePath := s.GetBundlePath(targetName, bundleID)
+	l := log.WithFields(log.Fields{
+		"action":     "VaultMergeStore.WriteMergedBundle",
+		"bundlePath": bundlePath,
+	})
+
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	previous, version, err := s.readPointer(ctx, mergeClient, targetName, bundleID)
+	if err != nil {
+		return err
+	}
+
+	generation := time.Now().UTC().Format(generationFormat)
+	generationPath := fmt.Sprintf("%s/%s", s.stagingPath(targetName, bundleID), generation)
+	l = l.WithField("generation", generation)
+
+	// Stage each merged secret
+	written := 0
+	for relPath, data := range secrets {
+		secretData, ok := data.(map[string]interface{})
+		if !ok {
+			l.WithField("path", relPath).Warn("Secret data is not a map, skipping")
+			continue
+		}
+
+		fullPath := fmt.Sprintf("%s/%s", generationPath, relPath)
+		if _, err := mergeClient.WriteSecretOnce(ctx, fullPath, secretData, nil); err != nil {
+			s.deleteTree(ctx, mergeClient, generationPath)
+			return fmt.Errorf("failed to write secret %s: %w", fullPath, err)
+		}
+		written++
+	}
+
+	// Publish the generation; the check-and-set fails if another write published first
+	pointer := bundlePointer{Generation: generation, Secrets: written, PublishedAt: time.Now().UTC()}
+	jsonData, err := json.Marshal(pointer)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bundle pointer: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return fmt.Errorf("failed to marshal bundle pointer: %w", err)
+	}
+	if _, err := mergeClient.WriteSecretOnce(ctx, bundlePath, data, &version); err != nil {
+		s.deleteTree(ctx, mergeClient, generationPath)
+		if vault.IsCASConflict(err) {
+			return fmt.Errorf("failed to publish bundle %s: another merge published it while this one was staged", bundlePath)
+		}
+		return fmt.Errorf("failed to publish bundle %s: %w", bundlePath, err)
+	}
+
+	l.WithField("secretsWritten", written).Debug("Bundle published to Vault")
+
+	// Readers may still be reading the replaced generation, so only older ones are collected
+	if previous != nil {
+		s.collectGenerations(ctx, mergeClient, targetName, bundleID, previous.Generation)
+	} else {
+		// Bundles written before publication had no pointer and kept their
+		// secrets under the pointer path
+		s.deleteTree(ctx, mergeClient, bundlePath)
+	}
+	return nil
+}
+
+// collectGenerations deletes the generations of a bundle older than keep,
+// including generations staged by writes that never published them.
+// Failures are logged; the next write retries them.
+func (s *VaultMergeStore) collectGenerations(ctx context.Context, mergeClient *vault.VaultClient, targetName, bundleID, keep string) {
+	stagingPath := s.stagingPath(targetName, bundleID)
+	secrets, err := mergeClient.ListSecrets(ctx, stagingPath)
+	if err != nil {
+		log.WithError(err).WithField("path", stagingPath).Warn("Failed to list bundle generations")
+		return
+	}
+
+	deleted := 0
+	for _, secretPath := range secrets {
+		generation, _, _ := strings.Cut(relativeSecretPath(stagingPath, secretPath), "/")
+		if generation >= keep {
+			continue
+		}
+		if err := mergeClient.DeleteSecret(ctx, secretPath); err != nil {
+			log.WithError(err).WithField("secret", secretPath).Warn("Failed to delete old bundle generation")
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.WithFields(log.Fields{
+			"action":  "VaultMergeStore.collectGenerations",
+			"target":  targetName,
+			"deleted": deleted,
+		}).Debug("Collected old bundle generations")
+	}
+}
+
+// deleteTree deletes every secret below path, logging failures
+func (s *VaultMergeStore) deleteTree(ctx context.Context, mergeClient *vault.VaultClient, path string) {
+	secrets, err := mergeClient.ListSecrets(ctx, path)
+	if err != nil {
+		return
+	}
+	for _, secretPath := range secrets {
+		if err := mergeClient.DeleteSecret(ctx, secretPath); err != nil {
+			log.WithError(err).WithField("secret", secretPath).Warn("Failed to delete merge store secret")
+		}
+	}
+}
+
+// ReadMergedBundle reads all secrets of the published generation of a Vault
+// bundle keyed by bundle-relative path. It fails unless every secret the
+// pointer counts can be read.
+func (s *VaultMergeStore) ReadMergedBundle(ctx context.Context, targetName, bundleID string) (map[string]map[string]interface{}, error) {
+	bundlePath := s.GetBundlePath(targetName, bundleID)
+
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pointer, _, err := s.readPointer(ctx, mergeClient, targetName, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if pointer == nil {
+		return nil, fmt.Errorf("bundle %s has not been published", bundlePath)
+	}
+	generationPath := fmt.Sprintf("%s/%s", s.stagingPath(targetName, bundleID), pointer.Generation)
+
+	secretsData := make(map[string]map[string]interface{})
+	if pointer.Secrets > 0 {
+		secrets, err := mergeClient.ListSecrets(ctx, generationPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets from bundle: %w", err)
+		}
+		for _, secretPath := range secrets {
+			data, err := mergeClient.GetKVSecretOnce(ctx, secretPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read secret %s from bundle: %w", secretPath, err)
+			}
+			secretsData[relativeSecretPath(generationPath, secretPath)] = data
+		}
+	}
+	if len(secretsData) != pointer.Secrets {
+		return nil, fmt.Errorf("bundle %s generation %s has %d secrets, its pointer lists %d", bundlePath, pointer.Generation, len(secretsData), pointer.Secrets)
+	}
+
+	return secretsData, nil
+}
+
+// ListBundles returns the IDs of the published bundles of a target
+func (s *VaultMergeStore) ListBundles(ctx context.Context, targetName string) ([]string, error) {
+	targetPath := s.targetPath(targetName)
+
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := mergeClient.ListSecrets(ctx, targetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bundles: %w", err)
+	}
+
+	// Pointers sit directly under the target path
+	var bundles []string
+	for _, secretPath := range secrets {
+		rel := relativeSecretPath(targetPath, secretPath)
+		if rel == "" || strings.Contains(rel, "/") {
+			continue
+		}
+		bundles = append(bundles, rel)
+	}
+	sort.Strings(bundles)
+
+	return bundles, nil
+}
+
+// DeleteBundle unpublishes a Vault bundle, then deletes all of its
+// generations, its provenance and its manifest
+func (s *VaultMergeStore) DeleteBundle(ctx context.Context, targetName, bundleID string) error {
+	bundlePath := s.GetBundlePath(targetName, bundleID)
+
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, version, err := mergeClient.GetKVSecretVersion(ctx, bundlePath); err == nil && version > 0 {
+		if err := mergeClient.DeleteSecret(ctx, bundlePath); err != nil {
+			return fmt.Errorf("failed to delete bundle pointer %s: %w", bundlePath, err)
+		}
+	}
+
+	stagingPath := s.stagingPath(targetName, bundleID)
+	secrets, err := mergeClient.ListSecrets(ctx, stagingPath)
+	if err != nil {
+		return fmt.Errorf("failed to list secrets from bundle: %w", err)
+	}
+	for _, secretPath := range secrets {
+		if err := mergeClient.DeleteSecret(ctx, secretPath); err != nil {
+			return fmt.Errorf("failed to delete secret %s: %w", secretPath, err)
+		}
+	}
+
+	provenancePath := s.provenancePath(targetName, bundleID)
+	if _, version, err := mergeClient.GetKVSecretVersion(ctx, provenancePath); err == nil && version > 0 {
+		if err := mergeClient.DeleteSecret(ctx, provenancePath); err != nil {
+			return fmt.Errorf("failed to delete provenance %s: %w", provenancePath, err)
+		}
+	}
+
+	manifestPath := s.manifestPath(targetName, bundleID)
+	if _, version, err := mergeClient.GetKVSecretVersion(ctx, manifestPath); err == nil && version > 0 {
+		if err := mergeClient.DeleteSecret(ctx, manifestPath); err != nil {
+			return fmt.Errorf("failed to delete manifest %s: %w", manifestPath, err)
+		}
+	}
+
+	return nil
+}
+
+// provenancePath returns the Vault path of a bundle's provenance. It is kept
+// outside the bundle so it is never read as one of its secrets.
+// Format: {mount}/provenance/{target_name}/{bundle_id}
+func (s *VaultMergeStore) provenancePath(targetName, bundleID string) string {
+	return fmt.Sprintf("%s/provenance/%s/%s", s.Mount, targetName, bundleID)
+}
+
+// WriteProvenance writes a bundle's provenance as a single KV entry
+func (s *VaultMergeStore) WriteProvenance(ctx context.Context, targetName, bundleID string, prov *Provenance) error {
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(prov)
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+
+	path := s.provenancePath(targetName, bundleID)
+	if _, err := mergeClient.WriteSecretOnce(ctx, path, data, nil); err != nil {
+		return fmt.Errorf("failed to write provenance %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadProvenance reads a bundle's provenance
+func (s *VaultMergeStore) ReadProvenance(ctx context.Context, targetName, bundleID string) (*Provenance, error) {
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := s.provenancePath(targetName, bundleID)
+	data, version, err := mergeClient.GetKVSecretVersion(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance %s: %w", path, err)
+	}
+	if version == 0 || data == nil {
+		return nil, errNoProvenance
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provenance: %w", err)
+	}
+	var prov Provenance
+	if err := json.Unmarshal(jsonData, &prov); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provenance: %w", err)
+	}
+	return &prov, nil
+}
+
+// manifestPath returns the Vault path of a bundle's manifest, kept outside the
+// bundle like its provenance.
+// Format: {mount}/manifests/{target_name}/{bundle_id}
+func (s *VaultMergeStore) manifestPath(targetName, bundleID string) string {
+	return fmt.Sprintf("%s/manifests/%s/%s", s.Mount, targetName, bundleID)
+}
+
+// WriteManifest writes a bundle's manifest as a single KV entry
+func (s *VaultMergeStore) WriteManifest(ctx context.Context, targetName, bundleID string, manifest *BundleManifest) error {
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	path := s.manifestPath(targetName, bundleID)
+	if _, err := mergeClient.WriteSecretOnce(ctx, path, data, nil); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadManifest reads a bundle's manifest
+func (s *VaultMergeStore) ReadManifest(ctx context.Context, targetName, bundleID string) (*BundleManifest, error) {
+	mergeClient, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := s.manifestPath(targetName, bundleID)
+	data, version, err := mergeClient.GetKVSecretVersion(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	if version == 0 || data == nil {
+		return nil, errNoManifest
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	var manifest BundleManifest
+	if err := json.Unmarshal(jsonData, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// relativeSecretPath strips a base path (and the separating slash) from a secret path
+func relativeSecretPath(basePath, secretPath string) string {
+	relPath := secretPath
+	if len(secretPath) > len(basePath) {
+		relPath = secretPath[len(basePath):]
+		if len(relPath) > 0 && relPath[0] == '/' {
+			relPath = relPath[1:]
+		}
+	}
+	return relPath
+}
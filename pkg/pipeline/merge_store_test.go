@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVaultMergeStore(t *testing.T) (*VaultMergeStore, *fakeKV) {
+	t.Helper()
+	t.Setenv("VAULT_TOKEN", "root")
+	kv, srv := newFakeKV(t, "merged")
+	return NewVaultMergeStore(&MergeStoreVault{Mount: "merged"}, &VaultConfig{Address: srv.URL}), kv
+}
+
+// generations returns the staged generations of a bundle in the fake mount
+func (kv *fakeKV) generations(targetName, bundleID string) []string {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	prefix := "staging/" + targetName + "/" + bundleID + "/"
+	seen := map[string]bool{}
+	var generations []string
+	for path := range kv.versions {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			generation, _, _ := strings.Cut(rest, "/")
+			if !seen[generation] {
+				seen[generation] = true
+				generations = append(generations, generation)
+			}
+		}
+	}
+	return generations
+}
+
+func TestVaultMergeStore_Publish(t *testing.T) {
+	ctx := context.Background()
+	store, kv := newTestVaultMergeStore(t)
+
+	// A generation left behind by a merge that crashed before publishing, and a
+	// secret of the layout used before bundles were published
+	kv.put("staging/Stg/abc/20000101T000000.000000000Z/db", map[string]interface{}{"password": "partial"})
+	kv.put("targets/Stg/abc/db", map[string]interface{}{"password": "legacy"})
+
+	_, err := store.ReadMergedBundle(ctx, "Stg", "abc")
+	assert.EqualError(t, err, "bundle merged/targets/Stg/abc has not been published")
+
+	bundles := []map[string]interface{}{
+		{"db": map[string]interface{}{"password": "v1"}, "api/key": map[string]interface{}{"token": "v1"}},
+		{"db": map[string]interface{}{"password": "v2"}},
+		{"db": map[string]interface{}{"password": "v3"}},
+	}
+	mergeClient, err := store.client(ctx)
+	require.NoError(t, err)
+	var published []string
+	for _, secrets := range bundles {
+		require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc", secrets))
+		pointer, _, err := store.readPointer(ctx, mergeClient, "Stg", "abc")
+		require.NoError(t, err)
+		assert.Equal(t, len(secrets), pointer.Secrets)
+		published = append(published, pointer.Generation)
+
+		read, err := store.ReadMergedBundle(ctx, "Stg", "abc")
+		require.NoError(t, err)
+		assert.Len(t, read, len(secrets))
+		assert.Equal(t, secrets["db"], read["db"])
+	}
+
+	// The published and the replaced generation are kept; older ones, including
+	// the crashed one, are collected
+	assert.ElementsMatch(t, published[1:], kv.generations("Stg", "abc"))
+	assert.NotContains(t, kv.data, "targets/Stg/abc/db")
+
+	// Only a bundle never published can have the legacy layout, so later
+	// writes do not look for it
+	kv.put("targets/Stg/abc/cache", map[string]interface{}{"url": "redis://"})
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc", bundles[2]))
+	assert.Contains(t, kv.data, "targets/Stg/abc/cache")
+	kv.mu.Lock()
+	delete(kv.data, "targets/Stg/abc/cache")
+	delete(kv.versions, "targets/Stg/abc/cache")
+	kv.mu.Unlock()
+
+	ids, err := store.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, ids)
+
+	require.NoError(t, store.DeleteBundle(ctx, "Stg", "abc"))
+	assert.Empty(t, kv.generations("Stg", "abc"))
+	ids, err = store.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestVaultMergeStore_ConcurrentPublish(t *testing.T) {
+	ctx := context.Background()
+	store, kv := newTestVaultMergeStore(t)
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc", map[string]interface{}{
+		"db": map[string]interface{}{"password": "v1"},
+	}))
+	before := kv.generations("Stg", "abc")
+
+	// Another merge publishes while this one is staging
+	kv.beforeWrite = func(path string) {
+		if strings.HasPrefix(path, "staging/") {
+			kv.versions["targets/Stg/abc"]++
+			kv.beforeWrite = nil
+		}
+	}
+	err := store.WriteMergedBundle(ctx, "Stg", "abc", map[string]interface{}{
+		"db": map[string]interface{}{"password": "v2"},
+	})
+	assert.EqualError(t, err, "failed to publish bundle merged/targets/Stg/abc: another merge published it while this one was staged")
+
+	// The staged generation is removed and the published one is untouched
+	assert.Equal(t, before, kv.generations("Stg", "abc"))
+	read, err := store.ReadMergedBundle(ctx, "Stg", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"password": "v1"}, read["db"])
+}
+
+func TestVaultMergeStore_ReadIncompleteGeneration(t *testing.T) {
+	ctx := context.Background()
+	store, kv := newTestVaultMergeStore(t)
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "abc", map[string]interface{}{
+		"db":    map[string]interface{}{"password": "v1"},
+		"cache": map[string]interface{}{"url": "redis://"},
+	}))
+	generation := kv.generations("Stg", "abc")[0]
+
+	kv.mu.Lock()
+	delete(kv.data, "staging/Stg/abc/"+generation+"/cache")
+	delete(kv.versions, "staging/Stg/abc/"+generation+"/cache")
+	kv.mu.Unlock()
+
+	_, err := store.ReadMergedBundle(ctx, "Stg", "abc")
+	assert.EqualError(t, err, "bundle merged/targets/Stg/abc generation "+generation+" has 1 secrets, its pointer lists 2")
+}
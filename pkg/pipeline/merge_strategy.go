@@ -0,0 +1,247 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/pkg/utils"
+)
+
+// validate checks the strategy names and rule globs of a merge strategy
+func (s *MergeStrategy) validate() error {
+	if s == nil {
+		return nil
+	}
+	if err := validateMergeFields(s.List, s.Map, s.Conflict); err != nil {
+		return err
+	}
+	for i, rule := range s.Rules {
+		for _, glob := range []string{rule.Secret, rule.Key} {
+			if _, err := path.Match(glob, ""); err != nil {
+				return fmt.Errorf("rules[%d]: invalid glob %q: %w", i, glob, err)
+			}
+		}
+		if err := validateMergeFields(rule.List, rule.Map, rule.Conflict); err != nil {
+			return fmt.Errorf("rules[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateMergeFields(list, mapStrategy, conflict string) error {
+	switch list {
+	case "", MergeListAppend, MergeListReplace, MergeListUnion:
+	default:
+		return fmt.Errorf("list must be append, replace or union, got %q", list)
+	}
+	switch mapStrategy {
+	case "", MergeMapMerge, MergeMapReplace:
+	default:
+		return fmt.Errorf("map must be merge or replace, got %q", mapStrategy)
+	}
+	switch conflict {
+	case "", MergeConflictOverride, MergeConflictKeepFirst, MergeConflictError:
+	default:
+		return fmt.Errorf("conflict must be override, keep-first or error, got %q", conflict)
+	}
+	return nil
+}
+
+// Strategy labels recorded in provenance. A key merged with the default
+// strategies is labelled mergeLabelAppend or mergeLabelOverride.
+const (
+	mergeLabelAppend   = "list:" + MergeListAppend
+	mergeLabelOverride = "conflict:" + MergeConflictOverride
+)
+
+// bundleMerger deep-merges a target's imports into its bundle using the
+// target's merge strategy, recording the provenance of every key
+type bundleMerger struct {
+	strategy   MergeStrategy
+	provenance *Provenance
+}
+
+// newBundleMerger returns a merger for a target's merge strategy (nil for the defaults)
+func newBundleMerger(strategy *MergeStrategy) *bundleMerger {
+	m := &bundleMerger{provenance: newProvenance()}
+	if strategy != nil {
+		m.strategy = *strategy
+	}
+	return m
+}
+
+// strategyFor returns the strategy for a key of a secret, with rules applied
+// over the target's settings and the defaults
+func (m *bundleMerger) strategyFor(secretPath, key string) MergeRule {
+	resolved := MergeRule{List: MergeListAppend, Map: MergeMapMerge, Conflict: MergeConflictOverride}
+	apply := func(list, mapStrategy, conflict string) {
+		if list != "" {
+			resolved.List = list
+		}
+		if mapStrategy != "" {
+			resolved.Map = mapStrategy
+		}
+		if conflict != "" {
+			resolved.Conflict = conflict
+		}
+	}
+
+	apply(m.strategy.List, m.strategy.Map, m.strategy.Conflict)
+	for _, rule := range m.strategy.Rules {
+		if globMatches(rule.Secret, secretPath) && globMatches(rule.Key, key) {
+			apply(rule.List, rule.Map, rule.Conflict)
+		}
+	}
+	return resolved
+}
+
+// globMatches reports whether name matches glob; an empty glob matches everything
+func globMatches(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	ok, _ := path.Match(glob, name)
+	return ok
+}
+
+// merge merges src, secret relPath as read from an import, into dst, the
+// secret merged from earlier imports (nil if none supplied it), and returns
+// the result. dst is modified in place.
+func (m *bundleMerger) merge(relPath string, dst, src map[string]interface{}, origin ValueOrigin) (map[string]interface{}, error) {
+	keys, ok := m.provenance.Secrets[relPath]
+	if !ok || dst == nil {
+		keys = make(map[string]KeyProvenance)
+		m.provenance.Secrets[relPath] = keys
+	}
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	if err := m.mergeMap(relPath, keys, "", dst, src, origin); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+func (m *bundleMerger) mergeMap(relPath string, keys map[string]KeyProvenance, prefix string, dst, src map[string]interface{}, origin ValueOrigin) error {
+	// Sorted so a conflict error names the same key every run
+	names := make([]string, 0, len(src))
+	for k := range src {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		srcVal := src[k]
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		dstVal, exists := dst[k]
+		switch {
+		case !exists:
+			dst[k] = utils.DeepCopy(srcVal)
+			recordValue(keys, key, srcVal, origin, nil, "")
+			continue
+		case srcVal == nil:
+			// A nil value keeps the existing one
+			continue
+		case dstVal == nil:
+			dst[k] = utils.DeepCopy(srcVal)
+			recordValue(keys, key, srcVal, origin, takeOrigins(keys, key), "")
+			continue
+		}
+
+		strategy := m.strategyFor(relPath, key)
+		label := "conflict:" + strategy.Conflict
+
+		dstMap, dstIsMap := dstVal.(map[string]interface{})
+		srcMap, srcIsMap := srcVal.(map[string]interface{})
+		if dstIsMap && srcIsMap {
+			if strategy.Map == MergeMapMerge {
+				if err := m.mergeMap(relPath, keys, key, dstMap, srcMap, origin); err != nil {
+					return err
+				}
+				continue
+			}
+			label = "map:" + strategy.Map
+		}
+
+		dstList, dstIsList := dstVal.([]interface{})
+		srcList, srcIsList := srcVal.([]interface{})
+		if dstIsList && srcIsList {
+			if strategy.List == MergeListAppend || strategy.List == MergeListUnion {
+				dst[k] = appendListItems(dstList, srcList, strategy.List == MergeListUnion)
+				kp := keys[key]
+				kp.Appended = appendOrigin(kp.Appended, kp.ValueOrigin)
+				kp.ValueOrigin = origin
+				kp.Strategy = "list:" + strategy.List
+				keys[key] = kp
+				continue
+			}
+			label = "list:" + strategy.List
+		}
+
+		// The values are not combined, so one of them is kept
+		switch strategy.Conflict {
+		case MergeConflictKeepFirst:
+			for name, kp := range keys {
+				if name == key || strings.HasPrefix(name, key+".") {
+					kp.Ignored = appendOrigin(kp.Ignored, origin)
+					kp.Strategy = label
+					keys[name] = kp
+				}
+			}
+			continue
+		case MergeConflictError:
+			if !reflect.DeepEqual(dstVal, srcVal) {
+				return fmt.Errorf("conflicting values for key %q of secret %q from %s and %s (conflict strategy is error)",
+					key, relPath, describeOrigins(keys, key), origin.Path)
+			}
+		}
+		dst[k] = utils.DeepCopy(srcVal)
+		recordValue(keys, key, srcVal, origin, takeOrigins(keys, key), label)
+	}
+	return nil
+}
+
+// appendListItems returns dst followed by the items of src; with union, items
+// already present are skipped
+func appendListItems(dst, src []interface{}, union bool) []interface{} {
+	result := make([]interface{}, 0, len(dst)+len(src))
+	for _, item := range dst {
+		result = append(result, utils.DeepCopy(item))
+	}
+	for _, item := range src {
+		if union && containsValue(result, item) {
+			continue
+		}
+		result = append(result, utils.DeepCopy(item))
+	}
+	return result
+}
+
+func containsValue(items []interface{}, v interface{}) bool {
+	for _, item := range items {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeOrigins names the paths that supplied key and its nested keys
+func describeOrigins(keys map[string]KeyProvenance, key string) string {
+	var paths []string
+	for name, kp := range keys {
+		if (name == key || strings.HasPrefix(name, key+".")) && !slices.Contains(paths, kp.Path) {
+			paths = append(paths, kp.Path)
+		}
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, ", ")
+}
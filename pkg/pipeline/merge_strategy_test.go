@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMerger_Strategies(t *testing.T) {
+	base := ValueOrigin{Import: "base", Path: "base/network"}
+	team := ValueOrigin{Import: "team", Path: "team/network"}
+
+	first := map[string]interface{}{
+		"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12"},
+		"tags":   map[string]interface{}{"env": "prod", "owner": "platform"},
+		"region": "us-east-1",
+	}
+	second := map[string]interface{}{
+		"cidrs":  []interface{}{"172.16.0.0/12", "192.168.0.0/16"},
+		"tags":   map[string]interface{}{"owner": "team"},
+		"region": "eu-west-1",
+	}
+
+	tests := []struct {
+		name     string
+		strategy *MergeStrategy
+		want     map[string]interface{}
+		wantProv map[string]KeyProvenance
+	}{
+		{
+			name:     "defaults",
+			strategy: nil,
+			want: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12", "172.16.0.0/12", "192.168.0.0/16"},
+				"tags":   map[string]interface{}{"env": "prod", "owner": "team"},
+				"region": "eu-west-1",
+			},
+			wantProv: map[string]KeyProvenance{
+				"cidrs":      {ValueOrigin: team, Appended: []ValueOrigin{base}, Strategy: mergeLabelAppend},
+				"tags.env":   {ValueOrigin: base},
+				"tags.owner": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+				"region":     {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+		{
+			name:     "list union and map replace",
+			strategy: &MergeStrategy{List: MergeListUnion, Map: MergeMapReplace},
+			want: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+				"tags":   map[string]interface{}{"owner": "team"},
+				"region": "eu-west-1",
+			},
+			wantProv: map[string]KeyProvenance{
+				"cidrs":      {ValueOrigin: team, Appended: []ValueOrigin{base}, Strategy: "list:union"},
+				"tags.owner": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: "map:replace"},
+				"region":     {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+		{
+			name:     "list replace",
+			strategy: &MergeStrategy{List: MergeListReplace},
+			want: map[string]interface{}{
+				"cidrs":  []interface{}{"172.16.0.0/12", "192.168.0.0/16"},
+				"tags":   map[string]interface{}{"env": "prod", "owner": "team"},
+				"region": "eu-west-1",
+			},
+			wantProv: map[string]KeyProvenance{
+				"cidrs":      {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: "list:replace"},
+				"tags.env":   {ValueOrigin: base},
+				"tags.owner": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+				"region":     {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+		{
+			name:     "keep-first",
+			strategy: &MergeStrategy{Conflict: MergeConflictKeepFirst},
+			want: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12", "172.16.0.0/12", "192.168.0.0/16"},
+				"tags":   map[string]interface{}{"env": "prod", "owner": "platform"},
+				"region": "us-east-1",
+			},
+			wantProv: map[string]KeyProvenance{
+				"cidrs":      {ValueOrigin: team, Appended: []ValueOrigin{base}, Strategy: mergeLabelAppend},
+				"tags.env":   {ValueOrigin: base},
+				"tags.owner": {ValueOrigin: base, Ignored: []ValueOrigin{team}, Strategy: "conflict:keep-first"},
+				"region":     {ValueOrigin: base, Ignored: []ValueOrigin{team}, Strategy: "conflict:keep-first"},
+			},
+		},
+		{
+			name: "rules override the target settings",
+			strategy: &MergeStrategy{List: MergeListReplace, Rules: []MergeRule{
+				{Secret: "net*", Key: "cidrs", List: MergeListUnion},
+				{Secret: "other/*", Conflict: MergeConflictKeepFirst},
+				{Key: "tags.*", Conflict: MergeConflictKeepFirst},
+			}},
+			want: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"},
+				"tags":   map[string]interface{}{"env": "prod", "owner": "platform"},
+				"region": "eu-west-1",
+			},
+			wantProv: map[string]KeyProvenance{
+				"cidrs":      {ValueOrigin: team, Appended: []ValueOrigin{base}, Strategy: "list:union"},
+				"tags.env":   {ValueOrigin: base},
+				"tags.owner": {ValueOrigin: base, Ignored: []ValueOrigin{team}, Strategy: "conflict:keep-first"},
+				"region":     {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newBundleMerger(tt.strategy)
+			merged, err := m.merge("network", nil, first, base)
+			require.NoError(t, err)
+			merged, err = m.merge("network", merged, second, team)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, merged)
+			assert.Equal(t, tt.wantProv, m.provenance.Secrets["network"])
+
+			// The imports are never modified
+			assert.Equal(t, []interface{}{"10.0.0.0/8", "172.16.0.0/12"}, first["cidrs"])
+		})
+	}
+}
+
+func TestBundleMerger_ConflictError(t *testing.T) {
+	base := ValueOrigin{Import: "base", Path: "base/db"}
+	team := ValueOrigin{Import: "team", Path: "team/db"}
+
+	m := newBundleMerger(&MergeStrategy{Conflict: MergeConflictError})
+	merged, err := m.merge("db", nil, map[string]interface{}{"host": "a", "port": "5432"}, base)
+	require.NoError(t, err)
+
+	// Equal values are not a conflict
+	merged, err = m.merge("db", merged, map[string]interface{}{"port": "5432", "user": "app"}, team)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"host": "a", "port": "5432", "user": "app"}, merged)
+
+	_, err = m.merge("db", merged, map[string]interface{}{"host": "b"}, team)
+	assert.EqualError(t, err, `conflicting values for key "host" of secret "db" from base/db and team/db (conflict strategy is error)`)
+}
+
+func TestPipeline_MergeStrategy(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	kv, srv := newFakeKV(t, "shared")
+	kv.put("base/network", map[string]interface{}{"cidrs": []interface{}{"10.0.0.0/8"}, "region": "us-east-1"})
+	kv.put("team/network", map[string]interface{}{"cidrs": []interface{}{"10.0.0.0/8", "192.168.0.0/16"}, "region": "eu-west-1"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srv.URL},
+		Sources: map[string]Source{
+			"base": {Vault: &VaultSource{Mount: "shared/base"}},
+			"team": {Vault: &VaultSource{Mount: "shared/team"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Prod": {Imports: []string{"base", "team"}, Merge: &MergeStrategy{List: MergeListUnion}},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	results, pipelineDiff, err := p.RunWithDiff(ctx, Options{Operation: OperationMerge, DryRun: true})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.NoError(t, results[0].Error)
+	changes := pipelineDiff.Targets[0].Changes
+	require.Len(t, changes, 1)
+	assert.Equal(t, map[string]string{"cidrs": "list:union"}, changes[0].MergeStrategies)
+
+	// A conflict fails the target's merge
+	cfg.Targets["Prod"] = Target{Imports: []string{"base", "team"}, Merge: &MergeStrategy{Conflict: MergeConflictError}}
+	p, err = New(cfg)
+	require.NoError(t, err)
+	results, err = p.Run(ctx, Options{Operation: OperationMerge})
+	require.Error(t, err)
+	require.Len(t, results, 1)
+	assert.ErrorContains(t, results[0].Error, `merge conflict: conflicting values for key "region"`)
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceVaultConfig(t *testing.T) {
+	p := &Pipeline{config: &Config{Vault: VaultConfig{
+		Address:           "https://vault.example.com",
+		Namespace:         "root-ns",
+		Auth:              VaultAuthConfig{Token: &TokenAuth{Token: "global"}},
+		MaxTraversalDepth: 10,
+	}}}
+
+	tests := []struct {
+		name     string
+		src      *VaultSource
+		expected VaultConfig
+	}{
+		{
+			name:     "no vault source",
+			expected: p.config.Vault,
+		},
+		{
+			name:     "inherits global settings",
+			src:      &VaultSource{Mount: "kv"},
+			expected: p.config.Vault,
+		},
+		{
+			name: "overrides connection and limits",
+			src: &VaultSource{
+				Address:            "https://vault-eu.example.com",
+				Namespace:          "team-eu",
+				Auth:               &VaultAuthConfig{Token: &TokenAuth{Token: "eu"}},
+				MaxSecretsPerMount: 50,
+			},
+			expected: VaultConfig{
+				Address:            "https://vault-eu.example.com",
+				Namespace:          "team-eu",
+				Auth:               VaultAuthConfig{Token: &TokenAuth{Token: "eu"}},
+				MaxTraversalDepth:  10,
+				MaxSecretsPerMount: 50,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, p.sourceVaultConfig(tt.src))
+		})
+	}
+}
+
+func TestReadVaultSource_Paths(t *testing.T) {
+	kv, srv := newFakeKV(t, "shared")
+	kv.put("team-a/db", map[string]interface{}{"user": "a"})
+	kv.put("team-a/nested/api", map[string]interface{}{"key": "a"})
+	kv.put("team-b/db", map[string]interface{}{"user": "b"})
+	kv.put("common", map[string]interface{}{"region": "eu"})
+
+	t.Setenv("VAULT_TOKEN", "root")
+	client := newVaultClient(&VaultConfig{Address: srv.URL})
+	require.NoError(t, client.Init(context.Background()))
+
+	p := &Pipeline{config: &Config{}}
+	versions := make(map[string]int)
+	secrets, err := p.readVaultSource(context.Background(), client, "shared/", []string{"team-a/*", "common"}, nil, versions)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"team-a/db":         {"user": "a"},
+		"team-a/nested/api": {"key": "a"},
+		"common":            {"region": "eu"},
+	}, secrets)
+	assert.Equal(t, map[string]int{"shared/team-a/db": 1, "shared/team-a/nested/api": 1, "shared/common": 1}, versions)
+
+	filter, err := newSecretFilter(&v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Exclude: []string{"*/nested"}}})
+	require.NoError(t, err)
+	secrets, err = p.readVaultSource(context.Background(), client, "shared/", nil, filter, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"team-a/db": {"user": "a"},
+		"team-b/db": {"user": "b"},
+		"common":    {"region": "eu"},
+	}, secrets)
+	assert.Equal(t, 1, filter.filtered)
+}
+
+func TestReadAWSSource_NoRole(t *testing.T) {
+	p := &Pipeline{config: &Config{AWS: AWSConfig{Region: "us-east-1"}}}
+
+	// A source in another account is never read with the ambient credentials
+	_, err := p.readAWSSource(context.Background(), &AWSSource{AccountID: "333333333333", Prefix: "shared/"}, nil)
+	assert.EqualError(t, err, "no role to assume into source account 333333333333: set aws.execution_context.custom_role_pattern or enable aws.control_tower")
+}
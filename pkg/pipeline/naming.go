@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// DefaultSecretNameTemplate places secrets at their bundle path under the target's
+// secret_prefix. With no prefix this is the bundle path unchanged.
+const DefaultSecretNameTemplate = "{{.Prefix}}/{{.Path}}"
+
+// SecretNameData is the data available to secret_name_template
+type SecretNameData struct {
+	// Prefix is the target's secret_prefix
+	Prefix string
+	// Target is the target name
+	Target string
+	// AccountID is the target account ID
+	AccountID string
+	// Path is the secret's path relative to the merged bundle
+	Path string
+}
+
+// secretNameFuncs are the helpers available in secret_name_template.
+// Arguments come first so helpers compose in pipelines: {{.Path | replace "/" "-" | lower}}
+var secretNameFuncs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"snake":      func(s string) string { return joinWords(s, "_", strings.ToLower) },
+	"kebab":      func(s string) string { return joinWords(s, "-", strings.ToLower) },
+	"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+}
+
+// ParseSecretNameTemplate parses a secret_name_template. An empty template
+// parses DefaultSecretNameTemplate.
+func ParseSecretNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = DefaultSecretNameTemplate
+	}
+	return template.New("secret_name").Funcs(secretNameFuncs).Option("missingkey=error").Parse(text)
+}
+
+// RenderSecretName renders a destination secret name.
+//
+// Repeated slashes (from empty fields) are collapsed and trailing slashes removed.
+// A leading slash is only kept when the template or the prefix starts with one,
+// so "{{.Prefix}}/{{.Path}}" with no prefix yields "path", not "/path".
+func RenderSecretName(tmpl *template.Template, text string, data SecretNameData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render secret name for %q: %w", data.Path, err)
+	}
+
+	if text == "" {
+		text = DefaultSecretNameTemplate
+	}
+	keepLeading := strings.HasPrefix(text, "/") || strings.HasPrefix(data.Prefix, "/")
+
+	var parts []string
+	for _, part := range strings.Split(buf.String(), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	name := strings.Join(parts, "/")
+	if name == "" {
+		return "", fmt.Errorf("secret name template rendered an empty name for %q", data.Path)
+	}
+	if keepLeading {
+		name = "/" + name
+	}
+	return name, nil
+}
+
+// joinWords splits s into words at separators and lower-to-upper case boundaries,
+// transforms each word and joins them with sep. Slashes are kept as path separators.
+func joinWords(s, sep string, transform func(string) string) string {
+	segments := strings.Split(s, "/")
+	for i, segment := range segments {
+		var words []string
+		var current []rune
+		runes := []rune(segment)
+		for j, r := range runes {
+			switch {
+			case r == '_' || r == '-' || r == ' ' || r == '.':
+				if len(current) > 0 {
+					words = append(words, string(current))
+					current = nil
+				}
+				continue
+			case unicode.IsUpper(r) && j > 0 && unicode.IsLower(runes[j-1]) && len(current) > 0:
+				words = append(words, string(current))
+				current = nil
+			}
+			current = append(current, r)
+		}
+		if len(current) > 0 {
+			words = append(words, string(current))
+		}
+		for k, w := range words {
+			words[k] = transform(w)
+		}
+		segments[i] = strings.Join(words, sep)
+	}
+	return strings.Join(segments, "/")
+}
+
+// secretNames maps every secret in a bundle to its destination name for a target.
+// Sync, the sync diff and orphan detection all use this mapping. Two bundle paths
+// rendering to the same name is an error rather than a silent overwrite.
+func (p *Pipeline) secretNames(targetName string, bundle map[string]map[string]interface{}) (map[string]string, error) {
+	target, ok := p.config.Targets[targetName]
+	if !ok {
+		return nil, fmt.Errorf("target not found: %s", targetName)
+	}
+
+	tmpl, err := ParseSecretNameTemplate(target.SecretNameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret_name_template: %w", err)
+	}
+
+	paths := make([]string, 0, len(bundle))
+	for secretPath := range bundle {
+		paths = append(paths, secretPath)
+	}
+	sort.Strings(paths)
+
+	names := make(map[string]string, len(bundle))
+	seen := make(map[string]string, len(bundle))
+	for _, secretPath := range paths {
+		name, err := RenderSecretName(tmpl, target.SecretNameTemplate, SecretNameData{
+			Prefix:    target.SecretPrefix,
+			Target:    targetName,
+			AccountID: target.AccountID,
+			Path:      secretPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if other, dup := seen[name]; dup {
+			return nil, fmt.Errorf("secret name collision: %q and %q both map to %q", other, secretPath, name)
+		}
+		seen[name] = secretPath
+		names[secretPath] = name
+	}
+
+	return names, nil
+}
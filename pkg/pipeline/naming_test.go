@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSecretName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     SecretNameData
+		expected string
+	}{
+		{
+			name:     "default template without prefix keeps bundle path",
+			data:     SecretNameData{Target: "Stg", Path: "api/datadog"},
+			expected: "api/datadog",
+		},
+		{
+			name:     "default template with prefix",
+			data:     SecretNameData{Prefix: "apps/", Target: "Stg", Path: "api/datadog"},
+			expected: "apps/api/datadog",
+		},
+		{
+			name:     "leading slash prefix is preserved",
+			data:     SecretNameData{Prefix: "/sandbox/", Target: "Stg", Path: "api"},
+			expected: "/sandbox/api",
+		},
+		{
+			name:     "namespaced layout",
+			template: "{{.Prefix}}/{{.Target}}/{{.Path}}",
+			data:     SecretNameData{Prefix: "secretsync", Target: "Serverless_Prod", Path: "db/creds"},
+			expected: "secretsync/Serverless_Prod/db/creds",
+		},
+		{
+			name:     "empty prefix collapses slashes",
+			template: "{{.Prefix}}/{{.Target}}/{{.Path}}",
+			data:     SecretNameData{Target: "Stg", Path: "db"},
+			expected: "Stg/db",
+		},
+		{
+			name:     "case and separator helpers",
+			template: "{{.Target | kebab}}/{{.Path | replace \"/\" \"_\" | upper}}",
+			data:     SecretNameData{Target: "Serverless_Prod", Path: "db/creds"},
+			expected: "serverless-prod/DB_CREDS",
+		},
+		{
+			name:     "snake case splits camel case",
+			template: "{{.Path | snake}}",
+			data:     SecretNameData{Path: "apiKeys/dataDog-token"},
+			expected: "api_keys/data_dog_token",
+		},
+		{
+			name:     "account id",
+			template: "{{.AccountID}}/{{.Path | trimPrefix \"shared/\"}}",
+			data:     SecretNameData{AccountID: "111111111111", Path: "shared/api"},
+			expected: "111111111111/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseSecretNameTemplate(tt.template)
+			require.NoError(t, err)
+			got, err := RenderSecretName(tmpl, tt.template, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestRenderSecretNameErrors(t *testing.T) {
+	_, err := ParseSecretNameTemplate("{{.Path")
+	assert.Error(t, err)
+
+	_, err = ParseSecretNameTemplate("{{.Path | nosuchfunc}}")
+	assert.Error(t, err)
+
+	tmpl, err := ParseSecretNameTemplate("{{.Missing}}")
+	require.NoError(t, err)
+	_, err = RenderSecretName(tmpl, "{{.Missing}}", SecretNameData{Path: "api"})
+	assert.Error(t, err)
+
+	tmpl, err = ParseSecretNameTemplate("{{.Prefix}}")
+	require.NoError(t, err)
+	_, err = RenderSecretName(tmpl, "{{.Prefix}}", SecretNameData{Path: "api"})
+	assert.ErrorContains(t, err, "empty name")
+}
+
+func TestPipelineSecretNames(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		Targets: map[string]Target{
+			"Stg": {
+				AccountID:          "111111111111",
+				SecretPrefix:       "platform",
+				SecretNameTemplate: "{{.Prefix}}/{{.Target | lower}}/{{.Path}}",
+			},
+			"Lower": {SecretNameTemplate: "{{.Path | lower}}"},
+		},
+	}}
+
+	bundle := map[string]map[string]interface{}{
+		"api/datadog": {"key": "v"},
+		"db":          {"user": "u"},
+	}
+
+	names, err := p.secretNames("Stg", bundle)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"api/datadog": "platform/stg/api/datadog",
+		"db":          "platform/stg/db",
+	}, names)
+
+	_, err = p.secretNames("Lower", map[string]map[string]interface{}{
+		"API": {}, "api": {},
+	})
+	assert.ErrorContains(t, err, "secret name collision")
+
+	_, err = p.secretNames("missing", bundle)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"context"
+
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+// notify sends the run report to the configured notification sinks.
+// Delivery failures are logged and never fail the run.
+func (p *Pipeline) notify(ctx context.Context, notifier *notify.Notifier, opts Options, results []Result, runErr error) {
+	if len(p.config.Notifications) == 0 {
+		return
+	}
+
+	report := p.notificationReport(ctx, opts, results, runErr)
+	if err := notifier.Notify(ctx, report); err != nil {
+		log.WithFields(log.Fields{
+			"action":     "Pipeline.notify",
+			"request_id": report.RequestID,
+		}).WithError(err).Warn("Failed to deliver run notifications")
+	}
+}
+
+// notificationReport summarizes a run without any secret values
+func (p *Pipeline) notificationReport(ctx context.Context, opts Options, results []Result, runErr error) notify.Report {
+	var summary diff.ChangeSummary
+	p.diffMu.Lock()
+	if p.pipelineDiff != nil {
+		summary = p.pipelineDiff.Summary
+	}
+	p.diffMu.Unlock()
+
+	return newNotificationReport(reqctx.GetRequestID(ctx), string(opts.Operation), opts.DryRun, results, summary, runErr)
+}
+
+// newNotificationReport builds a report from results and the diff summary.
+// The run fails when runErr is set or any result failed.
+func newNotificationReport(requestID, operation string, dryRun bool, results []Result, summary diff.ChangeSummary, runErr error) notify.Report {
+	report := notify.Report{
+		Operation: operation,
+		Success:   runErr == nil,
+		DryRun:    dryRun,
+		RequestID: requestID,
+		Summary:   summary,
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+
+	for _, r := range results {
+		tr := notify.TargetReport{
+			Target:           r.Target,
+			Phase:            r.Phase,
+			Success:          r.Success,
+			Duration:         r.Duration,
+			SecretsProcessed: r.Details.SecretsProcessed,
+			SecretsRemoved:   r.Details.SecretsRemoved,
+			SecretsFiltered:  r.Details.SecretsFiltered,
+		}
+		if r.Error != nil {
+			tr.Error = r.Error.Error()
+		}
+		if r.Diff != nil {
+			tr.Changes = notify.NewChanges(r.Diff.Changes)
+		}
+		if !r.Success {
+			report.Success = false
+		}
+		report.Targets = append(report.Targets, tr)
+	}
+
+	report.Events = notify.Events(report.Success, report.Summary)
+	return report
+}
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineNotify(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{
+		Notifications: []*v1alpha1.NotificationSpec{{
+			Webhook: &v1alpha1.WebhookNotification{URL: srv.URL, Events: []v1alpha1.NotificationEvent{"failure"}},
+		}},
+	}
+	p := &Pipeline{config: cfg}
+	p.initDiff(false, "")
+
+	targetDiff := diff.TargetDiff{
+		Target: "Stg",
+		Changes: []diff.SecretChange{{
+			Path:          "api",
+			ChangeType:    diff.ChangeTypeModified,
+			KeysModified:  []string{"token"},
+			CurrentValues: map[string]interface{}{"token": "old-secret"},
+			DesiredValues: map[string]interface{}{"token": "new-secret"},
+		}},
+		Summary: diff.ChangeSummary{Modified: 1, Total: 1},
+	}
+	p.addTargetDiff(targetDiff)
+
+	results := []Result{
+		{Target: "Stg", Phase: "sync", Success: true, Details: ResultDetails{SecretsProcessed: 1}, Diff: &targetDiff},
+		{Target: "Prod", Phase: "sync", Success: false, Error: errors.New("access denied")},
+	}
+	notifier := notify.New(cfg.Notifications, "")
+	p.notify(context.Background(), notifier, Options{Operation: OperationSync}, results, nil)
+
+	require.NotEmpty(t, body)
+	assert.NotContains(t, string(body), "old-secret")
+	assert.NotContains(t, string(body), "new-secret")
+
+	var report notify.Report
+	require.NoError(t, json.Unmarshal(body, &report))
+	assert.False(t, report.Success)
+	assert.Equal(t, []v1alpha1.NotificationEvent{"failure", "changes"}, report.Events)
+	assert.Equal(t, 1, report.Summary.Modified)
+	require.Len(t, report.Targets, 2)
+	assert.Equal(t, []string{"token"}, report.Targets[0].Changes[0].KeysModified)
+	assert.Equal(t, "access denied", report.Targets[1].Error)
+}
@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"context"
+	"sort"
+)
+
+// Ownership tags written on every secret SecretSync creates in a target account.
+// Orphan deletion only considers secrets carrying both tags for the target, so
+// secrets created by other tools (or by hand) are never removed.
+const (
+	ManagedByTagKey   = "secretsync:managed-by"
+	ManagedByTagValue = "secretsync"
+	TargetTagKey      = "secretsync:target"
+)
+
+// ownershipTags returns the tags that mark a secret as created for a target
+func ownershipTags(targetName string) map[string]string {
+	return map[string]string{
+		ManagedByTagKey: ManagedByTagValue,
+		TargetTagKey:    targetName,
+	}
+}
+
+// findOrphans returns the destination entries SecretSync created for the target
+// that the bundle no longer produces, sorted for stable output.
+func (p *Pipeline) findOrphans(ctx context.Context, dest Destination, entries map[string]interface{}) ([]string, error) {
+	managed, err := dest.Managed(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, name := range managed {
+		if _, ok := entries[name]; !ok {
+			orphans = append(orphans, name)
+		}
+	}
+	sort.Strings(orphans)
+
+	return orphans, nil
+}
@@ -0,0 +1,114 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/pkg/client/aws"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipTags(t *testing.T) {
+	tags := ownershipTags("Serverless_Prod")
+
+	assert.Equal(t, map[string]string{
+		"secretsync:managed-by": "secretsync",
+		"secretsync:target":     "Serverless_Prod",
+	}, tags)
+
+	// Tags are scoped per target so one target never prunes another's secrets
+	assert.NotEqual(t, tags, ownershipTags("Serverless_Stg"))
+}
+
+// fakeSecretsManager serves ListSecrets, filtering by tag key as AWS does, and
+// records DeleteSecret calls
+type fakeSecretsManager struct {
+	secrets map[string]map[string]string // name -> tags
+	deleted map[string]int64             // secret ID -> recovery window
+}
+
+func (f *fakeSecretsManager) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	switch r.Header.Get("X-Amz-Target") {
+	case "secretsmanager.ListSecrets":
+		var input struct {
+			Filters []struct {
+				Key    string
+				Values []string
+			}
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+
+		var list []map[string]interface{}
+	secrets:
+		for name, tags := range f.secrets {
+			for _, filter := range input.Filters {
+				if _, ok := tags[filter.Values[0]]; filter.Key == "tag-key" && !ok {
+					continue secrets
+				}
+			}
+			var tagList []map[string]string
+			for k, v := range tags {
+				tagList = append(tagList, map[string]string{"Key": k, "Value": v})
+			}
+			list = append(list, map[string]interface{}{"Name": name, "ARN": "arn:" + name, "Tags": tagList})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"SecretList": list})
+
+	case "secretsmanager.DeleteSecret":
+		var input struct {
+			SecretId             string
+			RecoveryWindowInDays int64
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		f.deleted[input.SecretId] = input.RecoveryWindowInDays
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"ARN": input.SecretId})
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestSecretsManagerDestination_DeleteOrphans(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CA_BUNDLE", "")
+	ctx := context.Background()
+
+	fake := &fakeSecretsManager{
+		secrets: map[string]map[string]string{
+			"app/db":     ownershipTags("Serverless_Prod"),
+			"app/old":    ownershipTags("Serverless_Prod"),
+			"stg/old":    ownershipTags("Serverless_Stg"),
+			"manual/key": {"team": "platform"},
+			"untracked":  {TargetTagKey: "Serverless_Prod"},
+		},
+		deleted: map[string]int64{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(srv.Close)
+
+	client := &aws.AwsClient{Region: "us-east-1"}
+	require.NoError(t, client.CreateClientWithEndpoint(ctx, srv.URL))
+	dest := &secretsManagerDestination{
+		client:             client,
+		targetName:         "Serverless_Prod",
+		recoveryWindowDays: 7,
+	}
+
+	p := &Pipeline{}
+	orphans, err := p.findOrphans(ctx, dest, map[string]interface{}{"app/db": map[string]interface{}{"password": "x"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app/old"}, orphans)
+
+	// Only the target's own orphan is deleted; unowned and other targets' secrets are kept
+	_, removed, failed := writeEntries(ctx, log.NewEntry(log.StandardLogger()), dest, nil, orphans)
+	assert.Empty(t, failed)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, map[string]int64{"arn:app/old": 7}, fake.deleted)
+}
@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	"github.com/extended-data-library/secretssync/pkg/utils"
+)
+
+const (
+	// overridesImport is the import recorded in provenance for override values
+	overridesImport = "overrides"
+	// overrideInlinePath is the path recorded in provenance for inline values
+	overrideInlinePath = "inline"
+	// mergeLabelOverrideValue is the strategy recorded for override values
+	mergeLabelOverrideValue = "override"
+)
+
+// validate checks an override's secret path, keys and Vault references
+func (o SecretOverride) validate() error {
+	if o.Secret == "" {
+		return fmt.Errorf("secret is required")
+	}
+	if len(o.Values) == 0 && len(o.VaultRefs) == 0 {
+		return fmt.Errorf("values or vault_refs is required")
+	}
+	for key, ref := range o.VaultRefs {
+		if _, ok := o.Values[key]; ok {
+			return fmt.Errorf("key %q is set in both values and vault_refs", key)
+		}
+		if _, _, err := parseVaultRef(ref); err != nil {
+			return fmt.Errorf("vault_refs.%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// validate checks a tombstone's globs
+func (r SecretRemoval) validate() error {
+	if r.Secret == "" {
+		return fmt.Errorf("secret is required")
+	}
+	for _, glob := range append([]string{r.Secret}, r.Keys...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+	return nil
+}
+
+// parseVaultRef splits a "mount/path/to/secret#key" reference
+func parseVaultRef(ref string) (secretPath, key string, err error) {
+	secretPath, key, ok := strings.Cut(ref, "#")
+	secretPath = strings.Trim(secretPath, "/")
+	if !ok || key == "" || !strings.Contains(secretPath, "/") {
+		return "", "", fmt.Errorf("reference %q must be in mount/path/to/secret#key format", ref)
+	}
+	return secretPath, key, nil
+}
+
+// hasVaultRefs reports whether any override references a Vault secret
+func hasVaultRefs(overrides []SecretOverride) bool {
+	for _, o := range overrides {
+		if len(o.VaultRefs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveVaultRefs reads the values referenced by a target's overrides, keyed
+// by reference. Each referenced secret is read once.
+func resolveVaultRefs(ctx context.Context, client *vault.VaultClient, overrides []SecretOverride) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	secrets := make(map[string]map[string]interface{})
+	for _, o := range overrides {
+		for _, ref := range o.VaultRefs {
+			secretPath, key, err := parseVaultRef(ref)
+			if err != nil {
+				return nil, err
+			}
+			data, ok := secrets[secretPath]
+			if !ok {
+				if data, err = client.GetKVSecretOnce(ctx, secretPath); err != nil {
+					return nil, fmt.Errorf("failed to read %s: %w", ref, err)
+				}
+				secrets[secretPath] = data
+			}
+			value, ok := data[key]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found in %s", key, secretPath)
+			}
+			values[ref] = value
+		}
+	}
+	return values, nil
+}
+
+// applyOverrides sets the keys of the overrides on the merged secrets, with
+// referenced values taken from refValues
+func (m *bundleMerger) applyOverrides(secrets map[string]interface{}, overrides []SecretOverride, refValues map[string]interface{}) {
+	for _, o := range overrides {
+		secret, _ := secrets[o.Secret].(map[string]interface{})
+		if secret == nil {
+			secret = make(map[string]interface{})
+			secrets[o.Secret] = secret
+		}
+		keys, ok := m.provenance.Secrets[o.Secret]
+		if !ok {
+			keys = make(map[string]KeyProvenance)
+			m.provenance.Secrets[o.Secret] = keys
+		}
+
+		set := func(key string, value interface{}, origin ValueOrigin) {
+			secret[key] = utils.DeepCopy(value)
+			recordValue(keys, key, value, origin, takeOrigins(keys, key), mergeLabelOverrideValue)
+		}
+		for key, value := range o.Values {
+			set(key, value, ValueOrigin{Import: overridesImport, Path: overrideInlinePath})
+		}
+		for key, ref := range o.VaultRefs {
+			set(key, refValues[ref], ValueOrigin{Import: overridesImport, Path: ref})
+		}
+	}
+}
+
+// applyRemovals deletes the keys and secrets matched by the tombstones from
+// the merged secrets, recording the removed keys in the provenance
+func (m *bundleMerger) applyRemovals(secrets map[string]interface{}, removals []SecretRemoval) {
+	for _, r := range removals {
+		for relPath, v := range secrets {
+			if !globMatches(r.Secret, relPath) {
+				continue
+			}
+			secret, _ := v.(map[string]interface{})
+			keys := m.provenance.Secrets[relPath]
+
+			var removed []string
+			for key := range secret {
+				if len(r.Keys) == 0 || matchesAny(r.Keys, key) {
+					removed = append(removed, key)
+				}
+			}
+			for _, key := range removed {
+				delete(secret, key)
+				takeOrigins(keys, key)
+			}
+			if len(r.Keys) == 0 {
+				delete(secrets, relPath)
+				delete(m.provenance.Secrets, relPath)
+			}
+			m.provenance.recordRemoved(relPath, removed)
+		}
+	}
+}
+
+// matchesAny reports whether name matches any of the globs
+func matchesAny(globs []string, name string) bool {
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordRemoved records keys of a secret deleted by a tombstone
+func (p *Provenance) recordRemoved(relPath string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if p.Removed == nil {
+		p.Removed = make(map[string][]string)
+	}
+	for _, key := range keys {
+		if !slices.Contains(p.Removed[relPath], key) {
+			p.Removed[relPath] = append(p.Removed[relPath], key)
+		}
+	}
+	sort.Strings(p.Removed[relPath])
+}
@@ -0,0 +1,174 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMerger_OverridesAndRemovals(t *testing.T) {
+	base := ValueOrigin{Import: "Base", Path: "Base/api/stripe"}
+	ref := "secret/prod/stripe#live_key"
+
+	m := newBundleMerger(nil)
+	secrets := make(map[string]interface{})
+	for relPath, data := range map[string]map[string]interface{}{
+		"api/stripe":   {"mode": "test", "secret_key": "sk_test", "test_key": "pk_test"},
+		"legacy/db":    {"password": "old"},
+		"legacy/cache": {"url": "redis://old"},
+	} {
+		merged, err := m.merge(relPath, nil, data, ValueOrigin{Import: "Base", Path: "Base/" + relPath})
+		require.NoError(t, err)
+		secrets[relPath] = merged
+	}
+
+	m.applyOverrides(secrets, []SecretOverride{
+		{Secret: "api/stripe", Values: map[string]interface{}{"mode": "live"}, VaultRefs: map[string]string{"secret_key": ref}},
+		{Secret: "api/feature", Values: map[string]interface{}{"enabled": true}},
+	}, map[string]interface{}{ref: "sk_live"})
+	m.applyRemovals(secrets, []SecretRemoval{
+		{Secret: "api/stripe", Keys: []string{"test_*"}},
+		{Secret: "legacy/*"},
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"api/stripe":  map[string]interface{}{"mode": "live", "secret_key": "sk_live"},
+		"api/feature": map[string]interface{}{"enabled": true},
+	}, secrets)
+
+	assert.Equal(t, map[string]map[string]KeyProvenance{
+		"api/stripe": {
+			"mode": {
+				ValueOrigin: ValueOrigin{Import: overridesImport, Path: overrideInlinePath},
+				Overrode:    []ValueOrigin{base},
+				Strategy:    mergeLabelOverrideValue,
+			},
+			"secret_key": {
+				ValueOrigin: ValueOrigin{Import: overridesImport, Path: ref},
+				Overrode:    []ValueOrigin{base},
+				Strategy:    mergeLabelOverrideValue,
+			},
+		},
+		"api/feature": {
+			"enabled": {ValueOrigin: ValueOrigin{Import: overridesImport, Path: overrideInlinePath}, Strategy: mergeLabelOverrideValue},
+		},
+	}, m.provenance.Secrets)
+	assert.Equal(t, map[string][]string{
+		"api/stripe":   {"test_key"},
+		"legacy/db":    {"password"},
+		"legacy/cache": {"url"},
+	}, m.provenance.Removed)
+}
+
+func TestSecretOverride_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		override SecretOverride
+		errMsg   string
+	}{
+		{name: "inline values", override: SecretOverride{Secret: "api", Values: map[string]interface{}{"mode": "live"}}},
+		{name: "vault ref", override: SecretOverride{Secret: "api", VaultRefs: map[string]string{"key": "secret/prod/api#key"}}},
+		{name: "missing secret", override: SecretOverride{Values: map[string]interface{}{"mode": "live"}}, errMsg: "secret is required"},
+		{name: "nothing to set", override: SecretOverride{Secret: "api"}, errMsg: "values or vault_refs is required"},
+		{
+			name:     "key set twice",
+			override: SecretOverride{Secret: "api", Values: map[string]interface{}{"key": "x"}, VaultRefs: map[string]string{"key": "secret/prod/api#key"}},
+			errMsg:   `key "key" is set in both values and vault_refs`,
+		},
+		{
+			name:     "ref without key",
+			override: SecretOverride{Secret: "api", VaultRefs: map[string]string{"key": "secret/prod/api"}},
+			errMsg:   "must be in mount/path/to/secret#key format",
+		},
+		{
+			name:     "ref without mount",
+			override: SecretOverride{Secret: "api", VaultRefs: map[string]string{"key": "api#key"}},
+			errMsg:   "must be in mount/path/to/secret#key format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.override.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestPipeline_OverridesAndRemovals(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	kv, srv := newFakeKV(t, "shared")
+	kv.put("app/api/stripe", map[string]interface{}{"mode": "test", "secret_key": "sk_test", "test_key": "pk_test"})
+	kv.put("app/legacy/db", map[string]interface{}{"password": "old"})
+	kv.put("prod/stripe", map[string]interface{}{"live_key": "sk_live"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srv.URL},
+		Sources: map[string]Source{
+			"app": {Vault: &VaultSource{Mount: "shared/app"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg": {Imports: []string{"app"}},
+			"Prod": {
+				Imports: []string{"Stg"},
+				Overrides: []SecretOverride{{
+					Secret:    "api/stripe",
+					Values:    map[string]interface{}{"mode": "live"},
+					VaultRefs: map[string]string{"secret_key": "shared/prod/stripe#live_key"},
+				}},
+				Remove: []SecretRemoval{
+					{Secret: "api/stripe", Keys: []string{"test_key"}},
+					{Secret: "legacy/db"},
+				},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	_, err = p.Run(ctx, Options{Operation: OperationMerge})
+	require.NoError(t, err)
+
+	bundle, err := p.readTargetBundle(ctx, "Prod")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"api/stripe": {"mode": "live", "secret_key": "sk_live"},
+	}, bundle)
+
+	explanations, err := p.Explain(ctx, "Prod", "api/stripe", "secret_key")
+	require.NoError(t, err)
+	assert.Equal(t, ValueOrigin{Import: overridesImport, Path: "shared/prod/stripe#live_key"}, explanations[0].Chain[0].ValueOrigin)
+	_, err = p.Explain(ctx, "Prod", "api/stripe", "test_key")
+	assert.ErrorContains(t, err, `key "test_key" was removed`)
+	_, err = p.Explain(ctx, "Prod", "legacy/db", "")
+	assert.ErrorContains(t, err, `secret "legacy/db" was removed`)
+
+	// Tombstoned keys show up in the diff once they leave an existing bundle
+	prod := cfg.Targets["Prod"]
+	prod.Remove = append(prod.Remove, SecretRemoval{Secret: "api/stripe", Keys: []string{"mode"}})
+	cfg.Targets["Prod"] = prod
+	p, err = New(cfg)
+	require.NoError(t, err)
+	_, pipelineDiff, err := p.RunWithDiff(ctx, Options{Operation: OperationMerge, DryRun: true})
+	require.NoError(t, err)
+	var changes []string
+	for _, td := range pipelineDiff.Targets {
+		if td.Target != "Prod" {
+			continue
+		}
+		for _, c := range td.Changes {
+			changes = append(changes, c.Path)
+			assert.Equal(t, []string{"mode", "test_key"}, c.Tombstoned)
+			assert.Equal(t, map[string]string{"secret_key": mergeLabelOverrideValue}, c.MergeStrategies)
+		}
+	}
+	assert.Equal(t, []string{"api/stripe"}, changes)
+}
@@ -35,12 +35,15 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
 	reqctx "github.com/extended-data-library/secretssync/pkg/context"
 	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -63,8 +66,13 @@ type Pipeline struct {
 	initialized bool
 	mu          sync.Mutex
 
-	awsCtx  *AWSExecutionContext
-	s3Store *S3MergeStore
+	awsCtx     *AWSExecutionContext
+	mergeStore MergeStore
+	// mergeStoreErr is why a configured merge store could not be created;
+	// only operations that need the store fail with it
+	mergeStoreErr error
+	// locks is the run lock backend, created on first use
+	locks lockBackend
 
 	results   []Result
 	resultsMu sync.Mutex
@@ -82,6 +90,12 @@ type Options struct {
 	Parallelism     int
 	ComputeDiff     bool
 	OutputFormat    diff.OutputFormat
+	// LockTimeout is how long to wait for run locks held by other runs
+	// (pipeline.lock); zero fails at once
+	LockTimeout time.Duration
+	// AllowLargeChanges lets targets proceed past tripped guardrails
+	// (pipeline.guardrails)
+	AllowLargeChanges bool
 }
 
 // DefaultOptions returns sensible default options
@@ -114,10 +128,13 @@ type ResultDetails struct {
 	SecretsModified  int      `json:"secrets_modified,omitempty"`
 	SecretsRemoved   int      `json:"secrets_removed,omitempty"`
 	SecretsUnchanged int      `json:"secrets_unchanged,omitempty"`
+	SecretsFiltered  int      `json:"secrets_filtered,omitempty"`
 	SourcePaths      []string `json:"source_paths,omitempty"`
 	DestinationPath  string   `json:"destination_path,omitempty"`
 	RoleARN          string   `json:"role_arn,omitempty"`
 	FailedImports    []string `json:"failed_imports,omitempty"`
+	// Guardrail is the guardrail that aborted the target
+	Guardrail string `json:"guardrail,omitempty"`
 }
 
 // New creates a new Pipeline from configuration
@@ -131,12 +148,28 @@ func New(cfg *Config) (*Pipeline, error) {
 		return nil, fmt.Errorf("failed to build dependency graph: %w", err)
 	}
 
+	mergeStore, err := NewMergeStore(context.Background(), cfg)
+	if err != nil {
+		log.WithError(err).Warn("Failed to initialize merge store")
+	}
+
 	return &Pipeline{
-		config: cfg,
-		graph:  graph,
+		config:        cfg,
+		graph:         graph,
+		mergeStore:    mergeStore,
+		mergeStoreErr: err,
 	}, nil
 }
 
+// errNoMergeStore returns the error for an operation that needs the merge
+// store when the pipeline has none
+func (p *Pipeline) errNoMergeStore() error {
+	if p.mergeStoreErr != nil {
+		return fmt.Errorf("failed to initialize merge store: %w", p.mergeStoreErr)
+	}
+	return fmt.Errorf("no merge store configured")
+}
+
 // NewWithContext creates a new Pipeline with AWS execution context
 func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 	p, err := New(cfg)
@@ -154,16 +187,6 @@ func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 		}
 	}
 
-	// Initialize S3 merge store if configured
-	if cfg.MergeStore.S3 != nil {
-		s3Store, err := NewS3MergeStore(ctx, cfg.MergeStore.S3, cfg.AWS.Region)
-		if err != nil {
-			log.WithError(err).Warn("Failed to initialize S3 merge store")
-		} else {
-			p.s3Store = s3Store
-		}
-	}
-
 	return p, nil
 }
 
@@ -188,6 +211,14 @@ func NewFromFileWithContext(ctx context.Context, path string) (*Pipeline, error)
 // Run executes the pipeline with the given options.
 // Each operation (merge, sync) is distinct and idempotent.
 func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
+	results, _, err := p.RunWithDiff(ctx, opts)
+	return results, err
+}
+
+// RunWithDiff runs like Run and also returns the diff computed by this run,
+// taken before another run can replace it. The diff is nil unless
+// opts.DryRun or opts.ComputeDiff is set; later runs never change it.
+func (p *Pipeline) RunWithDiff(ctx context.Context, opts Options) ([]Result, *diff.PipelineDiff, error) {
 	// Generate request ID and add to context
 	reqCtx := reqctx.NewRequestContext()
 	ctx = reqctx.WithRequestContext(ctx, reqCtx)
@@ -206,8 +237,13 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 	p.results = nil
 	p.resultsMu.Unlock()
 
-	if opts.DryRun || opts.ComputeDiff {
+	// A changes-detected notification needs the diff even when the caller did not ask for it
+	notifier := notify.New(p.config.Notifications, p.config.NotificationsTemplate)
+	diffForNotify := !opts.DryRun && !opts.ComputeDiff && notifier.Subscribes(v1alpha1.NotificationEventChangesDetected)
+	if opts.DryRun || opts.ComputeDiff || diffForNotify {
 		p.initDiff(opts.DryRun, "")
+	} else {
+		p.resetDiff()
 	}
 
 	targets := p.resolveTargets(opts.Targets)
@@ -222,18 +258,31 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 
 	p.initialized = true
 
-	var results []Result
-	var err error
-
+	var run func(context.Context, []string, Options) ([]Result, error)
 	switch opts.Operation {
 	case OperationMerge:
-		results, err = p.runMerge(ctx, targets, opts)
+		run = p.runMerge
 	case OperationSync:
-		results, err = p.runSync(ctx, targets, opts)
+		run = p.runSync
 	case OperationPipeline:
-		results, err = p.runPipeline(ctx, targets, opts)
+		run = p.runPipeline
 	default:
-		return nil, fmt.Errorf("unknown operation: %s", opts.Operation)
+		return nil, nil, fmt.Errorf("unknown operation: %s", opts.Operation)
+	}
+
+	runCtx, unlock, err := p.lockRun(ctx, targets, opts)
+	if err != nil {
+		l.WithError(err).Error("Failed to acquire run lock")
+		return nil, nil, err
+	}
+	results, err := run(runCtx, targets, opts)
+	if lockErr := unlock(); lockErr != nil {
+		err = errors.Join(err, lockErr)
+	}
+
+	p.notify(ctx, notifier, opts, results, err)
+	if diffForNotify {
+		p.resetDiff()
 	}
 
 	if err != nil {
@@ -248,7 +297,11 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 		}).Info("Pipeline execution completed successfully")
 	}
 
-	return results, err
+	var runDiff *diff.PipelineDiff
+	if opts.DryRun || opts.ComputeDiff {
+		runDiff = p.Diff()
+	}
+	return results, runDiff, err
 }
 
 // resolveTargets returns the targets to process, including dependencies
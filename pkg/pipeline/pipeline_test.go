@@ -3,8 +3,10 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"testing"
 
+	"github.com/extended-data-library/secretssync/pkg/diff"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -90,6 +92,30 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNew_MergeStoreUnavailable(t *testing.T) {
+	cfg := &Config{
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{
+			Path:    t.TempDir(),
+			KeyFile: filepath.Join(t.TempDir(), "missing.key"),
+		}},
+		Targets: map[string]Target{"Stg": {}},
+	}
+
+	// Commands that never touch the store, like validate and graph, still work
+	p, err := New(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Stg"}, p.graph.TopologicalOrder())
+
+	results, err := p.Run(context.Background(), Options{Operation: OperationMerge})
+	require.Error(t, err)
+	require.Len(t, results, 1)
+	require.Error(t, results[0].Error)
+	assert.Contains(t, results[0].Error.Error(), "failed to initialize merge store")
+
+	_, err = p.Plan(context.Background(), Options{})
+	assert.ErrorContains(t, err, "failed to initialize merge store")
+}
+
 func TestPipeline_Operations(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -527,3 +553,62 @@ func TestS3Config(t *testing.T) {
 	assert.Equal(t, "merge/", config.Prefix)
 	assert.Equal(t, "key-123", config.KMSKeyID)
 }
+
+func TestPipeline_RunWithDiff(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+
+	src, srcSrv := newFakeKV(t, "analytics")
+	src.put("db", map[string]interface{}{"password": "hunter2"})
+	dst, dstSrv := newFakeKV(t, "replica")
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Team_EU": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	results, runDiff, err := p.RunWithDiff(context.Background(), Options{Operation: OperationPipeline, ComputeDiff: true})
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	require.NotNil(t, runDiff)
+	// One change in the merge store and one at the destination
+	assert.Equal(t, 2, runDiff.Summary.Added)
+	assert.Same(t, p.Diff(), runDiff)
+	assert.Contains(t, dst.data, "db")
+
+	src.put("db", map[string]interface{}{"password": "changed"})
+	_, dryDiff, err := p.RunWithDiff(context.Background(), Options{Operation: OperationPipeline, DryRun: true})
+	require.NoError(t, err)
+	require.NotNil(t, dryDiff)
+	// A dry-run merge does not write the bundle, so only the merge store changes
+	assert.Equal(t, 1, dryDiff.Summary.Modified)
+	assert.Equal(t, map[string]interface{}{"password": "hunter2"}, dst.data["db"])
+	dryOutput := diff.FormatDiff(dryDiff, diff.OutputFormatJSON)
+
+	// Without dry run or diff no diff is returned. The dry run's diff is read
+	// while it runs, as the control API does, and is not added to.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			_ = diff.FormatDiff(dryDiff, diff.OutputFormatJSON)
+		}
+	}()
+	_, runDiff, err = p.RunWithDiff(context.Background(), Options{Operation: OperationPipeline})
+	<-done
+	require.NoError(t, err)
+	assert.Nil(t, runDiff)
+	assert.Nil(t, p.Diff())
+	assert.Equal(t, map[string]interface{}{"password": "changed"}, dst.data["db"])
+	assert.Equal(t, dryOutput, diff.FormatDiff(dryDiff, diff.OutputFormatJSON))
+}
@@ -0,0 +1,443 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// planFormatVersion is the plan file format written by Plan
+const planFormatVersion = 2
+
+// Plan records the merge and sync a pipeline run would perform, for Apply to
+// perform exactly, once reviewed. It holds salted hashes of whole bundles and
+// destination states, never secret values or hashes of single secrets, which
+// could be guessed offline with the salt at hand.
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// ConfigDigest is the SHA-256 of the configuration the plan was made with
+	ConfigDigest string `json:"config_digest"`
+	// Salt is mixed into every content hash of the plan
+	Salt    string             `json:"salt"`
+	Targets []TargetPlan       `json:"targets"`
+	Diff    *diff.PipelineDiff `json:"diff,omitempty"`
+}
+
+// TargetPlan is the planned merge and sync of one target
+type TargetPlan struct {
+	Target   string `json:"target"`
+	BundleID string `json:"bundle_id"`
+	// BundleHash is the hash of the merged bundle and Secrets its sorted secret paths
+	BundleHash string   `json:"bundle_hash"`
+	Secrets    []string `json:"secrets,omitempty"`
+	// StoredBundleHash is the hash of the bundle in the merge store, empty if none
+	StoredBundleHash string `json:"stored_bundle_hash,omitempty"`
+
+	Destination string `json:"destination"`
+	// DestinationHash is the hash of the destination's values of the bundle's
+	// entries and of the entries to delete
+	DestinationHash string `json:"destination_hash"`
+	// Writes are the entries the plan creates or updates; their values are
+	// covered by BundleHash
+	Writes  []string `json:"writes,omitempty"`
+	Deletes []string `json:"deletes,omitempty"`
+}
+
+// PlanDriftError is returned by Apply when the configuration, sources or
+// destinations changed since the plan was made
+type PlanDriftError struct {
+	// Target is the target that drifted, empty for the configuration
+	Target string
+	Reason string
+}
+
+func (e *PlanDriftError) Error() string {
+	if e.Target == "" {
+		return fmt.Sprintf("plan is stale: %s; run plan again", e.Reason)
+	}
+	return fmt.Sprintf("plan is stale: target %s: %s; run plan again", e.Target, e.Reason)
+}
+
+// LoadPlan reads a plan file written from a Plan
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if plan.Version != planFormatVersion {
+		return nil, fmt.Errorf("unsupported plan version %d (expected %d)", plan.Version, planFormatVersion)
+	}
+	return &plan, nil
+}
+
+// configDigest returns the SHA-256 of the pipeline's configuration
+func (p *Pipeline) configDigest() (string, error) {
+	data, err := json.Marshal(p.config)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saltedHash returns the SHA-256 of salt followed by v's JSON encoding
+func saltedHash(salt string, v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash content: %w", err)
+	}
+	h := sha256.New()
+	h.Write([]byte(salt))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// targetState is a target's merged bundle and destination state, read
+// without writing anything
+type targetState struct {
+	bundle  *mergedBundle
+	sync    *preparedSync
+	current map[string]interface{}
+	plan    TargetPlan
+}
+
+// readTargetState merges a target and reads the destination state its sync
+// would change. Inherited targets are taken from pending, and the target's
+// bundle is added to it.
+func (p *Pipeline) readTargetState(ctx context.Context, l *log.Entry, targetName, salt string, pending map[string]map[string]map[string]interface{}) (*targetState, error) {
+	target := p.config.Targets[targetName]
+	bundle, err := p.buildBundle(ctx, l, targetName, target, pending)
+	if err != nil {
+		return nil, err
+	}
+	if len(bundle.failedSources) > 0 {
+		return nil, fmt.Errorf("failed to read from %d sources: %v", len(bundle.failedSources), bundle.failedSources)
+	}
+
+	secretsData := make(map[string]map[string]interface{}, len(bundle.secrets))
+	for relPath, data := range bundle.secrets {
+		if m, ok := data.(map[string]interface{}); ok {
+			secretsData[relPath] = m
+		}
+	}
+	pending[targetName] = secretsData
+
+	prepared, err := p.prepareSync(ctx, l, targetName, target, secretsData)
+	if err != nil {
+		return nil, err
+	}
+	current, err := prepared.dest.Read(ctx, entryNames(prepared.entries, prepared.orphans))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination state: %w", err)
+	}
+
+	tp := TargetPlan{
+		Target:      targetName,
+		BundleID:    bundle.bundleID,
+		Secrets:     slices.Sorted(maps.Keys(bundle.secrets)),
+		Destination: prepared.dest.Location(),
+	}
+	if tp.BundleHash, err = saltedHash(salt, bundle.secrets); err != nil {
+		return nil, err
+	}
+	if stored, err := p.mergeStore.ReadMergedBundle(ctx, targetName, bundle.bundleID); err == nil {
+		if tp.StoredBundleHash, err = saltedHash(salt, stored); err != nil {
+			return nil, err
+		}
+	}
+	if tp.DestinationHash, err = saltedHash(salt, current); err != nil {
+		return nil, err
+	}
+
+	// Only entries that differ from the destination are written
+	names := make([]string, 0, len(prepared.entries))
+	for name := range prepared.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if currentValue, ok := current[name]; ok && utils.DeepEqual(currentValue, prepared.entries[name]) {
+			continue
+		}
+		tp.Writes = append(tp.Writes, name)
+	}
+	tp.Deletes = slices.Sorted(slices.Values(prepared.orphans))
+
+	return &targetState{bundle: bundle, sync: prepared, current: current, plan: tp}, nil
+}
+
+// checkPlanGuardrails checks a planned target's merge against its stored
+// bundle and its sync against the destination state
+func (p *Pipeline) checkPlanGuardrails(ctx context.Context, targetName string, state *targetState) error {
+	guardrails := p.guardrailsFor(p.config.Targets[targetName])
+	if !guardrails.enabled() {
+		return nil
+	}
+	current := p.storedSecrets(ctx, targetName, state.bundle.bundleID)
+	if guardErr := guardrails.check(targetName, "merge", current, state.bundle.secrets); guardErr != nil {
+		return guardErr
+	}
+	if guardErr := guardrails.check(targetName, "sync", state.current, state.sync.entries); guardErr != nil {
+		return guardErr
+	}
+	return nil
+}
+
+// entryNames returns the names of the entries and the orphans
+func entryNames(entries map[string]interface{}, orphans []string) []string {
+	names := append([]string{}, orphans...)
+	for name := range entries {
+		names = append(names, name)
+	}
+	return names
+}
+
+// drift compares the state read when applying with the planned state
+func (tp TargetPlan) drift(planned TargetPlan) error {
+	driftErr := func(format string, args ...interface{}) error {
+		return &PlanDriftError{Target: tp.Target, Reason: fmt.Sprintf(format, args...)}
+	}
+	if tp.BundleHash != planned.BundleHash {
+		// Only added and removed secrets can be named; values are hashed per bundle
+		var changed []string
+		for _, relPath := range tp.Secrets {
+			if !slices.Contains(planned.Secrets, relPath) {
+				changed = append(changed, relPath)
+			}
+		}
+		for _, relPath := range planned.Secrets {
+			if !slices.Contains(tp.Secrets, relPath) {
+				changed = append(changed, relPath)
+			}
+		}
+		if len(changed) == 0 {
+			return driftErr("source state changed")
+		}
+		sort.Strings(changed)
+		return driftErr("source secrets added or removed: %v", changed)
+	}
+	if tp.Destination != planned.Destination {
+		return driftErr("destination changed from %s to %s", planned.Destination, tp.Destination)
+	}
+	if !slices.Equal(tp.Deletes, planned.Deletes) {
+		return driftErr("entries to delete changed from %v to %v", planned.Deletes, tp.Deletes)
+	}
+	if tp.DestinationHash != planned.DestinationHash {
+		return driftErr("destination state changed")
+	}
+	if !slices.Equal(tp.Writes, planned.Writes) {
+		return driftErr("planned writes changed")
+	}
+	return nil
+}
+
+// Plan merges and resolves the sync of the selected targets (opts.Targets,
+// with their dependencies) without writing anything, and returns what Apply
+// would do. Inherited targets are merged from the planned bundles of their
+// parents. The diff against the merge store and destinations is in the
+// plan's Diff. A target that trips its guardrails fails the plan unless
+// opts.AllowLargeChanges is set.
+func (p *Pipeline) Plan(ctx context.Context, opts Options) (*Plan, error) {
+	reqCtx := reqctx.NewRequestContext()
+	ctx = reqctx.WithRequestContext(ctx, reqCtx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetDiff()
+
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+	digest, err := p.configDigest()
+	if err != nil {
+		return nil, err
+	}
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return nil, fmt.Errorf("failed to generate plan salt: %w", err)
+	}
+
+	plan := &Plan{
+		Version:      planFormatVersion,
+		CreatedAt:    time.Now().UTC(),
+		ConfigDigest: digest,
+		Salt:         hex.EncodeToString(saltBytes),
+	}
+	p.initDiff(true, "")
+
+	pending := make(map[string]map[string]map[string]interface{})
+	for _, targetName := range p.resolveTargets(opts.Targets) {
+		if _, ok := p.config.Targets[targetName]; !ok {
+			return nil, fmt.Errorf("target not found: %s", targetName)
+		}
+		l := log.WithFields(log.Fields{
+			"action":     "Pipeline.Plan",
+			"target":     targetName,
+			"request_id": reqCtx.RequestID,
+		})
+		state, err := p.readTargetState(ctx, l, targetName, plan.Salt, pending)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan target %s: %w", targetName, err)
+		}
+		mergeDiff := p.computeMergeDiff(ctx, targetName, state.bundle.bundleID, state.bundle.secrets, state.bundle.provenance)
+		p.addTargetDiff(*mergeDiff)
+		changes := diff.DiffSecrets(state.current, state.sync.entries)
+		p.addTargetDiff(diff.TargetDiff{Target: targetName, Changes: changes, Summary: diff.ComputeSummary(changes)})
+		if !opts.AllowLargeChanges {
+			if err := p.checkPlanGuardrails(ctx, targetName, state); err != nil {
+				return nil, err
+			}
+		}
+		plan.Targets = append(plan.Targets, state.plan)
+
+		l.WithFields(log.Fields{
+			"writes":  len(state.plan.Writes),
+			"deletes": len(state.plan.Deletes),
+		}).Info("Planned target")
+	}
+
+	plan.Diff = p.Diff()
+	return plan, nil
+}
+
+// Apply performs a plan made by Plan. The configuration, every planned
+// bundle and the destination state are read again first; if any of them
+// changed, Apply returns a PlanDriftError without writing anything.
+// Otherwise the changed bundles are written to the merge store and exactly
+// the planned destination writes and deletes are made. Only opts.LockTimeout
+// is used.
+func (p *Pipeline) Apply(ctx context.Context, plan *Plan, opts Options) ([]Result, error) {
+	reqCtx := reqctx.NewRequestContext()
+	ctx = reqctx.WithRequestContext(ctx, reqCtx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resetDiff()
+
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+	digest, err := p.configDigest()
+	if err != nil {
+		return nil, err
+	}
+	if digest != plan.ConfigDigest {
+		return nil, &PlanDriftError{Reason: "configuration changed since the plan was made"}
+	}
+
+	targetNames := make([]string, 0, len(plan.Targets))
+	for _, tp := range plan.Targets {
+		targetNames = append(targetNames, tp.Target)
+	}
+	runCtx, unlock, err := p.lockRun(ctx, targetNames, Options{LockTimeout: opts.LockTimeout})
+	if err != nil {
+		return nil, err
+	}
+	results, err := p.applyPlan(runCtx, plan, reqCtx.RequestID)
+	if lockErr := unlock(); lockErr != nil {
+		err = errors.Join(err, lockErr)
+	}
+
+	p.resultsMu.Lock()
+	p.results = results
+	p.resultsMu.Unlock()
+	return results, err
+}
+
+func (p *Pipeline) applyPlan(ctx context.Context, plan *Plan, requestID string) ([]Result, error) {
+	l := log.WithFields(log.Fields{
+		"action":     "Pipeline.Apply",
+		"request_id": requestID,
+	})
+
+	// Verify every target before writing anything
+	pending := make(map[string]map[string]map[string]interface{})
+	states := make([]*targetState, 0, len(plan.Targets))
+	for _, planned := range plan.Targets {
+		if _, ok := p.config.Targets[planned.Target]; !ok {
+			return nil, fmt.Errorf("target not found: %s", planned.Target)
+		}
+		state, err := p.readTargetState(ctx, l.WithField("target", planned.Target), planned.Target, plan.Salt, pending)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read state of target %s: %w", planned.Target, err)
+		}
+		if err := state.plan.drift(planned); err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	var results []Result
+	for _, state := range states {
+		start := time.Now()
+		tp := state.plan
+		tl := l.WithField("target", tp.Target)
+		bundlePath := p.mergeStore.GetBundlePath(tp.Target, tp.BundleID)
+
+		if tp.StoredBundleHash != tp.BundleHash {
+			if err := p.writeBundle(ctx, tp.Target, state.bundle); err != nil {
+				results = append(results, Result{
+					Target:   tp.Target,
+					Phase:    "apply",
+					Success:  false,
+					Error:    err,
+					Duration: time.Since(start),
+				})
+				return results, err
+			}
+		}
+
+		writes := make(map[string]interface{}, len(tp.Writes))
+		for _, name := range tp.Writes {
+			writes[name] = state.sync.entries[name]
+		}
+		written, removed, failed := writeEntries(ctx, tl, state.sync.dest, writes, tp.Deletes)
+
+		result := Result{
+			Target:    tp.Target,
+			Phase:     "apply",
+			Operation: "apply",
+			Success:   len(failed) == 0,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SecretsProcessed: written,
+				SecretsRemoved:   removed,
+				SecretsUnchanged: len(state.sync.entries) - len(tp.Writes),
+				SourcePaths:      []string{bundlePath},
+				DestinationPath:  tp.Destination,
+				RoleARN:          p.getRoleARNForTarget(p.config.Targets[tp.Target]),
+			},
+		}
+		if len(failed) > 0 {
+			result.Error = fmt.Errorf("failed to sync %d secrets: %v", len(failed), failed)
+			results = append(results, result)
+			return results, result.Error
+		}
+		results = append(results, result)
+
+		tl.WithFields(log.Fields{
+			"written": written,
+			"removed": removed,
+		}).Info("Applied planned target")
+	}
+	return results, nil
+}
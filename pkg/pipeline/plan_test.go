@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_PlanApply(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	src, srcSrv := newFakeKV(t, "analytics")
+	stg, stgSrv := newFakeKV(t, "replica")
+	prod, prodSrv := newFakeKV(t, "replica")
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srcSrv.URL},
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Stg": {
+				Imports:     []string{"analytics"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: stgSrv.URL, Mount: "replica"}},
+			},
+			"Prod": {
+				Imports:     []string{"Stg"},
+				Destination: DestinationConfig{Vault: &VaultDestination{Address: prodSrv.URL, Mount: "replica"}},
+			},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	src.put("db", map[string]interface{}{"password": "hunter2"})
+	src.put("cache", map[string]interface{}{"url": "redis://"})
+	stg.put("db", map[string]interface{}{"password": "hunter2"})
+
+	plan, err := p.Plan(ctx, Options{})
+	require.NoError(t, err)
+	require.Len(t, plan.Targets, 2)
+	assert.Equal(t, "Stg", plan.Targets[0].Target)
+	assert.Equal(t, "Prod", plan.Targets[1].Target)
+	// Unchanged entries are not written; Prod is planned from Stg's planned bundle
+	assert.Equal(t, []string{"replica/cache"}, plan.Targets[0].Writes)
+	assert.Equal(t, []string{"replica/cache", "replica/db"}, plan.Targets[1].Writes)
+	assert.Equal(t, []string{"cache", "db"}, plan.Targets[0].Secrets)
+	assert.Empty(t, plan.Targets[0].StoredBundleHash)
+	require.NotNil(t, plan.Diff)
+	assert.True(t, plan.Diff.Summary.Added > 0)
+
+	// Planning writes nothing
+	bundles, err := p.mergeStore.ListBundles(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Empty(t, bundles)
+	assert.NotContains(t, stg.data, "cache")
+	assert.Empty(t, prod.data)
+
+	// Plans round-trip through a file without secret values
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+	// nor hashes of single secrets that could be guessed with the salt
+	secretHash, err := saltedHash(plan.Salt, map[string]interface{}{"password": "hunter2"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secretHash)
+	require.NoError(t, os.WriteFile(planFile, data, 0o600))
+	loaded, err := LoadPlan(planFile)
+	require.NoError(t, err)
+
+	results, err := p.Apply(ctx, loaded, Options{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, 1, results[0].Details.SecretsProcessed)
+	assert.Equal(t, 1, results[0].Details.SecretsUnchanged)
+	assert.Equal(t, map[string]interface{}{"url": "redis://"}, stg.data["cache"])
+	assert.Equal(t, map[string]interface{}{"password": "hunter2"}, prod.data["db"])
+	bundle, err := p.readTargetBundle(ctx, "Prod")
+	require.NoError(t, err)
+	assert.Len(t, bundle, 2)
+
+	// Applying again finds nothing left to write
+	plan, err = p.Plan(ctx, Options{Targets: []string{"Stg"}})
+	require.NoError(t, err)
+	require.Len(t, plan.Targets, 1)
+	assert.Empty(t, plan.Targets[0].Writes)
+	assert.Equal(t, plan.Targets[0].BundleHash, plan.Targets[0].StoredBundleHash)
+}
+
+func TestPipeline_ApplyDrift(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	newPipeline := func(t *testing.T) (*Pipeline, *Config, *fakeKV, *fakeKV) {
+		src, srcSrv := newFakeKV(t, "analytics")
+		dst, dstSrv := newFakeKV(t, "replica")
+		src.put("db", map[string]interface{}{"password": "hunter2"})
+		cfg := &Config{
+			Vault: VaultConfig{Address: srcSrv.URL},
+			Sources: map[string]Source{
+				"analytics": {Vault: &VaultSource{Address: srcSrv.URL, Mount: "analytics"}},
+			},
+			MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+			Targets: map[string]Target{
+				"Stg": {
+					Imports:     []string{"analytics"},
+					Destination: DestinationConfig{Vault: &VaultDestination{Address: dstSrv.URL, Mount: "replica"}},
+				},
+			},
+		}
+		p, err := New(cfg)
+		require.NoError(t, err)
+		return p, cfg, src, dst
+	}
+
+	tests := []struct {
+		name    string
+		drift   func(t *testing.T, p *Pipeline, cfg *Config, src, dst *fakeKV) *Pipeline
+		wantErr string
+	}{
+		{
+			name: "source changed",
+			drift: func(t *testing.T, p *Pipeline, cfg *Config, src, dst *fakeKV) *Pipeline {
+				src.put("db", map[string]interface{}{"password": "rotated"})
+				return p
+			},
+			wantErr: "plan is stale: target Stg: source state changed",
+		},
+		{
+			name: "source secret added",
+			drift: func(t *testing.T, p *Pipeline, cfg *Config, src, dst *fakeKV) *Pipeline {
+				src.put("queue", map[string]interface{}{"url": "amqp://"})
+				return p
+			},
+			wantErr: "plan is stale: target Stg: source secrets added or removed: [queue]",
+		},
+		{
+			name: "destination changed",
+			drift: func(t *testing.T, p *Pipeline, cfg *Config, src, dst *fakeKV) *Pipeline {
+				dst.put("db", map[string]interface{}{"password": "manual"})
+				return p
+			},
+			wantErr: "plan is stale: target Stg: destination state changed",
+		},
+		{
+			name: "config changed",
+			drift: func(t *testing.T, p *Pipeline, cfg *Config, src, dst *fakeKV) *Pipeline {
+				cfg.Pipeline.Sync.DeleteOrphans = true
+				changed, err := New(cfg)
+				require.NoError(t, err)
+				return changed
+			},
+			wantErr: "plan is stale: configuration changed since the plan was made",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, cfg, src, dst := newPipeline(t)
+			plan, err := p.Plan(ctx, Options{})
+			require.NoError(t, err)
+
+			p = tt.drift(t, p, cfg, src, dst)
+			before := len(dst.data)
+			_, err = p.Apply(ctx, plan, Options{})
+			var driftErr *PlanDriftError
+			require.ErrorAs(t, err, &driftErr)
+			assert.EqualError(t, err, tt.wantErr+"; run plan again")
+
+			// Nothing is written
+			assert.Len(t, dst.data, before)
+			bundles, err := p.mergeStore.ListBundles(ctx, "Stg")
+			require.NoError(t, err)
+			assert.Empty(t, bundles)
+		})
+	}
+}
+
+func TestLoadPlan_Version(t *testing.T) {
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, os.WriteFile(planFile, []byte(`{"version": 99}`), 0o600))
+	_, err := LoadPlan(planFile)
+	assert.EqualError(t, err, "unsupported plan version 99 (expected 2)")
+}
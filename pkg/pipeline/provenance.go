@@ -0,0 +1,245 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// provenanceSuffix is appended to a bundle's name for the provenance stored next to it
+const provenanceSuffix = ".provenance"
+
+// errNoProvenance is returned by ReadProvenance when no provenance is stored for a bundle
+var errNoProvenance = errors.New("no provenance recorded")
+
+// ProvenanceStore is implemented by merge stores that keep the key provenance
+// of each bundle next to it
+type ProvenanceStore interface {
+	// WriteProvenance replaces the provenance of a bundle
+	WriteProvenance(ctx context.Context, targetName, bundleID string, prov *Provenance) error
+	// ReadProvenance returns the provenance of a bundle, or errNoProvenance
+	ReadProvenance(ctx context.Context, targetName, bundleID string) (*Provenance, error)
+}
+
+// Compile-time interface checks
+var (
+	_ ProvenanceStore = (*S3MergeStore)(nil)
+	_ ProvenanceStore = (*VaultMergeStore)(nil)
+	_ ProvenanceStore = (*FileMergeStore)(nil)
+)
+
+// ValueOrigin identifies an import that supplied a value
+type ValueOrigin struct {
+	// Import is the source or inherited target name
+	Import string `json:"import"`
+	// Path is the secret's path in the import, e.g. "analytics/db"
+	Path string `json:"path"`
+}
+
+// KeyProvenance records where the merged value of a key came from
+type KeyProvenance struct {
+	// ValueOrigin is the import whose value was kept
+	ValueOrigin
+	// Overrode lists earlier imports whose values were replaced, in merge order
+	Overrode []ValueOrigin `json:"overrode,omitempty"`
+	// Appended lists earlier imports whose list items precede this import's
+	Appended []ValueOrigin `json:"appended,omitempty"`
+	// Ignored lists later imports whose values were discarded by keep-first
+	Ignored []ValueOrigin `json:"ignored,omitempty"`
+	// Strategy is the merge strategy that resolved the value when several
+	// imports supplied it, e.g. "list:union" or "conflict:keep-first"
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// Provenance records, for every key of every secret in a merged bundle, the
+// import that supplied it. Keys of nested maps are joined with dots, e.g.
+// "db.host", matching how nested maps are deep-merged.
+type Provenance struct {
+	// Secrets maps secret paths to keys to their provenance
+	Secrets map[string]map[string]KeyProvenance `json:"secrets"`
+	// Removed maps secret paths to the keys deleted by remove tombstones
+	Removed map[string][]string `json:"removed,omitempty"`
+}
+
+// newProvenance returns an empty provenance
+func newProvenance() *Provenance {
+	return &Provenance{Secrets: make(map[string]map[string]KeyProvenance)}
+}
+
+// recordValue records origin for key, or for each key of a nested map
+func recordValue(keys map[string]KeyProvenance, key string, value interface{}, origin ValueOrigin, overrode []ValueOrigin, strategy string) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for k, v := range m {
+			recordValue(keys, key+"."+k, v, origin, overrode, strategy)
+		}
+		return
+	}
+	keys[key] = KeyProvenance{ValueOrigin: origin, Overrode: overrode, Strategy: strategy}
+}
+
+// takeOrigins removes the provenance of key and its nested keys, returning
+// every origin involved in their values
+func takeOrigins(keys map[string]KeyProvenance, key string) []ValueOrigin {
+	var names []string
+	for k := range keys {
+		if k == key || strings.HasPrefix(k, key+".") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	var origins []ValueOrigin
+	for _, name := range names {
+		kp := keys[name]
+		for _, o := range kp.Overrode {
+			origins = appendOrigin(origins, o)
+		}
+		for _, o := range kp.Appended {
+			origins = appendOrigin(origins, o)
+		}
+		for _, o := range kp.Ignored {
+			origins = appendOrigin(origins, o)
+		}
+		origins = appendOrigin(origins, kp.ValueOrigin)
+		delete(keys, name)
+	}
+	return origins
+}
+
+// appendOrigin appends o unless it is already listed
+func appendOrigin(origins []ValueOrigin, o ValueOrigin) []ValueOrigin {
+	for _, existing := range origins {
+		if existing == o {
+			return origins
+		}
+	}
+	return append(origins, o)
+}
+
+// nonDefaultStrategies returns the keys of a secret resolved by a
+// configured, non-default merge strategy, with the strategy
+func (p *Provenance) nonDefaultStrategies(relPath string) map[string]string {
+	var strategies map[string]string
+	for key, kp := range p.Secrets[relPath] {
+		if kp.Strategy == "" || kp.Strategy == mergeLabelAppend || kp.Strategy == mergeLabelOverride {
+			continue
+		}
+		if strategies == nil {
+			strategies = make(map[string]string)
+		}
+		strategies[key] = kp.Strategy
+	}
+	return strategies
+}
+
+// ExplainStep is one merge in the provenance chain of a key
+type ExplainStep struct {
+	// Target is the target whose merge kept the value
+	Target string `json:"target"`
+	KeyProvenance
+}
+
+// KeyExplanation is the provenance chain of one key, from the explained
+// target down through inherited targets to the source that supplied it
+type KeyExplanation struct {
+	Key   string        `json:"key"`
+	Chain []ExplainStep `json:"chain"`
+}
+
+// readProvenance returns the provenance of a target's current bundle
+func (p *Pipeline) readProvenance(ctx context.Context, targetName string) (*Provenance, error) {
+	store, ok := p.mergeStore.(ProvenanceStore)
+	if !ok {
+		return nil, fmt.Errorf("merge store does not record provenance")
+	}
+	bundleID := BundleID(p.config.GetTargetSourcePaths(targetName))
+	prov, err := store.ReadProvenance(ctx, targetName, bundleID)
+	if errors.Is(err, errNoProvenance) {
+		return nil, fmt.Errorf("no provenance recorded for target %s: run the merge phase to record it", targetName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provenance of %s: %w", targetName, err)
+	}
+	return prov, nil
+}
+
+// Explain returns the provenance chains of the keys of a secret in a target's
+// merged bundle, or of one key if key is set. Chains follow values inherited
+// from other targets to the source that supplied them.
+func (p *Pipeline) Explain(ctx context.Context, targetName, secretPath, key string) ([]KeyExplanation, error) {
+	if _, ok := p.config.Targets[targetName]; !ok {
+		return nil, fmt.Errorf("target not found: %s", targetName)
+	}
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+
+	// Provenance is read once per target; inheritance is acyclic
+	cache := make(map[string]*Provenance)
+	keysOf := func(target string) (map[string]KeyProvenance, error) {
+		prov, ok := cache[target]
+		if !ok {
+			var err error
+			if prov, err = p.readProvenance(ctx, target); err != nil {
+				return nil, err
+			}
+			cache[target] = prov
+		}
+		return prov.Secrets[secretPath], nil
+	}
+
+	keys, err := keysOf(targetName)
+	if err != nil {
+		return nil, err
+	}
+	removed := cache[targetName].Removed[secretPath]
+	if keys == nil {
+		if len(removed) > 0 {
+			return nil, fmt.Errorf("secret %q was removed from target %s by a remove tombstone", secretPath, targetName)
+		}
+		return nil, fmt.Errorf("secret %q not found in target %s", secretPath, targetName)
+	}
+
+	var names []string
+	if key != "" {
+		if _, ok := keys[key]; !ok {
+			if slices.Contains(removed, key) {
+				return nil, fmt.Errorf("key %q was removed from secret %q of target %s by a remove tombstone", key, secretPath, targetName)
+			}
+			return nil, fmt.Errorf("key %q not found in secret %q of target %s", key, secretPath, targetName)
+		}
+		names = []string{key}
+	} else {
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	explanations := make([]KeyExplanation, 0, len(names))
+	for _, name := range names {
+		explanation := KeyExplanation{Key: name}
+		target := targetName
+		for {
+			targetKeys, err := keysOf(target)
+			if err != nil {
+				return nil, err
+			}
+			kp, ok := targetKeys[name]
+			if !ok {
+				// The inherited bundle changed since this target was merged
+				break
+			}
+			explanation.Chain = append(explanation.Chain, ExplainStep{Target: target, KeyProvenance: kp})
+			if _, inherited := p.config.Targets[kp.Import]; !inherited {
+				break
+			}
+			target = kp.Import
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations, nil
+}
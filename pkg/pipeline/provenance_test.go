@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance_Merge(t *testing.T) {
+	base := ValueOrigin{Import: "base", Path: "base/db"}
+	team := ValueOrigin{Import: "team", Path: "team/db"}
+
+	tests := []struct {
+		name   string
+		first  map[string]interface{}
+		second map[string]interface{}
+		want   map[string]KeyProvenance
+	}{
+		{
+			name:   "scalar override",
+			first:  map[string]interface{}{"user": "app", "password": "old"},
+			second: map[string]interface{}{"password": "new"},
+			want: map[string]KeyProvenance{
+				"user":     {ValueOrigin: base},
+				"password": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+		{
+			name:   "nested maps merge per key",
+			first:  map[string]interface{}{"conn": map[string]interface{}{"host": "a", "port": 5432}},
+			second: map[string]interface{}{"conn": map[string]interface{}{"host": "b"}},
+			want: map[string]KeyProvenance{
+				"conn.host": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+				"conn.port": {ValueOrigin: base},
+			},
+		},
+		{
+			name:   "lists are appended",
+			first:  map[string]interface{}{"hosts": []interface{}{"a"}},
+			second: map[string]interface{}{"hosts": []interface{}{"b"}},
+			want: map[string]KeyProvenance{
+				"hosts": {ValueOrigin: team, Appended: []ValueOrigin{base}, Strategy: mergeLabelAppend},
+			},
+		},
+		{
+			name:   "scalar replaces map",
+			first:  map[string]interface{}{"conn": map[string]interface{}{"host": "a", "port": 5432}},
+			second: map[string]interface{}{"conn": "postgres://b"},
+			want: map[string]KeyProvenance{
+				"conn": {ValueOrigin: team, Overrode: []ValueOrigin{base}, Strategy: mergeLabelOverride},
+			},
+		},
+		{
+			name:   "nil keeps the existing value",
+			first:  map[string]interface{}{"password": "old"},
+			second: map[string]interface{}{"password": nil},
+			want: map[string]KeyProvenance{
+				"password": {ValueOrigin: base},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newBundleMerger(nil)
+			merged, err := m.merge("db", nil, tt.first, base)
+			require.NoError(t, err)
+			_, err = m.merge("db", merged, tt.second, team)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m.provenance.Secrets["db"])
+		})
+	}
+}
+
+func TestProvenanceStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) MergeStore{
+		"file": func(t *testing.T) MergeStore {
+			s, err := NewFileMergeStore(&MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()})
+			require.NoError(t, err)
+			return s
+		},
+		"s3": func(t *testing.T) MergeStore {
+			s, _ := newFakeS3Store(t, 0)
+			return s
+		},
+		"vault": func(t *testing.T) MergeStore {
+			_, srv := newFakeKV(t, "merged")
+			t.Setenv("VAULT_TOKEN", "root")
+			return NewVaultMergeStore(&MergeStoreVault{Mount: "merged"}, &VaultConfig{Address: srv.URL})
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			provStore := store.(ProvenanceStore)
+
+			_, err := provStore.ReadProvenance(ctx, "Stg", "b1")
+			assert.ErrorIs(t, err, errNoProvenance)
+
+			merger := newBundleMerger(nil)
+			_, err = merger.merge("db", nil, map[string]interface{}{"password": "x"}, ValueOrigin{Import: "analytics", Path: "analytics/db"})
+			require.NoError(t, err)
+			prov := merger.provenance
+			require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", map[string]interface{}{"db": map[string]interface{}{"password": "x"}}))
+			require.NoError(t, provStore.WriteProvenance(ctx, "Stg", "b1", prov))
+
+			got, err := provStore.ReadProvenance(ctx, "Stg", "b1")
+			require.NoError(t, err)
+			assert.Equal(t, prov, got)
+
+			// Provenance is not listed or read as part of the bundle
+			bundles, err := store.ListBundles(ctx, "Stg")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"b1"}, bundles)
+			secrets, err := store.ReadMergedBundle(ctx, "Stg", "b1")
+			require.NoError(t, err)
+			assert.Len(t, secrets, 1)
+
+			require.NoError(t, store.DeleteBundle(ctx, "Stg", "b1"))
+			_, err = provStore.ReadProvenance(ctx, "Stg", "b1")
+			assert.ErrorIs(t, err, errNoProvenance)
+		})
+	}
+}
+
+func TestPipeline_Explain(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "root")
+	ctx := context.Background()
+
+	kv, srv := newFakeKV(t, "shared")
+	kv.put("defaults/db", map[string]interface{}{"user": "app", "password": "changeme", "port": "5432"})
+	kv.put("team/db", map[string]interface{}{"password": "hunter2"})
+
+	cfg := &Config{
+		Vault: VaultConfig{Address: srv.URL},
+		Sources: map[string]Source{
+			"defaults": {Vault: &VaultSource{Mount: "shared/defaults"}},
+			"team":     {Vault: &VaultSource{Mount: "shared/team"}},
+		},
+		MergeStore: MergeStoreConfig{File: &MergeStoreFile{Path: t.TempDir(), Key: testFileStoreKey()}},
+		Targets: map[string]Target{
+			"Base":    {Imports: []string{"defaults"}},
+			"Team_EU": {Imports: []string{"Base", "team"}},
+		},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+	_, err = p.Run(ctx, Options{Operation: OperationMerge})
+	require.NoError(t, err)
+
+	explanations, err := p.Explain(ctx, "Team_EU", "db", "")
+	require.NoError(t, err)
+	require.Len(t, explanations, 3)
+	assert.Equal(t, []string{"password", "port", "user"}, []string{explanations[0].Key, explanations[1].Key, explanations[2].Key})
+
+	// Overridden by a direct import
+	assert.Equal(t, []ExplainStep{{
+		Target: "Team_EU",
+		KeyProvenance: KeyProvenance{
+			ValueOrigin: ValueOrigin{Import: "team", Path: "shared/team/db"},
+			Overrode:    []ValueOrigin{{Import: "Base", Path: "Base/db"}},
+			Strategy:    mergeLabelOverride,
+		},
+	}}, explanations[0].Chain)
+
+	// Followed through the inherited target to its source
+	explanations, err = p.Explain(ctx, "Team_EU", "db", "user")
+	require.NoError(t, err)
+	require.Len(t, explanations, 1)
+	assert.Equal(t, []ExplainStep{
+		{Target: "Team_EU", KeyProvenance: KeyProvenance{ValueOrigin: ValueOrigin{Import: "Base", Path: "Base/db"}}},
+		{Target: "Base", KeyProvenance: KeyProvenance{ValueOrigin: ValueOrigin{Import: "defaults", Path: "shared/defaults/db"}}},
+	}, explanations[0].Chain)
+
+	_, err = p.Explain(ctx, "Team_EU", "db", "nope")
+	assert.ErrorContains(t, err, `key "nope" not found`)
+	_, err = p.Explain(ctx, "Team_EU", "cache", "")
+	assert.ErrorContains(t, err, `secret "cache" not found`)
+	_, err = p.Explain(ctx, "Nope", "db", "")
+	assert.ErrorContains(t, err, "target not found")
+}
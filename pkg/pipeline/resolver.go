@@ -297,7 +297,7 @@ func (r *ResourceResolver) ResolveAll(names []string) []ResolvedResource {
 // DetectAuthProviders checks what authentication is available
 type AuthProviders struct {
 	VaultAvailable bool
-	VaultMethod    string // token, approle, kubernetes
+	VaultMethod    string // token, approle, kubernetes, jwt, aws-iam
 	AWSAvailable   bool
 	AWSMethod      string // env, iam_role, profile
 }
@@ -315,6 +315,10 @@ func DetectAuth(cfg *Config) AuthProviders {
 			result.VaultMethod = "approle"
 		} else if cfg.Vault.Auth.Kubernetes != nil {
 			result.VaultMethod = "kubernetes"
+		} else if cfg.Vault.Auth.JWT != nil {
+			result.VaultMethod = "jwt"
+		} else if cfg.Vault.Auth.AWS != nil {
+			result.VaultMethod = "aws-iam"
 		}
 	}
 
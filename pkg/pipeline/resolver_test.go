@@ -219,6 +219,28 @@ func TestDetectAuth(t *testing.T) {
 			expectedVault: true,
 			vaultMethod:   "kubernetes",
 		},
+		{
+			name: "vault jwt auth",
+			cfg: &Config{
+				Vault: VaultConfig{
+					Address: "http://vault:8200",
+					Auth:    VaultAuthConfig{JWT: &JWTAuth{Role: "ci", TokenEnv: "VAULT_JWT"}},
+				},
+			},
+			expectedVault: true,
+			vaultMethod:   "jwt",
+		},
+		{
+			name: "vault aws iam auth",
+			cfg: &Config{
+				Vault: VaultConfig{
+					Address: "http://vault:8200",
+					Auth:    VaultAuthConfig{AWS: &AWSIAMAuth{Role: "secretsync-ec2"}},
+				},
+			},
+			expectedVault: true,
+			vaultMethod:   "aws-iam",
+		},
 		{
 			name: "aws with region",
 			cfg: &Config{
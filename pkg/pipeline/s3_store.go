@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -23,6 +25,8 @@ type SecretVersion struct {
 	Data      map[string]interface{} `json:"data"`
 	Timestamp time.Time              `json:"timestamp"`
 	Hash      string                 `json:"hash,omitempty"`
+	// BundleID is set on versions of a merged bundle
+	BundleID string `json:"bundle_id,omitempty"`
 }
 
 // VersionStore interface for version management (v1.2.0 - Requirement 24)
@@ -304,10 +308,60 @@ func (s *S3MergeStore) WriteMergedBundle(ctx context.Context, targetName, bundle
 		return fmt.Errorf("failed to put object: %w", err)
 	}
 
+	if s.VersioningEnabled {
+		if err := s.recordBundleVersion(ctx, targetName, bundleID, secrets); err != nil {
+			return fmt.Errorf("failed to record bundle version: %w", err)
+		}
+	}
+
 	l.WithField("secretsCount", len(secrets)).Debug("Successfully wrote bundle to S3")
 	return nil
 }
 
+// recordBundleVersion stores the bundle as the target's next version, unless
+// it is identical to the latest one
+func (s *S3MergeStore) recordBundleVersion(ctx context.Context, targetName, bundleID string, secrets map[string]interface{}) error {
+	hash, err := bundleHash(secrets)
+	if err != nil {
+		return err
+	}
+
+	versions, err := s.ListBundleVersions(ctx, targetName)
+	if err != nil {
+		return err
+	}
+	next := 1
+	if len(versions) > 0 {
+		if versions[0].Hash == hash && versions[0].BundleID == bundleID {
+			log.WithFields(log.Fields{
+				"action":  "S3MergeStore.recordBundleVersion",
+				"target":  targetName,
+				"version": versions[0].Version,
+			}).Debug("Bundle unchanged, not recording a version")
+			return nil
+		}
+		next = versions[0].Version + 1
+	}
+
+	return s.StoreVersion(ctx, &SecretVersion{
+		Path:     bundleVersionPath(targetName),
+		Version:  next,
+		Data:     secrets,
+		Hash:     hash,
+		BundleID: bundleID,
+	})
+}
+
+// ListBundleVersions lists the recorded bundles of a target, newest first
+func (s *S3MergeStore) ListBundleVersions(ctx context.Context, targetName string) ([]SecretVersion, error) {
+	return s.ListVersions(ctx, bundleVersionPath(targetName))
+}
+
+// GetBundleVersion returns a recorded bundle of a target
+func (s *S3MergeStore) GetBundleVersion(ctx context.Context, targetName string, version int) (*SecretVersion, error) {
+	return s.GetVersion(ctx, bundleVersionPath(targetName), version)
+}
+
 // ReadMergedBundle reads a complete merged bundle from S3
 func (s *S3MergeStore) ReadMergedBundle(ctx context.Context, targetName, bundleID string) (map[string]map[string]interface{}, error) {
 	l := log.WithFields(log.Fields{
@@ -351,6 +405,45 @@ func (s *S3MergeStore) ReadMergedBundle(ctx context.Context, targetName, bundleI
 	return result, nil
 }
 
+// ListBundles lists all bundle IDs stored for a target
+func (s *S3MergeStore) ListBundles(ctx context.Context, targetName string) ([]string, error) {
+	l := log.WithFields(log.Fields{
+		"action": "S3MergeStore.ListBundles",
+		"bucket": s.Bucket,
+		"target": targetName,
+	})
+	l.Debug("Listing bundles from S3")
+
+	prefix := s.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	bundlePrefix := fmt.Sprintf("%sbundles/%s/", prefix, targetName)
+
+	var bundles []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(bundlePrefix),
+	})
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+
+		for _, obj := range output.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), bundlePrefix)
+			if !strings.HasSuffix(name, ".json") || strings.Contains(name, "/") || strings.HasSuffix(name, provenanceSuffix+".json") || strings.HasSuffix(name, manifestSuffix+".json") {
+				continue
+			}
+			bundles = append(bundles, strings.TrimSuffix(name, ".json"))
+		}
+	}
+
+	return bundles, nil
+}
+
 // DeleteBundle deletes a bundle from S3
 func (s *S3MergeStore) DeleteBundle(ctx context.Context, targetName, bundleID string) error {
 	l := log.WithFields(log.Fields{
@@ -361,19 +454,125 @@ func (s *S3MergeStore) DeleteBundle(ctx context.Context, targetName, bundleID st
 	})
 	l.Debug("Deleting bundle from S3")
 
-	key := s.bundleKey(targetName, bundleID)
+	for _, key := range []string{s.bundleKey(targetName, bundleID), s.provenanceKey(targetName, bundleID), s.manifestKey(targetName, bundleID)} {
+		_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete object: %w", err)
+		}
+	}
 
-	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+	return nil
+}
+
+// provenanceKey returns the S3 key for a bundle's provenance, next to the bundle
+func (s *S3MergeStore) provenanceKey(targetName, bundleID string) string {
+	return strings.TrimSuffix(s.bundleKey(targetName, bundleID), ".json") + provenanceSuffix + ".json"
+}
+
+// WriteProvenance writes a bundle's provenance to S3
+func (s *S3MergeStore) WriteProvenance(ctx context.Context, targetName, bundleID string, prov *Provenance) error {
+	jsonData, err := json.Marshal(prov)
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.provenanceKey(targetName, bundleID)),
+		Body:        bytes.NewReader(jsonData),
+		ContentType: aws.String("application/json"),
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// ReadProvenance reads a bundle's provenance from S3
+func (s *S3MergeStore) ReadProvenance(ctx context.Context, targetName, bundleID string) (*Provenance, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.Bucket),
-		Key:    aws.String(key),
+		Key:    aws.String(s.provenanceKey(targetName, bundleID)),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete object: %w", err)
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, errNoProvenance
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	var prov Provenance
+	if err := json.NewDecoder(output.Body).Decode(&prov); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provenance: %w", err)
 	}
+	return &prov, nil
+}
+
+// manifestKey returns the S3 key for a bundle's manifest, next to the bundle
+func (s *S3MergeStore) manifestKey(targetName, bundleID string) string {
+	return strings.TrimSuffix(s.bundleKey(targetName, bundleID), ".json") + manifestSuffix + ".json"
+}
 
+// WriteManifest writes a bundle's manifest to S3
+func (s *S3MergeStore) WriteManifest(ctx context.Context, targetName, bundleID string, manifest *BundleManifest) error {
+	jsonData, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.manifestKey(targetName, bundleID)),
+		Body:        bytes.NewReader(jsonData),
+		ContentType: aws.String("application/json"),
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
 	return nil
 }
 
+// ReadManifest reads a bundle's manifest from S3
+func (s *S3MergeStore) ReadManifest(ctx context.Context, targetName, bundleID string) (*BundleManifest, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.manifestKey(targetName, bundleID)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, errNoManifest
+		}
+		return nil, fmt.Errorf("failed to get object: %w", err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	var manifest BundleManifest
+	if err := json.NewDecoder(output.Body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
 // Version management methods (v1.2.0 - Requirement 24)
 
 // versionKeyPath returns the S3 key for a specific version of a secret
@@ -2,10 +2,21 @@ package pipeline
 
 import (
 	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestSecretVersion tests the SecretVersion structure (v1.2.0 - Requirement 24)
@@ -317,3 +328,132 @@ func TestVersionPathParsing(t *testing.T) {
 		})
 	}
 }
+
+// fakeS3 is a path-style S3 endpoint serving one bucket from memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// newFakeS3Store returns an S3 merge store backed by a fakeS3
+func newFakeS3Store(t *testing.T, retain int) (*S3MergeStore, *fakeS3) {
+	t.Helper()
+	f := &fakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(srv.URL),
+		UsePathStyle:               true,
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+	})
+	return &S3MergeStore{
+		Bucket:            "merge",
+		Prefix:            "secretsync",
+		VersioningEnabled: true,
+		RetainVersions:    retain,
+		client:            client,
+	}, f
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/merge"), "/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		prefix := r.URL.Query().Get("prefix")
+		var keys []string
+		for k := range f.objects {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString(`<ListBucketResult><Name>merge</Name><IsTruncated>false</IsTruncated>`)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "<Contents><Key>%s</Key></Contents>", k)
+		}
+		fmt.Fprintf(&b, "<KeyCount>%d</KeyCount></ListBucketResult>", len(keys))
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(b.String()))
+	case r.Method == http.MethodPut:
+		current, exists := f.objects[key]
+		ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if (ifNoneMatch == "*" && exists) || (ifMatch != "" && (!exists || ifMatch != etag(current))) {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`<Error><Code>PreconditionFailed</Code><Message>precondition failed</Message></Error>`))
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		w.Header().Set("ETag", etag(body))
+	case r.Method == http.MethodGet:
+		body, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+			return
+		}
+		w.Header().Set("ETag", etag(body))
+		_, _ = w.Write(body)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// etag returns an S3-style ETag of an object's content
+func etag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(body))
+}
+
+func TestS3MergeStore_BundleVersions(t *testing.T) {
+	ctx := context.Background()
+	store, f := newFakeS3Store(t, 2)
+
+	v1 := map[string]interface{}{"db": map[string]interface{}{"password": "one"}}
+	v2 := map[string]interface{}{"db": map[string]interface{}{"password": "two"}}
+	v3 := map[string]interface{}{"db": map[string]interface{}{"password": "three"}}
+
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", v1))
+	// An unchanged bundle does not add a version
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", v1))
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", v2))
+
+	versions, err := store.ListBundleVersions(ctx, "Stg")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, []int{2, 1}, []int{versions[0].Version, versions[1].Version})
+	assert.Equal(t, "b1", versions[0].BundleID)
+	hash, err := bundleHash(v2)
+	require.NoError(t, err)
+	assert.Equal(t, hash, versions[0].Hash)
+	assert.False(t, versions[0].Timestamp.IsZero())
+
+	got, err := store.GetBundleVersion(ctx, "Stg", 1)
+	require.NoError(t, err)
+	assert.Equal(t, v1, got.Data)
+
+	// Versions beyond retain_versions are deleted
+	require.NoError(t, store.WriteMergedBundle(ctx, "Stg", "b1", v3))
+	versions, err = store.ListBundleVersions(ctx, "Stg")
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2}, []int{versions[0].Version, versions[1].Version})
+	_, err = store.GetBundleVersion(ctx, "Stg", 1)
+	assert.Error(t, err)
+	assert.NotContains(t, f.objects, "secretsync/versions/Stg/bundle/v1.json")
+
+	// Other targets have their own history
+	versions, err = store.ListBundleVersions(ctx, "Prod")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
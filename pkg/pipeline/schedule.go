@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"sort"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduleGroup is a set of targets run together on one cron schedule
+type ScheduleGroup struct {
+	Schedule string
+	Targets  []string
+}
+
+// ParseSchedule parses a cron expression: five fields ("*/15 * * * *"), a
+// descriptor ("@hourly") or an interval ("@every 10m")
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+func validateSchedule(spec string) error {
+	if spec == "" {
+		return nil
+	}
+	_, err := ParseSchedule(spec)
+	return err
+}
+
+// ScheduleGroups groups the configured targets by their effective schedule:
+// the target's own schedule, else pipeline.schedule. Targets with neither
+// are returned in unscheduled. Groups and their targets are sorted.
+func (c *Config) ScheduleGroups() (groups []ScheduleGroup, unscheduled []string) {
+	bySchedule := make(map[string][]string)
+	for name, target := range c.Targets {
+		schedule := target.Schedule
+		if schedule == "" {
+			schedule = c.Pipeline.Schedule
+		}
+		if schedule == "" {
+			unscheduled = append(unscheduled, name)
+			continue
+		}
+		bySchedule[schedule] = append(bySchedule[schedule], name)
+	}
+
+	for schedule, targets := range bySchedule {
+		sort.Strings(targets)
+		groups = append(groups, ScheduleGroup{Schedule: schedule, Targets: targets})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Schedule < groups[j].Schedule })
+	sort.Strings(unscheduled)
+	return groups, unscheduled
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleGroups(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          Config
+		wantGroups      []ScheduleGroup
+		wantUnscheduled []string
+	}{
+		{
+			name: "no schedules",
+			config: Config{
+				Targets: map[string]Target{"Stg": {}, "Prod": {}},
+			},
+			wantUnscheduled: []string{"Prod", "Stg"},
+		},
+		{
+			name: "pipeline schedule applies to every target",
+			config: Config{
+				Pipeline: PipelineSettings{Schedule: "@hourly"},
+				Targets:  map[string]Target{"Stg": {}, "Prod": {}},
+			},
+			wantGroups: []ScheduleGroup{{Schedule: "@hourly", Targets: []string{"Prod", "Stg"}}},
+		},
+		{
+			name: "target schedules override the pipeline schedule",
+			config: Config{
+				Pipeline: PipelineSettings{Schedule: "@hourly"},
+				Targets: map[string]Target{
+					"Dev":  {},
+					"Stg":  {Schedule: "*/5 * * * *"},
+					"Prod": {Schedule: "*/5 * * * *"},
+				},
+			},
+			wantGroups: []ScheduleGroup{
+				{Schedule: "*/5 * * * *", Targets: []string{"Prod", "Stg"}},
+				{Schedule: "@hourly", Targets: []string{"Dev"}},
+			},
+		},
+		{
+			name: "targets without any schedule are left out",
+			config: Config{
+				Targets: map[string]Target{"Stg": {}, "Prod": {Schedule: "@daily"}},
+			},
+			wantGroups:      []ScheduleGroup{{Schedule: "@daily", Targets: []string{"Prod"}}},
+			wantUnscheduled: []string{"Stg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups, unscheduled := tt.config.ScheduleGroups()
+			assert.Equal(t, tt.wantGroups, groups)
+			assert.Equal(t, tt.wantUnscheduled, unscheduled)
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+// SecretSyncResult is the outcome of syncing one SecretSync resource
+type SecretSyncResult struct {
+	// Results has one result per spec.dest entry, in order
+	Results []Result
+	// Diff holds the changes computed for every destination
+	Diff *diff.PipelineDiff
+	// Hash is the SHA-256 of the transformed source secrets. It changes only
+	// when the synced content does.
+	Hash string
+	// Report summarizes the sync for notifications, without secret values
+	Report notify.Report
+}
+
+// Succeeded reports whether every destination synced
+func (r *SecretSyncResult) Succeeded() bool {
+	for _, res := range r.Results {
+		if !res.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// Synced returns the number of destinations that synced
+func (r *SecretSyncResult) Synced() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Success {
+			n++
+		}
+	}
+	return n
+}
+
+// SyncSecretSync runs the sync described by a SecretSync resource: it reads the
+// Vault source, applies the spec's filters and transforms, and writes the
+// result to every destination in spec.dest.
+//
+// A source path ending in "/" (or naming a whole mount) is a directory: every
+// secret below it is synced and destination paths are prefixes. Otherwise the
+// source is a single secret, or the directory of that name if one exists, and
+// a single secret is written to exactly the destination path.
+//
+// Secrets created at a destination are tagged as owned by the resource
+// ({namespace}/{name}); with spec.syncDelete, owned secrets below the
+// destination path that the source no longer produces are deleted. With
+// spec.dryRun the diff is computed and nothing is written.
+//
+// An error is returned when the spec is invalid or the source cannot be read;
+// destination failures are reported in the results.
+func SyncSecretSync(ctx context.Context, ss *v1alpha1.SecretSync) (*SecretSyncResult, error) {
+	if reqctx.FromContext(ctx) == nil {
+		ctx = reqctx.WithRequestContext(ctx, reqctx.NewRequestContext())
+	}
+	owner := ss.Namespace + "/" + ss.Name
+	spec := ss.Spec
+	dryRun := spec.DryRun != nil && *spec.DryRun
+	syncDelete := spec.SyncDelete != nil && *spec.SyncDelete
+
+	l := log.WithFields(log.Fields{
+		"action":     "SyncSecretSync",
+		"secretsync": owner,
+		"dryRun":     dryRun,
+		"request_id": reqctx.GetRequestID(ctx),
+	})
+
+	if spec.Source == nil || spec.Source.Path == "" {
+		return nil, errors.New("spec.source.path is required")
+	}
+	if len(spec.Dest) == 0 {
+		return nil, errors.New("spec.dest requires at least one destination")
+	}
+	filter, err := newSecretFilter(spec.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := newSecretTransform(spec.Transforms); err != nil {
+		return nil, err
+	}
+
+	source := spec.Source.DeepCopy()
+	if err := source.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init source vault client: %w", err)
+	}
+
+	p := &Pipeline{config: &Config{}}
+	p.initDiff(dryRun, "")
+
+	sourcePath, paths, single := secretSyncSource(source.Path)
+	secrets, err := p.readVaultSource(ctx, source, sourcePath, paths, filter, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+	secrets, err = applyTransforms(spec.Transforms, secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply transforms: %w", err)
+	}
+	// A single-secret source syncs to the exact destination name
+	if _, ok := secrets[single]; !ok || len(secrets) != 1 {
+		single = ""
+	}
+
+	hashData, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash secrets: %w", err)
+	}
+	sum := sha256.Sum256(hashData)
+
+	l.WithFields(log.Fields{
+		"secrets":      len(secrets),
+		"filtered":     filter.filtered,
+		"destinations": len(spec.Dest),
+	}).Info("Syncing SecretSync")
+
+	result := &SecretSyncResult{Hash: hex.EncodeToString(sum[:])}
+	for i, store := range spec.Dest {
+		res := p.syncStore(ctx, fmt.Sprintf("%s[%d]", owner, i), owner, store, secrets, single, dryRun, syncDelete)
+		res.Details.SecretsFiltered = filter.filtered
+		result.Results = append(result.Results, res)
+	}
+
+	result.Diff = p.pipelineDiff
+	result.Report = newNotificationReport(reqctx.GetRequestID(ctx), "secretsync", dryRun, result.Results, p.pipelineDiff.Summary, nil)
+	return result, nil
+}
+
+// secretSyncSource splits a SecretSync source path into the arguments of
+// readVaultSource. single is the key a single secret is read under, or empty
+// for a directory source.
+func secretSyncSource(sourcePath string) (root string, paths []string, single string) {
+	trimmed := strings.Trim(sourcePath, "/")
+	if strings.HasSuffix(sourcePath, "/") || !strings.Contains(trimmed, "/") {
+		return trimmed + "/", nil, ""
+	}
+	return path.Dir(trimmed) + "/", []string{path.Base(trimmed)}, path.Base(trimmed)
+}
+
+// syncStore syncs secrets to one spec.dest entry
+func (p *Pipeline) syncStore(ctx context.Context, targetName, owner string, store *v1alpha1.StoreConfig, secrets map[string]map[string]interface{}, single string, dryRun, syncDelete bool) Result {
+	start := time.Now()
+	l := log.WithFields(log.Fields{
+		"action":     "syncStore",
+		"target":     targetName,
+		"request_id": reqctx.GetRequestID(ctx),
+	})
+	fail := func(err error) Result {
+		return Result{
+			Target:    targetName,
+			Phase:     "sync",
+			Operation: string(OperationSync),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+		}
+	}
+
+	dest, root, prefix, err := newStoreDestination(ctx, p, owner, store)
+	if err != nil {
+		return fail(err)
+	}
+
+	names := make(map[string]string, len(secrets))
+	for secretPath := range secrets {
+		switch {
+		case single != "" && prefix != "":
+			names[secretPath] = prefix
+		case prefix != "":
+			names[secretPath] = prefix + "/" + secretPath
+		default:
+			names[secretPath] = secretPath
+		}
+	}
+
+	entries, err := destinationEntries(dest, secrets, names)
+	if err != nil {
+		return fail(fmt.Errorf("failed to build destination entries: %w", err))
+	}
+
+	// Only owned entries below the destination path are candidates, so several
+	// destinations on one account or mount do not delete each other's secrets
+	var orphans []string
+	if syncDelete {
+		managed, err := p.findOrphans(ctx, dest, entries)
+		if err != nil {
+			return fail(fmt.Errorf("failed to list orphaned secrets: %w", err))
+		}
+		for _, name := range managed {
+			if root == "" || name == root || strings.HasPrefix(name, root+"/") {
+				orphans = append(orphans, name)
+			}
+		}
+	}
+
+	targetDiff := p.computeSyncDiff(ctx, targetName, dest, entries, orphans)
+	p.addTargetDiff(*targetDiff)
+
+	details := ResultDetails{
+		SecretsProcessed: len(entries),
+		SecretsRemoved:   len(orphans),
+		DestinationPath:  dest.Location(),
+	}
+	if dryRun {
+		l.WithFields(log.Fields{
+			"secretsCount": len(entries),
+			"orphans":      len(orphans),
+			"destination":  dest.Location(),
+		}).Info("[DRY-RUN] Would sync secrets")
+		return Result{
+			Target:    targetName,
+			Phase:     "sync",
+			Operation: string(OperationSync),
+			Success:   true,
+			Duration:  time.Since(start),
+			Details:   details,
+			Diff:      targetDiff,
+		}
+	}
+
+	written, removed, failed := writeEntries(ctx, l, dest, entries, orphans)
+	details.SecretsProcessed, details.SecretsRemoved = written, removed
+
+	var syncErr error
+	if len(failed) > 0 {
+		syncErr = fmt.Errorf("failed to sync %d secrets: %v", len(failed), failed)
+	}
+	return Result{
+		Target:    targetName,
+		Phase:     "sync",
+		Operation: string(OperationSync),
+		Success:   syncErr == nil,
+		Error:     syncErr,
+		Duration:  time.Since(start),
+		Details:   details,
+		Diff:      targetDiff,
+	}
+}
+
+// newStoreDestination creates the destination for a spec.dest entry. root is
+// the entry name of the destination path and prefix the name secrets are
+// written below.
+func newStoreDestination(ctx context.Context, p *Pipeline, owner string, store *v1alpha1.StoreConfig) (dest Destination, root, prefix string, err error) {
+	switch {
+	case store == nil:
+		return nil, "", "", errors.New("destination is empty")
+
+	case store.AWS != nil:
+		client := store.AWS.DeepCopy()
+		tags := make(map[string]string, len(client.Tags)+2)
+		for k, v := range client.Tags {
+			tags[k] = v
+		}
+		for k, v := range ownershipTags(owner) {
+			tags[k] = v
+		}
+		client.Tags = tags
+		if err := client.Init(ctx); err != nil {
+			return nil, "", "", fmt.Errorf("failed to init aws client: %w", err)
+		}
+		name := strings.TrimSuffix(client.Name, "/")
+		return &secretsManagerDestination{
+			pipeline:   p,
+			client:     client,
+			targetName: owner,
+			accountID:  roleAccountID(client.RoleArn),
+		}, name, name, nil
+
+	case store.Vault != nil:
+		client := store.Vault.DeepCopy()
+		destPath := strings.Trim(client.Path, "/")
+		mount, prefix, _ := strings.Cut(destPath, "/")
+		if mount == "" {
+			return nil, "", "", errors.New("vault destination path is required")
+		}
+		if err := client.Init(ctx); err != nil {
+			return nil, "", "", fmt.Errorf("failed to init destination vault client: %w", err)
+		}
+		return &vaultDestination{
+			mount:      mount,
+			address:    client.Address,
+			targetName: owner,
+			client:     client,
+		}, destPath, prefix, nil
+
+	case store.IdentityCenter != nil:
+		return nil, "", "", errors.New("awsIdentityCenter is not supported as a SecretSync destination")
+	}
+	return nil, "", "", errors.New("destination has no store configured")
+}
+
+// roleAccountID returns the account ID of an IAM role ARN, or empty
+func roleAccountID(roleARN string) string {
+	parts := strings.Split(roleARN, ":")
+	if len(parts) < 5 {
+		return ""
+	}
+	return parts[4]
+}
@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecretSyncSource(t *testing.T) {
+	tests := []struct {
+		path   string
+		root   string
+		paths  []string
+		single string
+	}{
+		{path: "apps/team/", root: "apps/team/"},
+		{path: "apps", root: "apps/"},
+		{path: "apps/team/db", root: "apps/team/", paths: []string{"db"}, single: "db"},
+		{path: "/apps/db", root: "apps/", paths: []string{"db"}, single: "db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			root, paths, single := secretSyncSource(tt.path)
+			assert.Equal(t, tt.root, root)
+			assert.Equal(t, tt.paths, paths)
+			assert.Equal(t, tt.single, single)
+		})
+	}
+}
+
+func TestSyncSecretSync(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VAULT_TOKEN", "root")
+
+	src, srcSrv := newFakeKV(t, "apps")
+	src.put("team/db", map[string]interface{}{"user": "app", "password": "hunter2"})
+	src.put("team/api", map[string]interface{}{"token": "t"})
+	src.put("team/debug", map[string]interface{}{"token": "d"})
+	dst, dstSrv := newFakeKV(t, "replica")
+	dst.put("copy/unmanaged", map[string]interface{}{"keep": "me"})
+
+	syncDelete, dryRun := true, true
+	ss := &v1alpha1.SecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "replicate"},
+		Spec: v1alpha1.SecretSyncSpec{
+			Source:     &vault.VaultClient{Address: srcSrv.URL, Path: "apps/team/"},
+			Dest:       []*v1alpha1.StoreConfig{{Vault: &vault.VaultClient{Address: dstSrv.URL, Path: "replica/copy"}}},
+			SyncDelete: &syncDelete,
+			DryRun:     &dryRun,
+			Filters:    &v1alpha1.FilterConfig{Regex: &v1alpha1.RegexpFilterConfig{Exclude: []string{"debug"}}},
+			Transforms: &v1alpha1.TransformSpec{Exclude: []string{"^password$"}},
+		},
+	}
+
+	// Dry run computes the diff without writing
+	result, err := SyncSecretSync(ctx, ss)
+	require.NoError(t, err)
+	require.Len(t, result.Results, 1)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 2, result.Diff.Summary.Added)
+	assert.Equal(t, 1, result.Results[0].Details.SecretsFiltered)
+	assert.NotContains(t, dst.data, "copy/db")
+
+	dryRun = false
+	result, err = SyncSecretSync(ctx, ss)
+	require.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.Equal(t, 1, result.Synced())
+	assert.Equal(t, map[string]interface{}{"user": "app"}, dst.data["copy/db"])
+	assert.Equal(t, map[string]interface{}{"token": "t"}, dst.data["copy/api"])
+	assert.Equal(t, ownershipTags("team/replicate"), dst.custom["copy/db"])
+	assert.Equal(t, []v1alpha1.NotificationEvent{"success", "changes"}, result.Report.Events)
+	hash := result.Hash
+
+	// Unchanged source: same hash and no changes
+	result, err = SyncSecretSync(ctx, ss)
+	require.NoError(t, err)
+	assert.Equal(t, hash, result.Hash)
+	assert.Equal(t, diff.ChangeSummary{Unchanged: 2, Total: 2}, result.Diff.Summary)
+
+	// A secret removed at the source is deleted; unowned secrets are kept
+	delete(src.data, "team/api")
+	delete(src.versions, "team/api")
+	result, err = SyncSecretSync(ctx, ss)
+	require.NoError(t, err)
+	assert.True(t, result.Succeeded())
+	assert.NotEqual(t, hash, result.Hash)
+	assert.Equal(t, 1, result.Results[0].Details.SecretsRemoved)
+	assert.NotContains(t, dst.data, "copy/api")
+	assert.Contains(t, dst.data, "copy/unmanaged")
+}
+
+func TestSyncSecretSync_SingleSecret(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VAULT_TOKEN", "root")
+
+	src, srcSrv := newFakeKV(t, "apps")
+	src.put("team/db", map[string]interface{}{"user": "app"})
+	dst, dstSrv := newFakeKV(t, "replica")
+
+	ss := &v1alpha1.SecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "db"},
+		Spec: v1alpha1.SecretSyncSpec{
+			Source: &vault.VaultClient{Address: srcSrv.URL, Path: "apps/team/db"},
+			Dest: []*v1alpha1.StoreConfig{
+				{Vault: &vault.VaultClient{Address: dstSrv.URL, Path: "replica/prod/database"}},
+				{IdentityCenter: nil},
+			},
+		},
+	}
+
+	result, err := SyncSecretSync(ctx, ss)
+	require.NoError(t, err)
+	require.Len(t, result.Results, 2)
+	assert.True(t, result.Results[0].Success)
+	assert.Equal(t, map[string]interface{}{"user": "app"}, dst.data["prod/database"])
+
+	assert.False(t, result.Results[1].Success)
+	assert.ErrorContains(t, result.Results[1].Error, "no store configured")
+	assert.False(t, result.Succeeded())
+	assert.Equal(t, []v1alpha1.NotificationEvent{"failure", "changes"}, result.Report.Events)
+}
+
+func TestSyncSecretSync_InvalidSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   v1alpha1.SecretSyncSpec
+		errMsg string
+	}{
+		{
+			name:   "no source",
+			spec:   v1alpha1.SecretSyncSpec{Dest: []*v1alpha1.StoreConfig{{}}},
+			errMsg: "spec.source.path is required",
+		},
+		{
+			name:   "no destinations",
+			spec:   v1alpha1.SecretSyncSpec{Source: &vault.VaultClient{Path: "apps/"}},
+			errMsg: "spec.dest requires at least one destination",
+		},
+		{
+			name: "invalid filter",
+			spec: v1alpha1.SecretSyncSpec{
+				Source:  &vault.VaultClient{Path: "apps/"},
+				Dest:    []*v1alpha1.StoreConfig{{}},
+				Filters: &v1alpha1.FilterConfig{Regex: &v1alpha1.RegexpFilterConfig{Include: []string{"("}}},
+			},
+			errMsg: "filters.regex.include[0]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SyncSecretSync(context.Background(), &v1alpha1.SecretSync{Spec: tt.spec})
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
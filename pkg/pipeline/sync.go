@@ -2,25 +2,27 @@ package pipeline
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/extended-data-library/secretssync/pkg/client/aws"
-	"github.com/extended-data-library/secretssync/pkg/client/vault"
 	reqctx "github.com/extended-data-library/secretssync/pkg/context"
+	"github.com/extended-data-library/secretssync/pkg/diff"
 	log "github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // syncTarget executes sync operations for a single target.
 //
-// Sync reads from the merge store bundle (created by merge phase) and writes to AWS.
+// Sync reads from the merge store bundle (created by merge phase) and writes to the
+// target's destination (Secrets Manager by default).
 // The bundle path is deterministic based on the source sequence used during merge,
-// so sync always knows where to find the merged secrets.
+// so sync always knows where to find the merged secrets. With
+// pipeline.sync.freshness set, the bundle's manifest must meet its
+// requirements before anything is written.
 //
-// Flow: MergeStore[bundle_path] → AWS[target_account]
-func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun bool) Result {
+// Flow: MergeStore[bundle_path] → Destination[target_account]
+func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun, allowLargeChanges bool) Result {
 	start := time.Now()
 	requestID := reqctx.GetRequestID(ctx)
 	l := log.WithFields(log.Fields{
@@ -59,7 +61,7 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 	}).Info("Starting sync from merge store bundle")
 
 	// Read all secrets from the bundle
-	secretsData, err := p.readBundleSecrets(ctx, targetName, bundlePath)
+	secretsData, err := p.readBundleSecrets(ctx, targetName)
 	if err != nil {
 		return Result{
 			Target:   targetName,
@@ -72,79 +74,87 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 
 	l.WithField("secretsCount", len(secretsData)).Debug("Retrieved secrets from bundle")
 
-	if dryRun {
-		l.WithField("secretsCount", len(secretsData)).Info("[DRY-RUN] Would sync secrets to AWS")
+	if err := p.checkFreshness(ctx, targetName, secretsData); err != nil {
+		l.WithError(err).Error("Bundle failed freshness check, nothing synced")
 		return Result{
-			Target:    targetName,
-			Phase:     "sync",
-			Operation: string(OperationSync),
-			Success:   true,
-			Duration:  time.Since(start),
-			Details: ResultDetails{
-				SecretsProcessed: len(secretsData),
-				SourcePaths:      []string{bundlePath},
-				DestinationPath:  fmt.Sprintf("aws://%s", target.AccountID),
-			},
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(start),
 		}
 	}
 
-	// Get role ARN and region for this target
-	roleARN := p.getRoleARNForTarget(target)
-	region := target.Region
-	if region == "" {
-		region = p.config.AWS.Region
-	}
+	return p.syncBundle(ctx, l, start, targetName, target, bundlePath, secretsData, dryRun, allowLargeChanges)
+}
 
-	// Initialize AWS client for target account
-	awsClient, err := p.getAWSClientForTarget(ctx, target)
+// syncBundle filters, transforms and writes a bundle read from bundlePath to
+// the target's destination. Nothing is written when the sync trips the
+// target's guardrails, unless allowLargeChanges is set.
+func (p *Pipeline) syncBundle(ctx context.Context, l *log.Entry, start time.Time, targetName string, target Target, bundlePath string, secretsData map[string]map[string]interface{}, dryRun, allowLargeChanges bool) Result {
+	prepared, err := p.prepareSync(ctx, l, targetName, target, secretsData)
 	if err != nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "sync",
 			Success:  false,
-			Error:    fmt.Errorf("failed to get AWS client for target: %w", err),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
+	dest, entries, orphans, filter := prepared.dest, prepared.entries, prepared.orphans, prepared.filter
+	roleARN := p.getRoleARNForTarget(target)
 
-	// Sync each secret to AWS
-	var syncErrors []string
-	successCount := 0
-
-	for secretPath, data := range secretsData {
-		// Determine AWS secret name
-		awsSecretName := p.getAWSSecretName(targetName, secretPath)
+	// Compute diff against the current destination state before writing anything
+	var targetDiff *diff.TargetDiff
+	if p.pipelineDiff != nil {
+		targetDiff = p.computeSyncDiff(ctx, targetName, dest, entries, orphans)
+		p.addTargetDiff(*targetDiff)
+	}
 
-		// Convert data to JSON bytes for AWS
-		secretBytes, err := json.Marshal(data)
+	if guardrails := p.guardrailsFor(target); guardrails.enabled() && !allowLargeChanges {
+		current, err := dest.Read(ctx, entryNames(entries, orphans))
 		if err != nil {
-			l.WithError(err).WithField("secret", secretPath).Error("Failed to marshal secret data")
-			syncErrors = append(syncErrors, secretPath)
-			continue
-		}
-
-		// Create metadata for the write operation
-		meta := metav1.ObjectMeta{
-			Name:      awsSecretName,
-			Namespace: targetName,
+			return Result{
+				Target:   targetName,
+				Phase:    "sync",
+				Success:  false,
+				Error:    fmt.Errorf("failed to read destination state: %w", err),
+				Duration: time.Since(start),
+			}
 		}
-
-		if _, err := awsClient.WriteSecret(ctx, meta, awsSecretName, secretBytes); err != nil {
-			l.WithError(err).WithFields(log.Fields{
-				"secret":    secretPath,
-				"awsSecret": awsSecretName,
-			}).Error("Failed to write secret to AWS")
-			syncErrors = append(syncErrors, secretPath)
-			continue
+		if guardErr := guardrails.check(targetName, "sync", current, entries); guardErr != nil {
+			l.WithError(guardErr).Error("Guardrail tripped, nothing synced")
+			return guardrailResult(guardErr, start, targetDiff)
 		}
+	}
 
+	if dryRun {
 		l.WithFields(log.Fields{
-			"secret":    secretPath,
-			"awsSecret": awsSecretName,
-		}).Debug("Secret synced to AWS")
-		successCount++
+			"secretsCount": len(entries),
+			"orphans":      len(orphans),
+			"destination":  dest.Location(),
+		}).Info("[DRY-RUN] Would sync secrets")
+		return Result{
+			Target:    targetName,
+			Phase:     "sync",
+			Operation: string(OperationSync),
+			Success:   true,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SecretsProcessed: len(entries),
+				SecretsRemoved:   len(orphans),
+				SecretsFiltered:  filter.filtered,
+				SourcePaths:      []string{bundlePath},
+				DestinationPath:  dest.Location(),
+				RoleARN:          roleARN,
+			},
+			Diff: targetDiff,
+		}
 	}
 
+	successCount, removedCount, syncErrors := writeEntries(ctx, l, dest, entries, orphans)
+
 	success := len(syncErrors) == 0
 	var lastErr error
 	if !success {
@@ -155,10 +165,11 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		"duration": time.Since(start),
 		"success":  success,
 		"synced":   successCount,
+		"removed":  removedCount,
 		"failed":   len(syncErrors),
 	}).Info("Sync completed")
 
-	result := Result{
+	return Result{
 		Target:    targetName,
 		Phase:     "sync",
 		Operation: string(OperationSync),
@@ -167,86 +178,115 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		Duration:  time.Since(start),
 		Details: ResultDetails{
 			SecretsProcessed: successCount,
+			SecretsRemoved:   removedCount,
+			SecretsFiltered:  filter.filtered,
 			SourcePaths:      []string{bundlePath},
-			DestinationPath:  fmt.Sprintf("aws://%s", target.AccountID),
+			DestinationPath:  dest.Location(),
 			RoleARN:          roleARN,
 		},
+		Diff: targetDiff,
 	}
+}
 
-	// Compute diff if tracking is enabled
-	if p.pipelineDiff != nil {
-		targetDiff, err := p.computeSyncDiff(ctx, targetName, roleARN, region)
-		if err != nil {
-			l.WithError(err).Debug("Failed to compute sync diff")
-		} else {
-			result.Diff = targetDiff
-			p.addTargetDiff(*targetDiff)
-		}
+// preparedSync is a bundle resolved into the entries of a target's destination
+type preparedSync struct {
+	dest    Destination
+	entries map[string]interface{}
+	// orphans are managed entries no longer in the bundle (pipeline.sync.delete_orphans)
+	orphans []string
+	filter  *secretFilter
+}
+
+// prepareSync filters and transforms a bundle and resolves it into destination
+// entries and orphans, without writing anything
+func (p *Pipeline) prepareSync(ctx context.Context, l *log.Entry, targetName string, target Target, secretsData map[string]map[string]interface{}) (*preparedSync, error) {
+	// Target filters are validated with the config, so compiling cannot fail here
+	filter, _ := newSecretFilter(target.Filters)
+	secretsData = filter.apply(secretsData)
+	if filter.filtered > 0 {
+		l.WithField("filtered", filter.filtered).Debug("Filtered bundle secrets")
 	}
 
-	return result
-}
+	// Transform between merge and sync, so names, diff and writes see the output
+	secretsData, err := applyTransforms(target.Transforms, secretsData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply transforms: %w", err)
+	}
 
-// readBundleSecrets reads all secrets from the merge store bundle
-func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName, bundlePath string) (map[string]map[string]interface{}, error) {
-	secretsData := make(map[string]map[string]interface{})
+	// Resolve destination names once so writes, diff and orphan detection agree
+	secretNames, err := p.secretNames(targetName, secretsData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret names: %w", err)
+	}
 
-	if p.config.MergeStore.Vault != nil {
-		mergeClient := &vault.VaultClient{
-			Address:   p.config.Vault.Address,
-			Namespace: p.config.Vault.Namespace,
-		}
-		if err := mergeClient.Init(ctx); err != nil {
-			return nil, fmt.Errorf("failed to init merge vault client: %w", err)
-		}
+	// Initialize the destination for the target (used for the diff and the writes)
+	dest, err := p.newDestination(ctx, targetName, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize destination for target: %w", err)
+	}
 
-		secrets, err := mergeClient.ListSecrets(ctx, bundlePath)
+	entries, err := destinationEntries(dest, secretsData, secretNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build destination entries: %w", err)
+	}
+
+	// Find entries this tool created for the target that are no longer in the bundle
+	var orphans []string
+	if p.config.Pipeline.Sync.DeleteOrphans {
+		orphans, err = p.findOrphans(ctx, dest, entries)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list secrets from bundle: %w", err)
+			return nil, fmt.Errorf("failed to list orphaned secrets: %w", err)
 		}
+	}
 
-		for _, secretPath := range secrets {
-			data, err := mergeClient.GetKVSecretOnce(ctx, secretPath)
-			if err != nil {
-				log.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret from bundle")
-				continue
-			}
-			// Use relative path within bundle
-			relPath := secretPath
-			if len(secretPath) > len(bundlePath) {
-				relPath = secretPath[len(bundlePath):]
-				if len(relPath) > 0 && relPath[0] == '/' {
-					relPath = relPath[1:]
-				}
-			}
-			secretsData[relPath] = data
-		}
-	} else if p.s3Store != nil {
-		target, ok := p.config.Targets[targetName]
-		if !ok {
-			return nil, fmt.Errorf("target not found: %s", targetName)
-		}
+	return &preparedSync{dest: dest, entries: entries, orphans: orphans, filter: filter}, nil
+}
 
-		var sourcePaths []string
-		for _, importName := range target.Imports {
-			sourcePath := p.config.GetSourcePath(importName)
-			sourcePaths = append(sourcePaths, sourcePath)
+// writeEntries writes entries in name order, then deletes the orphans.
+// It returns the number written and removed and the names that failed.
+func writeEntries(ctx context.Context, l *log.Entry, dest Destination, entries map[string]interface{}, orphans []string) (written, removed int, failed []string) {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := dest.Write(ctx, name, entries[name]); err != nil {
+			l.WithError(err).WithField("secret", name).Error("Failed to write secret")
+			failed = append(failed, name)
+			continue
 		}
-		bundleID := BundleID(sourcePaths)
+		l.WithField("secret", name).Debug("Secret synced")
+		written++
+	}
 
-		data, err := p.s3Store.ReadMergedBundle(ctx, targetName, bundleID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read bundle from S3: %w", err)
+	// Delete orphans (Secrets Manager schedules them with the configured recovery window)
+	for _, name := range orphans {
+		if err := dest.Delete(ctx, name); err != nil {
+			l.WithError(err).WithField("secret", name).Error("Failed to delete orphaned secret")
+			failed = append(failed, name)
+			continue
 		}
-		secretsData = data
+		l.WithField("secret", name).Info("Deleted orphaned secret")
+		removed++
 	}
+	return written, removed, failed
+}
 
-	return secretsData, nil
+// readBundleSecrets reads all secrets from the target's merge store bundle
+func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName string) (map[string]map[string]interface{}, error) {
+	if p.mergeStore == nil {
+		return nil, p.errNoMergeStore()
+	}
+	bundleID := BundleID(p.config.GetTargetSourcePaths(targetName))
+	return p.mergeStore.ReadMergedBundle(ctx, targetName, bundleID)
 }
 
 // getAWSClientForTarget returns an AWS client configured for the target account.
 // It handles cross-account role assumption via Control Tower or custom patterns.
-func (p *Pipeline) getAWSClientForTarget(ctx context.Context, target Target) (*aws.AwsClient, error) {
+// Secrets created through the client carry the target's ownership tags.
+func (p *Pipeline) getAWSClientForTarget(ctx context.Context, targetName string, target Target) (*aws.AwsClient, error) {
 	region := target.Region
 	if region == "" {
 		region = p.config.AWS.Region
@@ -254,6 +294,7 @@ func (p *Pipeline) getAWSClientForTarget(ctx context.Context, target Target) (*a
 
 	client := &aws.AwsClient{
 		Region: region,
+		Tags:   ownershipTags(targetName),
 	}
 
 	// If we have an AWS execution context with role assumption
@@ -271,13 +312,19 @@ func (p *Pipeline) getAWSClientForTarget(ctx context.Context, target Target) (*a
 
 // getRoleARNForTarget returns the role ARN for assuming into the target account
 func (p *Pipeline) getRoleARNForTarget(target Target) string {
-	if target.AccountID == "" {
+	return p.getRoleARNForAccount(target.AccountID)
+}
+
+// getRoleARNForAccount returns the role ARN for assuming into an account.
+// Returns empty when no account is given (use the current credentials).
+func (p *Pipeline) getRoleARNForAccount(accountID string) string {
+	if accountID == "" {
 		return ""
 	}
 
 	// Use custom role pattern if provided
 	if p.awsCtx != nil && p.config.AWS.ExecutionContext.CustomRolePattern != "" {
-		return fmt.Sprintf(p.config.AWS.ExecutionContext.CustomRolePattern, target.AccountID)
+		return fmt.Sprintf(p.config.AWS.ExecutionContext.CustomRolePattern, accountID)
 	}
 
 	// Use Control Tower execution role if enabled
@@ -286,15 +333,8 @@ func (p *Pipeline) getRoleARNForTarget(target Target) string {
 		if roleName == "" {
 			roleName = "AWSControlTowerExecution"
 		}
-		return fmt.Sprintf("arn:aws:iam::%s:role/%s", target.AccountID, roleName)
+		return fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName)
 	}
 
 	return ""
 }
-
-// getAWSSecretName determines the AWS Secrets Manager secret name for a given path.
-func (p *Pipeline) getAWSSecretName(targetName, secretPath string) string {
-	// Default: use the secret path as-is
-	// Could be customized via target config or naming patterns
-	return secretPath
-}
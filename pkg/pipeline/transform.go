@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+)
+
+// transformFuncs are available in transform templates, in addition to the
+// secret name helpers
+var transformFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// secretTransform is a compiled TransformSpec. It is applied to every secret of a
+// bundle between merge and sync, in order: include, exclude, rename, template.
+type secretTransform struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	rename  []v1alpha1.RenameTransform
+	tmpl    *template.Template
+}
+
+// newSecretTransform compiles a TransformSpec. Include and exclude entries are
+// regular expressions matched against key names.
+func newSecretTransform(spec *v1alpha1.TransformSpec) (*secretTransform, error) {
+	t := &secretTransform{}
+	if spec == nil {
+		return t, nil
+	}
+
+	compile := func(field string, patterns []string) ([]*regexp.Regexp, error) {
+		res := make([]*regexp.Regexp, 0, len(patterns))
+		for i, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("transforms.%s[%d] is invalid regex: %w", field, i, err)
+			}
+			res = append(res, re)
+		}
+		return res, nil
+	}
+
+	var err error
+	if t.include, err = compile("include", spec.Include); err != nil {
+		return nil, err
+	}
+	if t.exclude, err = compile("exclude", spec.Exclude); err != nil {
+		return nil, err
+	}
+
+	for i, r := range spec.Rename {
+		if r.From == "" || r.To == "" {
+			return nil, fmt.Errorf("transforms.rename[%d] requires from and to", i)
+		}
+	}
+	t.rename = spec.Rename
+
+	if spec.Template != nil && *spec.Template != "" {
+		funcs := template.FuncMap{}
+		for name, fn := range secretNameFuncs {
+			funcs[name] = fn
+		}
+		for name, fn := range transformFuncs {
+			funcs[name] = fn
+		}
+		t.tmpl, err = template.New("transform").Funcs(funcs).Option("missingkey=error").Parse(*spec.Template)
+		if err != nil {
+			return nil, fmt.Errorf("transforms.template is invalid: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// matchAny reports whether any pattern matches the key
+func matchAny(patterns []*regexp.Regexp, key string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply transforms one secret's data. The template, if any, is executed with the
+// filtered and renamed data as dot and must render a JSON object; secrets with no
+// keys left are not rendered.
+func (t *secretTransform) apply(data map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if len(t.include) > 0 && !matchAny(t.include, key) {
+			continue
+		}
+		if matchAny(t.exclude, key) {
+			continue
+		}
+		out[key] = value
+	}
+
+	for _, r := range t.rename {
+		if value, ok := out[r.From]; ok {
+			delete(out, r.From)
+			out[r.To] = value
+		}
+	}
+
+	if t.tmpl == nil || len(out) == 0 {
+		return out, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	var rendered map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rendered); err != nil {
+		return nil, fmt.Errorf("template output is not a JSON object: %w", err)
+	}
+	return rendered, nil
+}
+
+// applyTransforms transforms every secret of a bundle. Secrets left without keys
+// are dropped, so they are not synced.
+func applyTransforms(spec *v1alpha1.TransformSpec, secrets map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	if spec == nil {
+		return secrets, nil
+	}
+	t, err := newSecretTransform(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]map[string]interface{}, len(secrets))
+	for path, data := range secrets {
+		transformed, err := t.apply(data)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", path, err)
+		}
+		if len(transformed) > 0 {
+			result[path] = transformed
+		}
+	}
+	return result, nil
+}
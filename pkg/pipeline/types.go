@@ -1,6 +1,12 @@
 // Package pipeline provides unified configuration and orchestration for secrets syncing pipelines.
 package pipeline
 
+import (
+	"time"
+
+	"github.com/extended-data-library/secretssync/api/v1alpha1"
+)
+
 // Config represents the unified pipeline configuration
 type Config struct {
 	Log            LogConfig                `mapstructure:"log" yaml:"log"`
@@ -11,6 +17,11 @@ type Config struct {
 	Targets        map[string]Target        `mapstructure:"targets" yaml:"targets"`
 	DynamicTargets map[string]DynamicTarget `mapstructure:"dynamic_targets" yaml:"dynamic_targets"`
 	Pipeline       PipelineSettings         `mapstructure:"pipeline" yaml:"pipeline"`
+
+	// Notifications are sent after each run; NotificationsTemplate is the
+	// message template shared by sinks without their own body
+	Notifications         []*v1alpha1.NotificationSpec `mapstructure:"notifications" yaml:"notifications,omitempty"`
+	NotificationsTemplate string                       `mapstructure:"notifications_template" yaml:"notifications_template,omitempty"`
 }
 
 // LogConfig controls logging behavior
@@ -37,6 +48,8 @@ type VaultAuthConfig struct {
 	AppRole    *AppRoleAuth    `mapstructure:"approle" yaml:"approle"`
 	Token      *TokenAuth      `mapstructure:"token" yaml:"token"`
 	Kubernetes *KubernetesAuth `mapstructure:"kubernetes" yaml:"kubernetes"`
+	JWT        *JWTAuth        `mapstructure:"jwt" yaml:"jwt,omitempty"`
+	AWS        *AWSIAMAuth     `mapstructure:"aws" yaml:"aws,omitempty"`
 }
 
 // AppRoleAuth configures AppRole authentication
@@ -57,6 +70,25 @@ type KubernetesAuth struct {
 	MountPath string `mapstructure:"mount_path" yaml:"mount_path"`
 }
 
+// JWTAuth configures JWT/OIDC authentication (e.g. GitHub Actions OIDC tokens)
+type JWTAuth struct {
+	Role      string `mapstructure:"role" yaml:"role"`
+	MountPath string `mapstructure:"mount_path" yaml:"mount_path"`
+	// TokenFile is read first; TokenEnv names an environment variable holding the JWT
+	TokenFile string `mapstructure:"token_file" yaml:"token_file,omitempty"`
+	TokenEnv  string `mapstructure:"token_env" yaml:"token_env,omitempty"`
+}
+
+// AWSIAMAuth configures Vault's AWS IAM authentication using the ambient AWS credentials
+type AWSIAMAuth struct {
+	Role      string `mapstructure:"role" yaml:"role"`
+	MountPath string `mapstructure:"mount_path" yaml:"mount_path"`
+	// Region of the STS endpoint to sign for (default: global endpoint)
+	Region string `mapstructure:"region" yaml:"region,omitempty"`
+	// ServerID is sent as X-Vault-AWS-IAM-Server-ID when the Vault role requires it
+	ServerID string `mapstructure:"server_id" yaml:"server_id,omitempty"`
+}
+
 // AWSConfig configures AWS with Control Tower / Organizations awareness
 type AWSConfig struct {
 	Region           string                 `mapstructure:"region" yaml:"region"`
@@ -137,14 +169,20 @@ type IdentityCenterConfig struct {
 type Source struct {
 	Vault *VaultSource `mapstructure:"vault" yaml:"vault"`
 	AWS   *AWSSource   `mapstructure:"aws" yaml:"aws"`
+
+	// Filters selects which secrets are read from the source during merge
+	Filters *v1alpha1.FilterConfig `mapstructure:"filters" yaml:"filters,omitempty"`
 }
 
-// VaultSource imports secrets from a Vault KV2 mount
+// VaultSource imports secrets from a Vault KV2 mount.
+// Address, Namespace, Auth and the traversal limits default to the top-level vault
+// settings. When Paths is set, only those sub-paths of the mount are read.
 type VaultSource struct {
-	Address   string   `mapstructure:"address" yaml:"address"`
-	Namespace string   `mapstructure:"namespace" yaml:"namespace"`
-	Mount     string   `mapstructure:"mount" yaml:"mount"`
-	Paths     []string `mapstructure:"paths" yaml:"paths"`
+	Address   string           `mapstructure:"address" yaml:"address"`
+	Namespace string           `mapstructure:"namespace" yaml:"namespace"`
+	Mount     string           `mapstructure:"mount" yaml:"mount"`
+	Paths     []string         `mapstructure:"paths" yaml:"paths"`
+	Auth      *VaultAuthConfig `mapstructure:"auth" yaml:"auth,omitempty"`
 
 	// Traversal configuration for recursive secret listing
 	// These settings control memory usage and performance during large Vault traversals
@@ -165,6 +203,7 @@ type AWSSource struct {
 type MergeStoreConfig struct {
 	Vault *MergeStoreVault `mapstructure:"vault" yaml:"vault"`
 	S3    *MergeStoreS3    `mapstructure:"s3" yaml:"s3"`
+	File  *MergeStoreFile  `mapstructure:"file" yaml:"file"`
 }
 
 // MergeStoreVault uses Vault as the merge store
@@ -182,6 +221,15 @@ type MergeStoreS3 struct {
 	Versioning *VersioningConfig `mapstructure:"versioning" yaml:"versioning"`
 }
 
+// MergeStoreFile uses a local directory as the merge store.
+// Bundles are encrypted with AES-256-GCM using a base64-encoded 32-byte key,
+// given inline (supports ${VAR} expansion) or read from key_file.
+type MergeStoreFile struct {
+	Path    string `mapstructure:"path" yaml:"path"`
+	Key     string `mapstructure:"key" yaml:"key,omitempty"`
+	KeyFile string `mapstructure:"key_file" yaml:"key_file,omitempty"`
+}
+
 // VersioningConfig configures secret versioning (v1.2.0 - Requirement 24)
 type VersioningConfig struct {
 	Enabled        bool `mapstructure:"enabled" yaml:"enabled"`
@@ -198,6 +246,159 @@ type Target struct {
 	Region       string   `mapstructure:"region" yaml:"region"`
 	SecretPrefix string   `mapstructure:"secret_prefix" yaml:"secret_prefix"`
 	RoleARN      string   `mapstructure:"role_arn" yaml:"role_arn"`
+
+	// SecretNameTemplate controls destination secret names, e.g.
+	// "{{.Prefix}}/{{.Target}}/{{.Path}}". Defaults to DefaultSecretNameTemplate.
+	SecretNameTemplate string `mapstructure:"secret_name_template" yaml:"secret_name_template,omitempty"`
+
+	// Destination selects where sync writes. Defaults to Secrets Manager.
+	Destination DestinationConfig `mapstructure:"destination" yaml:"destination,omitempty"`
+
+	// Filters selects which secrets of the merged bundle are synced
+	Filters *v1alpha1.FilterConfig `mapstructure:"filters" yaml:"filters,omitempty"`
+
+	// Transforms reshapes each secret of the merged bundle before it is synced
+	Transforms *v1alpha1.TransformSpec `mapstructure:"transforms" yaml:"transforms,omitempty"`
+
+	// Schedule is a cron expression for running this target under
+	// `secretsync serve`, overriding pipeline.schedule
+	Schedule string `mapstructure:"schedule" yaml:"schedule,omitempty"`
+
+	// Merge configures how imports are merged. Defaults to deep merge with
+	// lists appended and later imports overriding earlier ones.
+	Merge *MergeStrategy `mapstructure:"merge" yaml:"merge,omitempty"`
+
+	// Overrides set keys of the merged bundle after imports are merged
+	Overrides []SecretOverride `mapstructure:"overrides" yaml:"overrides,omitempty"`
+
+	// Remove deletes inherited keys or whole secrets from the merged bundle,
+	// after overrides are applied
+	Remove []SecretRemoval `mapstructure:"remove" yaml:"remove,omitempty"`
+
+	// Guardrails overrides the limits of pipeline.guardrails for this target.
+	// Each limit set here replaces the global one.
+	Guardrails *GuardrailSettings `mapstructure:"guardrails" yaml:"guardrails,omitempty"`
+}
+
+// SecretOverride sets keys of one secret in a target's merged bundle,
+// replacing whatever the imports supplied. The secret is created if no
+// import supplied it.
+type SecretOverride struct {
+	Secret string `mapstructure:"secret" yaml:"secret"`
+
+	// Values are set inline; they are stored in the config, so only use
+	// them for non-sensitive values
+	Values map[string]interface{} `mapstructure:"values" yaml:"values,omitempty"`
+
+	// VaultRefs maps keys to a key of another Vault secret, as
+	// "mount/path/to/secret#key", read with the top-level vault config
+	VaultRefs map[string]string `mapstructure:"vault_refs" yaml:"vault_refs,omitempty"`
+}
+
+// SecretRemoval is a tombstone deleting keys from the secrets of a target's
+// merged bundle matching Secret (path.Match syntax). With no keys the whole
+// secret is deleted. Keys are globs on top-level key names.
+type SecretRemoval struct {
+	Secret string   `mapstructure:"secret" yaml:"secret"`
+	Keys   []string `mapstructure:"keys" yaml:"keys,omitempty"`
+}
+
+// Merge strategies
+const (
+	// MergeListAppend appends the items of later lists (default)
+	MergeListAppend = "append"
+	// MergeListReplace treats lists as single values, resolved by the conflict strategy
+	MergeListReplace = "replace"
+	// MergeListUnion appends only items not already in the list
+	MergeListUnion = "union"
+
+	// MergeMapMerge merges maps key by key (default)
+	MergeMapMerge = "merge"
+	// MergeMapReplace treats maps as single values, resolved by the conflict strategy
+	MergeMapReplace = "replace"
+
+	// MergeConflictOverride keeps the value of the later import (default)
+	MergeConflictOverride = "override"
+	// MergeConflictKeepFirst keeps the value of the earlier import
+	MergeConflictKeepFirst = "keep-first"
+	// MergeConflictError fails the target's merge when imports supply different values
+	MergeConflictError = "error"
+)
+
+// MergeStrategy configures how a target's imports are merged into its bundle.
+// A conflict is two imports supplying values for a key that are not merged:
+// scalars, values of different types, and lists or maps set to replace.
+type MergeStrategy struct {
+	List     string `mapstructure:"list" yaml:"list,omitempty"`         // append, replace or union
+	Map      string `mapstructure:"map" yaml:"map,omitempty"`           // merge or replace
+	Conflict string `mapstructure:"conflict" yaml:"conflict,omitempty"` // override, keep-first or error
+
+	// Rules override the strategy for matching secrets and keys; when several
+	// match, later rules win
+	Rules []MergeRule `mapstructure:"rules" yaml:"rules,omitempty"`
+}
+
+// MergeRule overrides the merge strategy for keys matching both globs
+// (path.Match syntax). Keys of nested maps are matched joined with dots,
+// e.g. "network.cidrs". An empty glob matches everything.
+type MergeRule struct {
+	Secret   string `mapstructure:"secret" yaml:"secret,omitempty"`
+	Key      string `mapstructure:"key" yaml:"key,omitempty"`
+	List     string `mapstructure:"list" yaml:"list,omitempty"`
+	Map      string `mapstructure:"map" yaml:"map,omitempty"`
+	Conflict string `mapstructure:"conflict" yaml:"conflict,omitempty"`
+}
+
+// DestinationConfig selects a non-default sync destination for a target.
+// With no sub-config set, secrets are written to Secrets Manager.
+type DestinationConfig struct {
+	SSM        *SSMDestination        `mapstructure:"ssm" yaml:"ssm,omitempty"`
+	Vault      *VaultDestination      `mapstructure:"vault" yaml:"vault,omitempty"`
+	Kubernetes *KubernetesDestination `mapstructure:"kubernetes" yaml:"kubernetes,omitempty"`
+}
+
+// SSM parameter layouts
+const (
+	// SSMModeJSON writes each secret as one parameter holding its JSON object
+	SSMModeJSON = "json"
+	// SSMModeFlatten writes each key of a secret as its own parameter at {name}/{key}
+	SSMModeFlatten = "flatten"
+)
+
+// SSMDestination writes secrets to SSM Parameter Store as SecureString parameters
+// in the target account and region.
+type SSMDestination struct {
+	Mode     string            `mapstructure:"mode" yaml:"mode,omitempty"` // json (default) or flatten
+	KMSKeyID string            `mapstructure:"kms_key_id" yaml:"kms_key_id,omitempty"`
+	Tier     string            `mapstructure:"tier" yaml:"tier,omitempty"` // Standard, Advanced or Intelligent-Tiering
+	Tags     map[string]string `mapstructure:"tags" yaml:"tags,omitempty"`
+}
+
+// VaultDestination writes secrets to a KV v2 mount, optionally on another Vault
+// cluster. Unset fields fall back to the top-level vault settings.
+type VaultDestination struct {
+	Address   string           `mapstructure:"address" yaml:"address,omitempty"`
+	Namespace string           `mapstructure:"namespace" yaml:"namespace,omitempty"`
+	Mount     string           `mapstructure:"mount" yaml:"mount"`
+	Auth      *VaultAuthConfig `mapstructure:"auth" yaml:"auth,omitempty"`
+}
+
+// Kubernetes Secret layouts
+const (
+	// KubernetesModeSecretPerPath writes one Secret per bundle path with a key per secret key
+	KubernetesModeSecretPerPath = "secret-per-path"
+	// KubernetesModeSingleSecret writes one Secret with a key per bundle path holding its JSON
+	KubernetesModeSingleSecret = "single-secret"
+)
+
+// KubernetesDestination writes secrets as v1.Secrets into a namespace.
+// The cluster is reached with the in-cluster config, or with kubeconfig/context when set.
+type KubernetesDestination struct {
+	Namespace  string `mapstructure:"namespace" yaml:"namespace"`
+	Mode       string `mapstructure:"mode" yaml:"mode,omitempty"`               // secret-per-path (default) or single-secret
+	SecretName string `mapstructure:"secret_name" yaml:"secret_name,omitempty"` // single-secret name (default: target name)
+	Kubeconfig string `mapstructure:"kubeconfig" yaml:"kubeconfig,omitempty"`
+	Context    string `mapstructure:"context" yaml:"context,omitempty"`
 }
 
 // UnmarshalYAML implements custom YAML unmarshaling to support shorthand format.
@@ -228,9 +429,18 @@ type DynamicTarget struct {
 	// AccountNamePatterns maps discovered accounts to specific targets using regex
 	AccountNamePatterns []AccountNamePattern `mapstructure:"account_name_patterns" yaml:"account_name_patterns"`
 
-	Region       string `mapstructure:"region" yaml:"region"`
-	SecretPrefix string `mapstructure:"secret_prefix" yaml:"secret_prefix"`
-	RoleARN      string `mapstructure:"role_arn" yaml:"role_arn"`
+	Region             string `mapstructure:"region" yaml:"region"`
+	SecretPrefix       string `mapstructure:"secret_prefix" yaml:"secret_prefix"`
+	RoleARN            string `mapstructure:"role_arn" yaml:"role_arn"`
+	SecretNameTemplate string `mapstructure:"secret_name_template" yaml:"secret_name_template,omitempty"`
+
+	Destination DestinationConfig       `mapstructure:"destination" yaml:"destination,omitempty"`
+	Filters     *v1alpha1.FilterConfig  `mapstructure:"filters" yaml:"filters,omitempty"`
+	Transforms  *v1alpha1.TransformSpec `mapstructure:"transforms" yaml:"transforms,omitempty"`
+	Schedule    string                  `mapstructure:"schedule" yaml:"schedule,omitempty"`
+	Merge       *MergeStrategy          `mapstructure:"merge" yaml:"merge,omitempty"`
+	// Guardrails overrides the limits of pipeline.guardrails for every discovered target
+	Guardrails *GuardrailSettings `mapstructure:"guardrails" yaml:"guardrails,omitempty"`
 }
 
 // DiscoveryConfig defines how to discover dynamic targets
@@ -311,6 +521,53 @@ type PipelineSettings struct {
 	Sync            SyncSettings  `mapstructure:"sync" yaml:"sync"`
 	DryRun          bool          `mapstructure:"dry_run" yaml:"dry_run"`
 	ContinueOnError bool          `mapstructure:"continue_on_error" yaml:"continue_on_error"`
+
+	// Schedule is the default cron expression for `secretsync serve`, e.g.
+	// "*/15 * * * *" or "@every 1h". Targets without a schedule of their own
+	// run on it; with neither set a target is not run by serve.
+	Schedule string `mapstructure:"schedule" yaml:"schedule,omitempty"`
+
+	// Lock makes runs that write take a distributed lock, so overlapping runs
+	// from different processes cannot interleave
+	Lock LockSettings `mapstructure:"lock" yaml:"lock,omitempty"`
+
+	// Guardrails abort a target whose merge or sync would change too much,
+	// unless the run allows large changes
+	Guardrails GuardrailSettings `mapstructure:"guardrails" yaml:"guardrails,omitempty"`
+}
+
+// GuardrailSettings limits how much one run may change a target's merged
+// bundle or destination. Limits that are not set are not enforced.
+type GuardrailSettings struct {
+	// MaxRemoved is the most secrets a run may remove
+	MaxRemoved *int `mapstructure:"max_removed" yaml:"max_removed,omitempty"`
+	// MaxChangedPercent is the most secrets a run may add, modify or remove,
+	// as a percentage of the existing secrets. Not enforced when there are
+	// no existing secrets.
+	MaxChangedPercent *float64 `mapstructure:"max_changed_percent" yaml:"max_changed_percent,omitempty"`
+	// RefuseEmpty refuses to write an empty bundle or sync no secrets
+	RefuseEmpty *bool `mapstructure:"refuse_empty" yaml:"refuse_empty,omitempty"`
+}
+
+// LockSettings configures the distributed run lock. A lock is a record naming
+// the run holding it; the holder refreshes it every TTL/3 and a lock not
+// refreshed for TTL can be taken over.
+type LockSettings struct {
+	// Backend is "vault" (a KV v2 entry written with check-and-set) or "s3" (an
+	// object written with conditional requests). Empty disables the lock.
+	Backend string `mapstructure:"backend" yaml:"backend,omitempty"`
+	// Scope is "config" (default), one lock for every run of the config, or
+	// "target", one lock per target so runs of disjoint targets do not wait
+	Scope string `mapstructure:"scope" yaml:"scope,omitempty"`
+	// Name of the config-scoped lock (default "pipeline")
+	Name string `mapstructure:"name" yaml:"name,omitempty"`
+	// TTL is how long a lock outlives its last heartbeat (default 5m)
+	TTL time.Duration `mapstructure:"ttl" yaml:"ttl,omitempty"`
+	// Mount is the KV v2 mount of the vault backend (default merge_store.vault.mount)
+	Mount string `mapstructure:"mount" yaml:"mount,omitempty"`
+	// Bucket and Prefix locate the s3 backend's objects (default merge_store.s3)
+	Bucket string `mapstructure:"bucket" yaml:"bucket,omitempty"`
+	Prefix string `mapstructure:"prefix" yaml:"prefix,omitempty"`
 }
 
 // MergeSettings configures the merge phase
@@ -320,6 +577,26 @@ type MergeSettings struct {
 
 // SyncSettings configures the sync phase
 type SyncSettings struct {
-	Parallel      int  `mapstructure:"parallel" yaml:"parallel"`
+	Parallel int `mapstructure:"parallel" yaml:"parallel"`
+
+	// DeleteOrphans removes secrets from target accounts that are no longer in the
+	// merged bundle. Only secrets carrying SecretSync's ownership tags are touched.
 	DeleteOrphans bool `mapstructure:"delete_orphans" yaml:"delete_orphans"`
+	// RecoveryWindowDays is the Secrets Manager recovery window for orphan deletion
+	// (7-30 days, 0 uses the AWS default of 30)
+	RecoveryWindowDays int `mapstructure:"recovery_window_days" yaml:"recovery_window_days,omitempty"`
+
+	// Freshness refuses to sync bundles whose manifest is too old or no longer
+	// matches the sources they were merged from
+	Freshness FreshnessSettings `mapstructure:"freshness" yaml:"freshness,omitempty"`
+}
+
+// FreshnessSettings are the requirements a bundle's manifest must meet before
+// sync writes the bundle to a destination
+type FreshnessSettings struct {
+	// MaxAge refuses bundles merged longer ago than this (0 disables the check)
+	MaxAge time.Duration `mapstructure:"max_age" yaml:"max_age,omitempty"`
+	// MatchSources refuses bundles whose Vault source secrets were added,
+	// removed or written since the merge
+	MatchSources bool `mapstructure:"match_sources" yaml:"match_sources,omitempty"`
 }
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+)
+
+// method returns the name of the configured auth method, or "" when none is set
+func (a *VaultAuthConfig) method() string {
+	switch {
+	case a.Token != nil:
+		return vault.AuthMethodToken
+	case a.AppRole != nil:
+		return vault.AuthMethodAppRole
+	case a.Kubernetes != nil:
+		return vault.AuthMethodKubernetes
+	case a.JWT != nil:
+		return vault.AuthMethodJWT
+	case a.AWS != nil:
+		return vault.AuthMethodAWS
+	}
+	return ""
+}
+
+// validate checks that at most one method is configured and that it is complete
+func (a *VaultAuthConfig) validate() error {
+	configured := 0
+	for _, set := range []bool{a.Token != nil, a.AppRole != nil, a.Kubernetes != nil, a.JWT != nil, a.AWS != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return fmt.Errorf("vault.auth: only one auth method may be configured")
+	}
+
+	switch {
+	case a.AppRole != nil && a.AppRole.RoleID == "":
+		return fmt.Errorf("vault.auth.approle.role_id is required")
+	case a.Kubernetes != nil && a.Kubernetes.Role == "":
+		return fmt.Errorf("vault.auth.kubernetes.role is required")
+	case a.JWT != nil && a.JWT.Role == "":
+		return fmt.Errorf("vault.auth.jwt.role is required")
+	case a.JWT != nil && a.JWT.TokenFile == "" && a.JWT.TokenEnv == "":
+		return fmt.Errorf("vault.auth.jwt requires token_file or token_env")
+	}
+	return nil
+}
+
+// clientAuth converts the configured method into VaultClient auth.
+// Returns nil when no method is configured, which keeps the client's
+// default of service account JWT or VAULT_TOKEN.
+func (a *VaultAuthConfig) clientAuth() *vault.Auth {
+	switch a.method() {
+	case vault.AuthMethodToken:
+		return &vault.Auth{Method: vault.AuthMethodToken, Token: a.Token.Token}
+	case vault.AuthMethodAppRole:
+		return &vault.Auth{
+			Method:   vault.AuthMethodAppRole,
+			Mount:    a.AppRole.Mount,
+			RoleID:   a.AppRole.RoleID,
+			SecretID: a.AppRole.SecretID,
+		}
+	case vault.AuthMethodKubernetes:
+		return &vault.Auth{
+			Method: vault.AuthMethodKubernetes,
+			Mount:  a.Kubernetes.MountPath,
+			Role:   a.Kubernetes.Role,
+		}
+	case vault.AuthMethodJWT:
+		return &vault.Auth{
+			Method:  vault.AuthMethodJWT,
+			Mount:   a.JWT.MountPath,
+			Role:    a.JWT.Role,
+			JWTFile: a.JWT.TokenFile,
+			JWTEnv:  a.JWT.TokenEnv,
+		}
+	case vault.AuthMethodAWS:
+		return &vault.Auth{
+			Method:      vault.AuthMethodAWS,
+			Mount:       a.AWS.MountPath,
+			Role:        a.AWS.Role,
+			AWSRegion:   a.AWS.Region,
+			AWSServerID: a.AWS.ServerID,
+		}
+	}
+	return nil
+}
+
+// withOverrides returns a copy of the config with any non-empty connection
+// settings replaced
+func (c VaultConfig) withOverrides(address, namespace string, auth *VaultAuthConfig) VaultConfig {
+	if address != "" {
+		c.Address = address
+	}
+	if namespace != "" {
+		c.Namespace = namespace
+	}
+	if auth != nil {
+		c.Auth = *auth
+	}
+	return c
+}
+
+// newVaultClient builds an uninitialized Vault client from pipeline configuration
+func newVaultClient(cfg *VaultConfig) *vault.VaultClient {
+	return &vault.VaultClient{
+		Address:                  cfg.Address,
+		Namespace:                cfg.Namespace,
+		Auth:                     cfg.Auth.clientAuth(),
+		MaxTraversalDepth:        cfg.MaxTraversalDepth,
+		MaxSecretsPerMount:       cfg.MaxSecretsPerMount,
+		QueueCompactionThreshold: cfg.QueueCompactionThreshold,
+	}
+}
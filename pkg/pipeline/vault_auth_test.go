@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/extended-data-library/secretssync/pkg/client/vault"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultAuthConfigClientAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     VaultAuthConfig
+		expected *vault.Auth
+	}{
+		{
+			name:     "none configured",
+			auth:     VaultAuthConfig{},
+			expected: nil,
+		},
+		{
+			name:     "token",
+			auth:     VaultAuthConfig{Token: &TokenAuth{Token: "s.abc"}},
+			expected: &vault.Auth{Method: vault.AuthMethodToken, Token: "s.abc"},
+		},
+		{
+			name: "approle",
+			auth: VaultAuthConfig{AppRole: &AppRoleAuth{Mount: "ci", RoleID: "rid", SecretID: "sid"}},
+			expected: &vault.Auth{
+				Method:   vault.AuthMethodAppRole,
+				Mount:    "ci",
+				RoleID:   "rid",
+				SecretID: "sid",
+			},
+		},
+		{
+			name: "kubernetes",
+			auth: VaultAuthConfig{Kubernetes: &KubernetesAuth{Role: "secretsync", MountPath: "k8s-prod"}},
+			expected: &vault.Auth{
+				Method: vault.AuthMethodKubernetes,
+				Mount:  "k8s-prod",
+				Role:   "secretsync",
+			},
+		},
+		{
+			name: "jwt",
+			auth: VaultAuthConfig{JWT: &JWTAuth{Role: "ci", MountPath: "github", TokenFile: "/tmp/token"}},
+			expected: &vault.Auth{
+				Method:  vault.AuthMethodJWT,
+				Mount:   "github",
+				Role:    "ci",
+				JWTFile: "/tmp/token",
+			},
+		},
+		{
+			name: "aws iam",
+			auth: VaultAuthConfig{AWS: &AWSIAMAuth{Role: "ec2", Region: "us-west-2", ServerID: "vault.example.com"}},
+			expected: &vault.Auth{
+				Method:      vault.AuthMethodAWS,
+				Role:        "ec2",
+				AWSRegion:   "us-west-2",
+				AWSServerID: "vault.example.com",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.auth.clientAuth())
+		})
+	}
+}
+
+func TestVaultAuthConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   VaultAuthConfig
+		errMsg string
+	}{
+		{name: "none", auth: VaultAuthConfig{}},
+		{name: "token", auth: VaultAuthConfig{Token: &TokenAuth{Token: "s.abc"}}},
+		{
+			name: "multiple methods",
+			auth: VaultAuthConfig{
+				Token:   &TokenAuth{Token: "s.abc"},
+				AppRole: &AppRoleAuth{RoleID: "rid"},
+			},
+			errMsg: "only one auth method",
+		},
+		{
+			name:   "approle without role_id",
+			auth:   VaultAuthConfig{AppRole: &AppRoleAuth{SecretID: "sid"}},
+			errMsg: "vault.auth.approle.role_id is required",
+		},
+		{
+			name:   "kubernetes without role",
+			auth:   VaultAuthConfig{Kubernetes: &KubernetesAuth{MountPath: "kubernetes"}},
+			errMsg: "vault.auth.kubernetes.role is required",
+		},
+		{
+			name:   "jwt without token source",
+			auth:   VaultAuthConfig{JWT: &JWTAuth{Role: "ci"}},
+			errMsg: "vault.auth.jwt requires token_file or token_env",
+		},
+		{
+			name:   "jwt without role",
+			auth:   VaultAuthConfig{JWT: &JWTAuth{TokenEnv: "VAULT_JWT"}},
+			errMsg: "vault.auth.jwt.role is required",
+		},
+		{name: "aws iam", auth: VaultAuthConfig{AWS: &AWSIAMAuth{Role: "ec2"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.validate()
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestNewVaultClientFromConfig(t *testing.T) {
+	c := newVaultClient(&VaultConfig{
+		Address:           "https://vault.example.com",
+		Namespace:         "admin",
+		Auth:              VaultAuthConfig{AppRole: &AppRoleAuth{RoleID: "rid"}},
+		MaxTraversalDepth: 10,
+	})
+
+	assert.Equal(t, "https://vault.example.com", c.Address)
+	assert.Equal(t, "admin", c.Namespace)
+	assert.Equal(t, 10, c.MaxTraversalDepth)
+	assert.Equal(t, vault.AuthMethodAppRole, c.Auth.Method)
+}
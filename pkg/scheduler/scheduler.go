@@ -0,0 +1,266 @@
+// Package scheduler runs pipelines on cron schedules for `secretsync serve`.
+// Targets are grouped by schedule (see pipeline.Config.ScheduleGroups) and
+// each group runs as one pipeline run. Runs never overlap: a group that fires
+// while another run is in progress waits for it, and a group that is still
+// waiting or running when it fires again is skipped.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/observability"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// Runner runs a configured pipeline. *pipeline.Pipeline implements it.
+type Runner interface {
+	Run(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, error)
+	Config() *pipeline.Config
+}
+
+// LoadFunc builds a Runner from the current configuration. It is called on
+// Start and on every Reload.
+type LoadFunc func(ctx context.Context) (Runner, error)
+
+// Scheduler runs the schedule groups of a pipeline configuration
+type Scheduler struct {
+	load LoadFunc
+	opts pipeline.Options
+
+	// mu guards the fields below
+	mu      sync.Mutex
+	cron    *cron.Cron
+	runner  Runner
+	groups  []pipeline.ScheduleGroup
+	stopped bool
+
+	// runMu serializes runs; running tracks runs started or waiting
+	runMu   sync.Mutex
+	running sync.WaitGroup
+
+	// runCtx is passed to every run and canceled when a drain times out
+	runCtx     context.Context
+	cancelRuns context.CancelFunc
+}
+
+// New creates a scheduler. opts are used for every run, with Targets set to
+// the group being run.
+func New(load LoadFunc, opts pipeline.Options) *Scheduler {
+	runCtx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		load:       load,
+		opts:       opts,
+		runCtx:     runCtx,
+		cancelRuns: cancel,
+	}
+}
+
+// Start loads the configuration and starts running its schedules
+func (s *Scheduler) Start(ctx context.Context) error {
+	runner, groups, c, err := s.build(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return errors.New("scheduler is stopped")
+	}
+	if s.cron != nil {
+		return errors.New("scheduler is already started")
+	}
+	s.runner, s.groups, s.cron = runner, groups, c
+	c.Start()
+	logSchedules(groups)
+	return nil
+}
+
+// Reload loads the configuration again and replaces the schedules. On error
+// the current configuration keeps running. Runs in progress finish with the
+// configuration they started with.
+func (s *Scheduler) Reload(ctx context.Context) error {
+	runner, groups, c, err := s.build(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return errors.New("scheduler is stopped")
+	}
+	old := s.cron
+	s.runner, s.groups, s.cron = runner, groups, c
+	c.Start()
+	s.mu.Unlock()
+
+	if old != nil {
+		old.Stop()
+	}
+	log.WithField("action", "Scheduler.Reload").Info("Configuration reloaded")
+	logSchedules(groups)
+	return nil
+}
+
+// Trigger runs every schedule group once now, in the background
+func (s *Scheduler) Trigger() {
+	s.mu.Lock()
+	runner, groups := s.runner, s.groups
+	s.mu.Unlock()
+
+	for _, g := range groups {
+		go s.run(runner, g)
+	}
+}
+
+// Runner returns the runner of the current configuration, or nil before Start
+func (s *Scheduler) Runner() Runner {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runner
+}
+
+// RunLock returns the lock held by every scheduled run. Runs started outside
+// the scheduler (e.g. through the control API) hold it too, so they never
+// overlap a scheduled run, including one of a configuration since reloaded.
+func (s *Scheduler) RunLock() sync.Locker {
+	return &s.runMu
+}
+
+// Stop stops the schedules and waits for in-flight runs to finish. Runs
+// waiting to start are skipped. If ctx ends first, in-flight runs are
+// canceled and ctx's error is returned.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	c := s.cron
+	s.mu.Unlock()
+
+	if c != nil {
+		c.Stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancelRuns()
+		return nil
+	case <-ctx.Done():
+		s.cancelRuns()
+		return fmt.Errorf("timed out waiting for in-flight runs: %w", ctx.Err())
+	}
+}
+
+// build loads the configuration and creates, without starting, its cron
+func (s *Scheduler) build(ctx context.Context) (Runner, []pipeline.ScheduleGroup, *cron.Cron, error) {
+	runner, err := s.load(ctx)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load pipeline: %w", err)
+	}
+
+	groups, unscheduled := runner.Config().ScheduleGroups()
+	if len(groups) == 0 {
+		return nil, nil, nil, errors.New("no schedules configured: set pipeline.schedule or targets.<name>.schedule")
+	}
+	if len(unscheduled) > 0 {
+		log.WithFields(log.Fields{
+			"action":  "Scheduler.build",
+			"targets": unscheduled,
+		}).Warn("Targets without a schedule will not run")
+	}
+
+	c := cron.New(cron.WithChain(cron.SkipIfStillRunning(cron.PrintfLogger(log.StandardLogger()))))
+	for _, g := range groups {
+		schedule, err := pipeline.ParseSchedule(g.Schedule)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid schedule %q: %w", g.Schedule, err)
+		}
+		c.Schedule(schedule, cron.FuncJob(func() { s.run(runner, g) }))
+	}
+	return runner, groups, c, nil
+}
+
+// run runs one schedule group, after any run in progress
+func (s *Scheduler) run(runner Runner, g pipeline.ScheduleGroup) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.running.Add(1)
+	s.mu.Unlock()
+	defer s.running.Done()
+
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	l := log.WithFields(log.Fields{
+		"action":   "Scheduler.run",
+		"schedule": g.Schedule,
+		"targets":  g.Targets,
+	})
+
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		l.Info("Skipping scheduled run, scheduler is stopping")
+		return
+	}
+
+	opts := s.opts
+	opts.Targets = g.Targets
+
+	start := time.Now()
+	l.Info("Starting scheduled run")
+	results, err := runner.Run(s.runCtx, opts)
+
+	status := "success"
+	if err == nil {
+		var failed []string
+		for _, r := range results {
+			if !r.Success {
+				failed = append(failed, r.Target)
+			}
+		}
+		if len(failed) > 0 {
+			err = fmt.Errorf("targets failed: %v", failed)
+		}
+	}
+	if err != nil {
+		status = "failure"
+		l.WithError(err).Error("Scheduled run failed")
+	} else {
+		l.WithField("duration", time.Since(start)).Info("Scheduled run completed")
+	}
+
+	observability.PipelineScheduledRuns.WithLabelValues(g.Schedule, status).Inc()
+	observability.PipelineLastRunTimestamp.WithLabelValues(g.Schedule, status).SetToCurrentTime()
+}
+
+func logSchedules(groups []pipeline.ScheduleGroup) {
+	now := time.Now()
+	for _, g := range groups {
+		l := log.WithFields(log.Fields{
+			"action":   "logSchedules",
+			"schedule": g.Schedule,
+			"targets":  g.Targets,
+		})
+		if schedule, err := pipeline.ParseSchedule(g.Schedule); err == nil {
+			l = l.WithField("next", schedule.Next(now))
+		}
+		l.Info("Scheduled targets")
+	}
+}
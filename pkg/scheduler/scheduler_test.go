@@ -0,0 +1,253 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner records runs. When block is set, each run waits for it to be
+// closed or for its context to be canceled.
+type fakeRunner struct {
+	config *pipeline.Config
+	block  chan struct{}
+
+	mu   sync.Mutex
+	runs [][]string
+	errs []error
+
+	started chan []string
+}
+
+func newFakeRunner(cfg *pipeline.Config) *fakeRunner {
+	return &fakeRunner{config: cfg, started: make(chan []string, 10)}
+}
+
+func (f *fakeRunner) Config() *pipeline.Config { return f.config }
+
+func (f *fakeRunner) Run(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, error) {
+	f.started <- opts.Targets
+	var err error
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.runs = append(f.runs, opts.Targets)
+	f.errs = append(f.errs, err)
+	return []pipeline.Result{{Target: "t", Success: err == nil}}, err
+}
+
+func (f *fakeRunner) Runs() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.runs...)
+}
+
+func loader(runners ...Runner) LoadFunc {
+	var mu sync.Mutex
+	return func(ctx context.Context) (Runner, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(runners) == 0 {
+			return nil, errors.New("config not found")
+		}
+		r := runners[0]
+		runners = runners[1:]
+		if r == nil {
+			return nil, errors.New("invalid configuration")
+		}
+		return r, nil
+	}
+}
+
+func waitStarted(t *testing.T, f *fakeRunner) []string {
+	t.Helper()
+	select {
+	case targets := <-f.started:
+		return targets
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not start")
+		return nil
+	}
+}
+
+func TestScheduler_Start(t *testing.T) {
+	tests := []struct {
+		name    string
+		load    LoadFunc
+		wantErr string
+	}{
+		{
+			name:    "load error",
+			load:    loader(),
+			wantErr: "failed to load pipeline: config not found",
+		},
+		{
+			name: "no schedules",
+			load: loader(newFakeRunner(&pipeline.Config{
+				Targets: map[string]pipeline.Target{"Stg": {}},
+			})),
+			wantErr: "no schedules configured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.load, pipeline.Options{})
+			assert.ErrorContains(t, s.Start(context.Background()), tt.wantErr)
+		})
+	}
+}
+
+func TestScheduler_RunsOnSchedule(t *testing.T) {
+	f := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{
+			"Stg":  {Schedule: "@every 1s"},
+			"Prod": {Schedule: "@daily"},
+		},
+	})
+	s := New(loader(f), pipeline.Options{Operation: pipeline.OperationPipeline})
+	require.NoError(t, s.Start(context.Background()))
+
+	assert.Equal(t, []string{"Stg"}, waitStarted(t, f))
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	f := newFakeRunner(&pipeline.Config{
+		Pipeline: pipeline.PipelineSettings{Schedule: "@daily"},
+		Targets: map[string]pipeline.Target{
+			"Stg":  {},
+			"Prod": {},
+			"Dev":  {Schedule: "@weekly"},
+		},
+	})
+	s := New(loader(f), pipeline.Options{})
+	require.NoError(t, s.Start(context.Background()))
+
+	s.Trigger()
+	waitStarted(t, f)
+	waitStarted(t, f)
+	require.NoError(t, s.Stop(context.Background()))
+
+	assert.ElementsMatch(t, [][]string{{"Prod", "Stg"}, {"Dev"}}, f.Runs())
+}
+
+func TestScheduler_Reload(t *testing.T) {
+	first := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{"Stg": {Schedule: "@daily"}},
+	})
+	second := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{"Prod": {Schedule: "@daily"}},
+	})
+	s := New(loader(first, nil, second), pipeline.Options{})
+	assert.Nil(t, s.Runner())
+	require.NoError(t, s.Start(context.Background()))
+	assert.Same(t, first, s.Runner())
+
+	// A failed reload keeps the running configuration
+	assert.ErrorContains(t, s.Reload(context.Background()), "invalid configuration")
+	s.Trigger()
+	assert.Equal(t, []string{"Stg"}, waitStarted(t, first))
+
+	require.NoError(t, s.Reload(context.Background()))
+	assert.Same(t, second, s.Runner())
+	s.Trigger()
+	assert.Equal(t, []string{"Prod"}, waitStarted(t, second))
+
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+func TestScheduler_RunLock(t *testing.T) {
+	first := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{"Stg": {Schedule: "@daily"}},
+	})
+	second := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{"Prod": {Schedule: "@daily"}},
+	})
+	s := New(loader(first, second), pipeline.Options{})
+	require.NoError(t, s.Start(context.Background()))
+	require.NoError(t, s.Reload(context.Background()))
+
+	// The lock is shared across reloads, so a run of the new configuration
+	// waits while it is held
+	s.RunLock().Lock()
+	s.Trigger()
+	select {
+	case <-second.started:
+		t.Fatal("run started while the run lock was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	s.RunLock().Unlock()
+	assert.Equal(t, []string{"Prod"}, waitStarted(t, second))
+
+	require.NoError(t, s.Stop(context.Background()))
+}
+
+func TestScheduler_StopDrains(t *testing.T) {
+	f := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{
+			"Stg":  {Schedule: "@daily"},
+			"Prod": {Schedule: "@weekly"},
+		},
+	})
+	f.block = make(chan struct{})
+	s := New(loader(f), pipeline.Options{})
+	require.NoError(t, s.Start(context.Background()))
+
+	// One group runs while the other waits for it
+	s.Trigger()
+	waitStarted(t, f)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(context.Background()) }()
+
+	select {
+	case <-stopped:
+		t.Fatal("Stop returned before the in-flight run finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(f.block)
+	require.NoError(t, <-stopped)
+
+	// The waiting group was skipped and the in-flight run completed
+	assert.Len(t, f.Runs(), 1)
+	assert.Equal(t, []error{nil}, f.errs)
+
+	s.Trigger()
+	assert.Empty(t, f.started)
+}
+
+func TestScheduler_StopTimeout(t *testing.T) {
+	f := newFakeRunner(&pipeline.Config{
+		Targets: map[string]pipeline.Target{"Stg": {Schedule: "@daily"}},
+	})
+	f.block = make(chan struct{})
+	s := New(loader(f), pipeline.Options{})
+	require.NoError(t, s.Start(context.Background()))
+
+	s.Trigger()
+	waitStarted(t, f)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+
+	// The in-flight run is canceled
+	assert.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return len(f.errs) == 1 && errors.Is(f.errs[0], context.Canceled)
+	}, 5*time.Second, 10*time.Millisecond)
+}
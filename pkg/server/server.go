@@ -0,0 +1,434 @@
+// Package server provides the HTTP control API of `secretsync serve`: it
+// starts pipeline runs in the background and serves their status, results
+// and diffs. Every request needs an `Authorization: Bearer <token>` header.
+//
+//	POST /api/v1/runs                  start a run
+//	GET  /api/v1/runs                  list recent runs
+//	GET  /api/v1/runs/{id}             run status
+//	GET  /api/v1/runs/{id}/results     per-target results
+//	GET  /api/v1/runs/{id}/diff        diff, ?format=human|json|github|compact|sidebyside
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxRuns is the number of finished runs kept for polling
+const MaxRuns = 100
+
+// MaxPendingRuns is the number of runs that may wait to start. Further
+// requests are rejected with 429 Too Many Requests.
+const MaxPendingRuns = 10
+
+// maxRequestBytes limits the size of a run request body
+const maxRequestBytes = 1 << 20
+
+// Runner runs a configured pipeline. *pipeline.Pipeline implements it.
+type Runner interface {
+	RunWithDiff(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, *diff.PipelineDiff, error)
+	Config() *pipeline.Config
+}
+
+// Run statuses
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// RunRequest starts a run
+type RunRequest struct {
+	// Operation is pipeline (default), merge or sync
+	Operation pipeline.Operation `json:"operation,omitempty"`
+	// Targets limits the run to these targets and their dependencies
+	Targets []string `json:"targets,omitempty"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+	// Diff computes the diff of a run that is not a dry run
+	Diff bool `json:"diff,omitempty"`
+	// AllowLargeChanges lets targets proceed past tripped guardrails
+	AllowLargeChanges bool `json:"allow_large_changes,omitempty"`
+}
+
+// RunStatus describes a run
+type RunStatus struct {
+	ID         string             `json:"id"`
+	Status     string             `json:"status"`
+	Operation  pipeline.Operation `json:"operation"`
+	Targets    []string           `json:"targets,omitempty"`
+	DryRun     bool               `json:"dry_run"`
+	Diff       bool               `json:"diff"`
+	Error      string             `json:"error,omitempty"`
+	ExitCode   int                `json:"exit_code"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+}
+
+// Result is a pipeline.Result with its error as a string
+type Result struct {
+	Target    string                 `json:"target"`
+	Phase     string                 `json:"phase"`
+	Operation string                 `json:"operation"`
+	Success   bool                   `json:"success"`
+	Error     string                 `json:"error,omitempty"`
+	Duration  time.Duration          `json:"duration"`
+	Details   pipeline.ResultDetails `json:"details"`
+	Diff      *diff.TargetDiff       `json:"diff,omitempty"`
+}
+
+type run struct {
+	RunStatus
+	results []pipeline.Result
+	diff    *diff.PipelineDiff
+}
+
+// Server serves the control API. Runs hold runLock while they run, so they
+// are serialized with each other and with every run that shares the lock.
+type Server struct {
+	runner  func() Runner
+	token   string
+	runLock sync.Locker
+
+	mu   sync.Mutex
+	runs map[string]*run
+	ids  []string
+
+	wg         sync.WaitGroup
+	runCtx     context.Context
+	cancelRuns context.CancelFunc
+}
+
+// New creates a server. runner returns the pipeline to run, which may change
+// between runs (e.g. on config reload). token is required. runLock is held
+// for every run, so sharing it with a scheduler keeps API and scheduled runs
+// from overlapping across reloads; nil uses a lock of the server's own.
+func New(runner func() Runner, token string, runLock sync.Locker) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("an API token is required")
+	}
+	if runLock == nil {
+		runLock = &sync.Mutex{}
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		runner:     runner,
+		token:      token,
+		runLock:    runLock,
+		runs:       make(map[string]*run),
+		runCtx:     runCtx,
+		cancelRuns: cancel,
+	}, nil
+}
+
+// Handler returns the API handler
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/runs", s.createRun)
+	mux.HandleFunc("GET /api/v1/runs", s.listRuns)
+	mux.HandleFunc("GET /api/v1/runs/{id}", s.getRun)
+	mux.HandleFunc("GET /api/v1/runs/{id}/results", s.getResults)
+	mux.HandleFunc("GET /api/v1/runs/{id}/diff", s.getDiff)
+	return s.authenticate(mux)
+}
+
+// Wait waits for runs started through the API to finish. If ctx ends first,
+// they are canceled and ctx's error is returned.
+func (s *Server) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelRuns()
+		return fmt.Errorf("timed out waiting for API runs: %w", ctx.Err())
+	}
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="secretsync"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) createRun(w http.ResponseWriter, r *http.Request) {
+	var req RunRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+
+	runner := s.runner()
+	if runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "pipeline is not loaded")
+		return
+	}
+	if err := validateRequest(&req, runner.Config()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rn := &run{RunStatus: RunStatus{
+		ID:        uuid.NewString(),
+		Status:    StatusPending,
+		Operation: req.Operation,
+		Targets:   req.Targets,
+		DryRun:    req.DryRun,
+		Diff:      req.Diff || req.DryRun,
+		CreatedAt: time.Now().UTC(),
+	}}
+	s.mu.Lock()
+	if s.pendingLocked() >= MaxPendingRuns {
+		s.mu.Unlock()
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("%d runs are already waiting to start", MaxPendingRuns))
+		return
+	}
+	s.runs[rn.ID] = rn
+	s.ids = append(s.ids, rn.ID)
+	s.pruneLocked()
+	status := rn.RunStatus
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.execute(runner, rn, pipeline.Options{
+		Operation:         req.Operation,
+		Targets:           req.Targets,
+		DryRun:            req.DryRun,
+		ContinueOnError:   true,
+		ComputeDiff:       req.Diff || req.DryRun,
+		AllowLargeChanges: req.AllowLargeChanges,
+	})
+
+	w.Header().Set("Location", "/api/v1/runs/"+rn.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+func validateRequest(req *RunRequest, cfg *pipeline.Config) error {
+	switch req.Operation {
+	case "":
+		req.Operation = pipeline.OperationPipeline
+	case pipeline.OperationPipeline, pipeline.OperationMerge, pipeline.OperationSync:
+	default:
+		return fmt.Errorf("unknown operation %q (must be pipeline, merge or sync)", req.Operation)
+	}
+	for _, target := range req.Targets {
+		if _, ok := cfg.Targets[target]; !ok {
+			return fmt.Errorf("unknown target %q", target)
+		}
+	}
+	return nil
+}
+
+func (s *Server) execute(runner Runner, rn *run, opts pipeline.Options) {
+	defer s.wg.Done()
+	l := log.WithFields(log.Fields{
+		"action":    "Server.execute",
+		"run":       rn.ID,
+		"operation": opts.Operation,
+		"targets":   opts.Targets,
+		"dryRun":    opts.DryRun,
+	})
+
+	s.runLock.Lock()
+	defer s.runLock.Unlock()
+
+	started := time.Now().UTC()
+	s.mu.Lock()
+	rn.Status, rn.StartedAt = StatusRunning, &started
+	s.mu.Unlock()
+
+	l.Info("Starting API run")
+	results, runDiff, err := runner.RunWithDiff(s.runCtx, opts)
+
+	finished := time.Now().UTC()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rn.results, rn.diff, rn.FinishedAt = results, runDiff, &finished
+	rn.ExitCode = exitCode(results, runDiff)
+	if err == nil && rn.ExitCode >= 2 {
+		err = errors.New("one or more targets failed")
+	}
+	if err != nil {
+		rn.Status, rn.Error = StatusFailed, err.Error()
+		if rn.ExitCode < 2 {
+			rn.ExitCode = 2
+		}
+		l.WithError(err).Error("API run failed")
+		return
+	}
+	rn.Status = StatusSucceeded
+	l.Info("API run completed")
+}
+
+// exitCode matches `secretsync pipeline --exit-code`: 0 = no changes,
+// 1 = changes, 2 = errors, 3 = a guardrail aborted a target
+func exitCode(results []pipeline.Result, runDiff *diff.PipelineDiff) int {
+	if len(pipeline.TrippedGuardrails(results)) > 0 {
+		return pipeline.ExitCodeGuardrail
+	}
+	for _, r := range results {
+		if !r.Success {
+			return 2
+		}
+	}
+	if runDiff != nil {
+		return runDiff.ExitCode()
+	}
+	return 0
+}
+
+// pendingLocked counts the runs waiting to start
+func (s *Server) pendingLocked() int {
+	n := 0
+	for _, rn := range s.runs {
+		if rn.Status == StatusPending {
+			n++
+		}
+	}
+	return n
+}
+
+// pruneLocked drops the oldest finished runs beyond MaxRuns
+func (s *Server) pruneLocked() {
+	for i := 0; len(s.ids) > MaxRuns && i < len(s.ids); {
+		rn := s.runs[s.ids[i]]
+		if rn.FinishedAt == nil {
+			i++
+			continue
+		}
+		delete(s.runs, rn.ID)
+		s.ids = slices.Delete(s.ids, i, i+1)
+	}
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	runs := make([]RunStatus, 0, len(s.ids))
+	for i := len(s.ids) - 1; i >= 0; i-- {
+		runs = append(runs, s.runs[s.ids[i]].RunStatus)
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// lookup returns a copy of the run named by the request, writing a 404 if
+// it does not exist
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rn, ok := s.runs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return run{}, false
+	}
+	return *rn, true
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request) {
+	rn, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, rn.RunStatus)
+}
+
+func (s *Server) getResults(w http.ResponseWriter, r *http.Request) {
+	rn, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if rn.FinishedAt == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("run is %s", rn.Status))
+		return
+	}
+
+	results := make([]Result, 0, len(rn.results))
+	for _, res := range rn.results {
+		out := Result{
+			Target:    res.Target,
+			Phase:     res.Phase,
+			Operation: res.Operation,
+			Success:   res.Success,
+			Duration:  res.Duration,
+			Details:   res.Details,
+			Diff:      res.Diff,
+		}
+		if res.Error != nil {
+			out.Error = res.Error.Error()
+		}
+		results = append(results, out)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) getDiff(w http.ResponseWriter, r *http.Request) {
+	rn, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if rn.FinishedAt == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("run is %s", rn.Status))
+		return
+	}
+	if rn.diff == nil {
+		writeError(w, http.StatusNotFound, "run has no diff; start it with dry_run or diff")
+		return
+	}
+
+	format := diff.OutputFormat(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		format = diff.OutputFormatHuman
+	case diff.OutputFormatHuman, diff.OutputFormatJSON, diff.OutputFormatGitHub, diff.OutputFormatCompact, diff.OutputFormatSideBySide:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q", format))
+		return
+	}
+
+	contentType := "text/plain; charset=utf-8"
+	if format == diff.OutputFormatJSON {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(diff.FormatDiff(rn.diff, format)))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn("Failed to write API response")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
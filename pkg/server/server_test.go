@@ -0,0 +1,426 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/extended-data-library/secretssync/pkg/diff"
+	"github.com/extended-data-library/secretssync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "s3cret"
+
+// fakeRunner returns a canned diff for runs that compute one. When block is
+// set, each run waits for it to be closed or for its context to be canceled.
+type fakeRunner struct {
+	block     chan struct{}
+	fail      bool
+	guardrail bool
+	err       error
+	config    *pipeline.Config
+
+	mu   sync.Mutex
+	opts []pipeline.Options
+}
+
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{config: &pipeline.Config{
+		Targets: map[string]pipeline.Target{"Stg": {}, "Prod": {}},
+	}}
+}
+
+func (f *fakeRunner) Config() *pipeline.Config { return f.config }
+
+func (f *fakeRunner) RunWithDiff(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, *diff.PipelineDiff, error) {
+	f.mu.Lock()
+	f.opts = append(f.opts, opts)
+	f.mu.Unlock()
+
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	result := pipeline.Result{Target: "Stg", Phase: "sync", Operation: "sync", Success: !f.fail}
+	if f.fail {
+		result.Error = errors.New("access denied")
+	}
+	if f.guardrail && !opts.AllowLargeChanges {
+		result.Success = false
+		result.Error = &pipeline.GuardrailError{Target: "Stg", Phase: "sync", Guardrail: pipeline.GuardrailMaxRemoved}
+	}
+	if !opts.DryRun && !opts.ComputeDiff {
+		return []pipeline.Result{result}, nil, f.err
+	}
+
+	changes := []diff.SecretChange{{Path: "api-key", ChangeType: diff.ChangeTypeAdded}}
+	d := &diff.PipelineDiff{DryRun: opts.DryRun}
+	d.AddTargetDiff(diff.TargetDiff{Target: "Stg", Changes: changes, Summary: diff.ComputeSummary(changes)})
+	return []pipeline.Result{result}, d, f.err
+}
+
+func newTestServer(t *testing.T, runner Runner) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := New(func() Runner { return runner }, testToken, nil)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, ts *httptest.Server, method, path, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(b)
+}
+
+// startRun starts a run and waits for it to finish
+func startRun(t *testing.T, s *Server, ts *httptest.Server, body string) RunStatus {
+	t.Helper()
+	resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", body)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, out)
+
+	var status RunStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	assert.Equal(t, "/api/v1/runs/"+status.ID, resp.Header.Get("Location"))
+
+	require.NoError(t, s.Wait(context.Background()))
+	_, out = do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID, "")
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+	return status
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(func() Runner { return nil }, "", nil)
+	assert.ErrorContains(t, err, "an API token is required")
+}
+
+func TestServer_Auth(t *testing.T) {
+	_, ts := newTestServer(t, newFakeRunner())
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "missing", header: "", want: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic " + testToken, want: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "valid", header: "Bearer " + testToken, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/runs", nil)
+			require.NoError(t, err)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+}
+
+func TestServer_CreateRunValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		runner  Runner
+		body    string
+		want    int
+		wantErr string
+	}{
+		{name: "invalid json", runner: newFakeRunner(), body: "{", want: http.StatusBadRequest, wantErr: "invalid request"},
+		{name: "unknown field", runner: newFakeRunner(), body: `{"dryrun": true}`, want: http.StatusBadRequest, wantErr: "unknown field"},
+		{name: "unknown operation", runner: newFakeRunner(), body: `{"operation": "deploy"}`, want: http.StatusBadRequest, wantErr: `unknown operation "deploy"`},
+		{name: "unknown target", runner: newFakeRunner(), body: `{"targets": ["Stg", "Dev"]}`, want: http.StatusBadRequest, wantErr: `unknown target "Dev"`},
+		{name: "not loaded", runner: nil, body: `{}`, want: http.StatusServiceUnavailable, wantErr: "pipeline is not loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newTestServer(t, tt.runner)
+			resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", tt.body)
+			assert.Equal(t, tt.want, resp.StatusCode)
+			var body map[string]string
+			require.NoError(t, json.Unmarshal([]byte(out), &body))
+			assert.Contains(t, body["error"], tt.wantErr)
+		})
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	tests := []struct {
+		name         string
+		fail         bool
+		guardrail    bool
+		err          error
+		body         string
+		wantOpts     pipeline.Options
+		wantStatus   string
+		wantExitCode int
+		wantError    string
+		wantDiff     bool
+	}{
+		{
+			name: "targeted dry run",
+			body: `{"targets": ["Stg"], "dry_run": true}`,
+			wantOpts: pipeline.Options{
+				Operation:       pipeline.OperationPipeline,
+				Targets:         []string{"Stg"},
+				DryRun:          true,
+				ContinueOnError: true,
+				ComputeDiff:     true,
+			},
+			wantStatus:   StatusSucceeded,
+			wantExitCode: 1,
+			wantDiff:     true,
+		},
+		{
+			name: "sync without diff",
+			body: `{"operation": "sync"}`,
+			wantOpts: pipeline.Options{
+				Operation:       pipeline.OperationSync,
+				ContinueOnError: true,
+			},
+			wantStatus: StatusSucceeded,
+		},
+		{
+			name:         "target failure",
+			fail:         true,
+			body:         `{"diff": true}`,
+			wantOpts:     pipeline.Options{Operation: pipeline.OperationPipeline, ContinueOnError: true, ComputeDiff: true},
+			wantStatus:   StatusFailed,
+			wantExitCode: 2,
+			wantError:    "one or more targets failed",
+			wantDiff:     true,
+		},
+		{
+			name:         "guardrail tripped",
+			guardrail:    true,
+			body:         `{}`,
+			wantOpts:     pipeline.Options{Operation: pipeline.OperationPipeline, ContinueOnError: true},
+			wantStatus:   StatusFailed,
+			wantExitCode: pipeline.ExitCodeGuardrail,
+			wantError:    "one or more targets failed",
+		},
+		{
+			name:       "large changes allowed",
+			guardrail:  true,
+			body:       `{"allow_large_changes": true}`,
+			wantOpts:   pipeline.Options{Operation: pipeline.OperationPipeline, ContinueOnError: true, AllowLargeChanges: true},
+			wantStatus: StatusSucceeded,
+		},
+		{
+			name:         "run error",
+			err:          errors.New("failed to load config"),
+			body:         `{}`,
+			wantOpts:     pipeline.Options{Operation: pipeline.OperationPipeline, ContinueOnError: true},
+			wantStatus:   StatusFailed,
+			wantExitCode: 2,
+			wantError:    "failed to load config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeRunner()
+			f.fail, f.guardrail, f.err = tt.fail, tt.guardrail, tt.err
+			s, ts := newTestServer(t, f)
+
+			status := startRun(t, s, ts, tt.body)
+			assert.Equal(t, tt.wantStatus, status.Status)
+			assert.Equal(t, tt.wantExitCode, status.ExitCode)
+			assert.Equal(t, tt.wantError, status.Error)
+			assert.NotNil(t, status.FinishedAt)
+			assert.Equal(t, []pipeline.Options{tt.wantOpts}, f.opts)
+
+			resp, out := do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID+"/results", "")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			var results []Result
+			require.NoError(t, json.Unmarshal([]byte(out), &results))
+			if tt.err == nil {
+				require.Len(t, results, 1)
+				assert.Equal(t, "Stg", results[0].Target)
+				assert.Equal(t, tt.wantStatus == StatusSucceeded, results[0].Success)
+				if tt.fail {
+					assert.Equal(t, "access denied", results[0].Error)
+				}
+			}
+
+			resp, out = do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID+"/diff", "")
+			if !tt.wantDiff {
+				assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+				assert.Contains(t, out, "run has no diff")
+				return
+			}
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, out, "api-key")
+		})
+	}
+}
+
+func TestServer_DiffFormats(t *testing.T) {
+	s, ts := newTestServer(t, newFakeRunner())
+	status := startRun(t, s, ts, `{"dry_run": true}`)
+	path := "/api/v1/runs/" + status.ID + "/diff"
+
+	tests := []struct {
+		format          string
+		wantCode        int
+		wantContentType string
+	}{
+		{format: "", wantCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{format: "human", wantCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{format: "json", wantCode: http.StatusOK, wantContentType: "application/json"},
+		{format: "github", wantCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{format: "compact", wantCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{format: "sidebyside", wantCode: http.StatusOK, wantContentType: "text/plain; charset=utf-8"},
+		{format: "yaml", wantCode: http.StatusBadRequest, wantContentType: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			resp, out := do(t, ts, http.MethodGet, path+"?format="+tt.format, "")
+			assert.Equal(t, tt.wantCode, resp.StatusCode)
+			assert.Equal(t, tt.wantContentType, resp.Header.Get("Content-Type"))
+			if tt.wantCode == http.StatusOK {
+				format := diff.OutputFormat(tt.format)
+				if format == "" {
+					format = diff.OutputFormatHuman
+				}
+				assert.Equal(t, diff.FormatDiff(s.runs[status.ID].diff, format), out)
+			}
+		})
+	}
+}
+
+func TestServer_RunInProgress(t *testing.T) {
+	f := newFakeRunner()
+	f.block = make(chan struct{})
+	s, ts := newTestServer(t, f)
+
+	resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", `{"dry_run": true}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var status RunStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+
+	for _, path := range []string{"/results", "/diff"} {
+		resp, _ := do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID+path, "")
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, path)
+	}
+
+	close(f.block)
+	require.NoError(t, s.Wait(context.Background()))
+	resp, _ = do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID+"/diff", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServer_RunLock(t *testing.T) {
+	f := newFakeRunner()
+	var lock sync.Mutex
+	s, err := New(func() Runner { return f }, testToken, &lock)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	// Runs stay pending while another run holds the lock
+	lock.Lock()
+	var ids []string
+	for i := 0; i < MaxPendingRuns; i++ {
+		resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", `{"dry_run": true}`)
+		require.Equal(t, http.StatusAccepted, resp.StatusCode, out)
+		var status RunStatus
+		require.NoError(t, json.Unmarshal([]byte(out), &status))
+		ids = append(ids, status.ID)
+	}
+
+	// Too many runs are waiting
+	resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", `{"dry_run": true}`)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode, out)
+
+	time.Sleep(50 * time.Millisecond)
+	for _, id := range ids {
+		var status RunStatus
+		_, out := do(t, ts, http.MethodGet, "/api/v1/runs/"+id, "")
+		require.NoError(t, json.Unmarshal([]byte(out), &status))
+		assert.Equal(t, StatusPending, status.Status)
+		assert.Nil(t, status.StartedAt)
+	}
+	f.mu.Lock()
+	assert.Empty(t, f.opts)
+	f.mu.Unlock()
+
+	lock.Unlock()
+	require.NoError(t, s.Wait(context.Background()))
+	for _, id := range ids {
+		var status RunStatus
+		_, out := do(t, ts, http.MethodGet, "/api/v1/runs/"+id, "")
+		require.NoError(t, json.Unmarshal([]byte(out), &status))
+		assert.Equal(t, StatusSucceeded, status.Status)
+	}
+}
+
+func TestServer_NotFound(t *testing.T) {
+	_, ts := newTestServer(t, newFakeRunner())
+	for _, path := range []string{"", "/results", "/diff"} {
+		resp, _ := do(t, ts, http.MethodGet, "/api/v1/runs/missing"+path, "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestServer_ListRuns(t *testing.T) {
+	s, ts := newTestServer(t, newFakeRunner())
+	for i := 0; i < MaxRuns+5; i++ {
+		startRun(t, s, ts, fmt.Sprintf(`{"dry_run": %t}`, i%2 == 0))
+	}
+
+	resp, out := do(t, ts, http.MethodGet, "/api/v1/runs", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var runs []RunStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &runs))
+
+	// Newest first, and only the last MaxRuns are kept
+	require.Len(t, runs, MaxRuns)
+	assert.Equal(t, s.ids[len(s.ids)-1], runs[0].ID)
+}
+
+func TestServer_WaitTimeout(t *testing.T) {
+	f := newFakeRunner()
+	f.block = make(chan struct{})
+	s, ts := newTestServer(t, f)
+
+	resp, out := do(t, ts, http.MethodPost, "/api/v1/runs", `{}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var status RunStatus
+	require.NoError(t, json.Unmarshal([]byte(out), &status))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Wait(ctx), context.DeadlineExceeded)
+
+	// The in-flight run is canceled
+	assert.Eventually(t, func() bool {
+		_, out := do(t, ts, http.MethodGet, "/api/v1/runs/"+status.ID, "")
+		return strings.Contains(out, context.Canceled.Error())
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -93,6 +93,11 @@ func appendSlices(dst, src []interface{}) []interface{} {
 	return result
 }
 
+// DeepCopy returns a deep copy of a value decoded from JSON
+func DeepCopy(v interface{}) interface{} {
+	return deepCopyValue(v)
+}
+
 // deepCopyValue creates a deep copy of a value
 func deepCopyValue(v interface{}) interface{} {
 	if v == nil {
@@ -61,6 +61,7 @@ type SyncResult struct {
 	SecretsModified  int    // Secrets modified
 	SecretsRemoved   int    // Secrets removed
 	SecretsUnchanged int    // Secrets unchanged
+	SecretsFiltered  int    // Secrets skipped by source or target filters
 	DurationMs       int64  // Duration in milliseconds
 	ErrorMessage     string // Error message if failed
 	ResultsJSON      string // Full results as JSON
@@ -203,6 +204,7 @@ func RunPipeline(configPath string, opts *SyncOptions) *SyncResult {
 		result.SecretsModified += r.Details.SecretsModified
 		result.SecretsRemoved += r.Details.SecretsRemoved
 		result.SecretsUnchanged += r.Details.SecretsUnchanged
+		result.SecretsFiltered += r.Details.SecretsFiltered
 
 		if !r.Success && result.Success {
 			result.Success = false
@@ -331,7 +333,7 @@ func GetConfigInfo(configPath string) *ConfigInfo {
 	info.TargetCount = len(cfg.Targets)
 	info.VaultAddress = cfg.Vault.Address
 	info.AWSRegion = cfg.AWS.Region
-	info.HasMergeStore = cfg.MergeStore.Vault != nil || cfg.MergeStore.S3 != nil
+	info.HasMergeStore = cfg.MergeStore.Vault != nil || cfg.MergeStore.S3 != nil || cfg.MergeStore.File != nil
 
 	// Build sorted slices for deterministic output
 	info.Sources = make([]string, 0, len(cfg.Sources))